@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"marvelbot/pkg/card"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileNameReplacer turns a set or pack name into a file name in the style of the card data, e.g. The_Doomsday_Chair
+var fileNameReplacer = strings.NewReplacer(
+	" ", "_", "/", "_", "\\", "_",
+	":", "", "?", "", "*", "", "\"", "", "<", "", ">", "", "|", "",
+)
+
+// dataFile is a card data file, along with the cards to be written to it.
+type dataFile struct {
+	path   string
+	cards  []*card.Card
+	exists bool
+	data   []byte // The contents of an existing file, so that cards left untouched by a merge are written as they were
+}
+
+// importCards converts a MarvelCDB cards JSON dump, read from a file or stdin, into card data files laid out like
+// data/cards/<SKU>/<Set>.yaml. With -merge, cards are merged into any existing file that already has them or their set,
+// filling in what is missing without replacing anything curated by hand. Without it, existing files are never touched.
+func importCards(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	out := fs.String("o", "data/cards", "Directory to write card data to")
+	merge := fs.Bool("merge", false, "Merge into existing card data files")
+	packsPath := fs.String("packs", "data/packs.yaml", "Pack data used to find the SKU of each card's pack")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	packs, err := card.LoadPackRegistry(*packsPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var r io.Reader = os.Stdin
+	if fs.NArg() > 0 && fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+	mcdbCards := []*card.MarvelCDBCard{}
+	if err := json.NewDecoder(r).Decode(&mcdbCards); err != nil {
+		fmt.Fprintf(os.Stderr, "error unmarshaling MarvelCDB cards: %v\n", err)
+		return 1
+	}
+
+	// Existing files are read once for each pack they might be merged into
+	existing := map[string][]*dataFile{}
+	files := map[string]*dataFile{}
+	order := []*dataFile{}
+	cards := card.ConvertCards(mcdbCards, packs)
+	for _, c := range cards {
+		sku := c.Packs[0].SKU
+		if *merge {
+			if _, ok := existing[sku]; !ok {
+				dataFiles, err := readDataFiles(filepath.Join(*out, sku))
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					return 1
+				}
+				existing[sku] = dataFiles
+			}
+		}
+		df := findDataFile(existing[sku], c)
+		if df == nil {
+			path := filepath.Join(*out, sku, fileNameReplacer.Replace(dataFileName(c))+".yaml")
+			if df = files[path]; df == nil {
+				df = &dataFile{path: path}
+				for _, other := range existing[sku] {
+					if other.path == path {
+						df = other
+					}
+				}
+				if _, err := os.Stat(path); err == nil {
+					df.exists = true
+				}
+				files[path] = df
+			}
+		}
+		if !containsFile(order, df) {
+			order = append(order, df)
+		}
+		df.cards = card.MergeCards(df.cards, []*card.Card{c})
+	}
+
+	if !*merge {
+		for _, df := range order {
+			if df.exists {
+				fmt.Fprintf(os.Stderr, "%s already exists, use -merge to merge into it\n", df.path)
+				return 1
+			}
+		}
+	}
+	for _, df := range order {
+		if err := writeDataFile(df); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	fmt.Printf("%d cards imported into %d files\n", len(cards), len(order))
+	return 0
+}
+
+// dataFileName returns the name of the file a card belongs in: its encounter or hero set, its aspect for player cards
+// outside of a set, or otherwise its pack.
+func dataFileName(c *card.Card) string {
+	switch {
+	case len(c.Sets) > 0:
+		return c.Sets[0].Name
+	case len(c.Faces) > 0 && len(c.Faces[0].Aspect) > 0:
+		return c.Faces[0].Aspect[0]
+	default:
+		return c.Packs[0].Name
+	}
+}
+
+// readDataFiles reads every card data file under dir. A missing directory has no files.
+func readDataFiles(dir string) (dataFiles []*dataFile, err error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil, nil
+	}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".yaml") {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		cards := []*card.Card{}
+		if err := yaml.Unmarshal(data, &cards); err != nil {
+			return fmt.Errorf("error unmarshaling %s: %w", path, err)
+		}
+		dataFiles = append(dataFiles, &dataFile{path: path, cards: cards, exists: true, data: data})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", dir, err)
+	}
+	return dataFiles, nil
+}
+
+// findDataFile returns the existing file a card should be merged into: the file that already has the card, or failing
+// that, one with other cards from the same set, or one named for the card's set, aspect or pack wherever it is in the
+// pack's directory, e.g. Aspects/Basic.yaml.
+func findDataFile(dataFiles []*dataFile, c *card.Card) *dataFile {
+	for _, df := range dataFiles {
+		if card.FindMatch(df.cards, c) != nil {
+			return df
+		}
+	}
+	for _, df := range dataFiles {
+		for _, other := range df.cards {
+			for _, set := range other.Sets {
+				for _, s := range c.Sets {
+					if strings.EqualFold(set.Name, s.Name) {
+						return df
+					}
+				}
+			}
+		}
+	}
+	name := fileNameReplacer.Replace(dataFileName(c)) + ".yaml"
+	for _, df := range dataFiles {
+		if strings.EqualFold(filepath.Base(df.path), name) {
+			return df
+		}
+	}
+	return nil
+}
+
+// containsFile returns whether the file is in the slice.
+func containsFile(dataFiles []*dataFile, df *dataFile) bool {
+	for _, other := range dataFiles {
+		if other == df {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDataFile writes the cards of a data file as YAML, creating its directory if needed. Cards that are unchanged from
+// the existing file keep their original text, comments included, so that a merge only touches the cards it changed.
+func writeDataFile(df *dataFile) error {
+	chunks := splitCards(df.data)
+	var b bytes.Buffer
+	for i, c := range df.cards {
+		data, err := encodeCard(c)
+		if err != nil {
+			return fmt.Errorf("error marshaling %s: %w", df.path, err)
+		}
+		// The header of the file, such as a leading comment, is kept before the first card
+		if i == 0 && len(chunks) > 0 {
+			b.Write(chunks[0])
+		}
+		// Merged cards keep their place in the file, and imported cards are added after them
+		if i+1 < len(chunks) {
+			original := []*card.Card{}
+			if err := yaml.Unmarshal(chunks[i+1], &original); err == nil && len(original) == 1 {
+				if unchanged, err := encodeCard(original[0]); err == nil && bytes.Equal(unchanged, data) {
+					data = chunks[i+1]
+				}
+			}
+		}
+		b.Write(data)
+	}
+	if err := os.MkdirAll(filepath.Dir(df.path), 0755); err != nil {
+		return fmt.Errorf("unable to create directory for %s: %w", df.path, err)
+	}
+	if err := ioutil.WriteFile(df.path, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", df.path, err)
+	}
+	return nil
+}
+
+// splitCards splits the contents of a card data file into the text of each card, preceded by the header of the file.
+// Each card starts at an unindented "-", along with any comments or blank lines just before it.
+func splitCards(data []byte) (chunks [][]byte) {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	start, pending := 0, 0
+	for i, line := range lines {
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 || (trimmed[0] == '#' && line[0] == '#') {
+			continue
+		}
+		if line[0] == '-' {
+			// Comments and blank lines just before the card belong to it
+			pending = i
+			for pending > start && isBlankOrComment(lines[pending-1]) {
+				pending--
+			}
+			chunks = append(chunks, bytes.Join(lines[start:pending], nil))
+			start = pending
+		}
+	}
+	return append(chunks, bytes.Join(lines[start:], nil))
+}
+
+// isBlankOrComment returns whether a line is blank or an unindented comment.
+func isBlankOrComment(line []byte) bool {
+	return len(bytes.TrimSpace(line)) == 0 || line[0] == '#'
+}
+
+// encodeCard marshals a card as an item of a card data file, in the style of the card data: indented by two spaces,
+// with sequences in a mapping at the same indent as their key.
+func encodeCard(c *card.Card) ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode([]*card.Card{c}); err != nil {
+		return nil, err
+	}
+	return compactSequences(b.Bytes()), nil
+}
+
+// compactSequences removes the indent that the YAML encoder gives to sequences in a mapping, e.g. "names:\n  - Rhino"
+// becomes "names:\n- Rhino". Block scalars are moved along with their key but otherwise left alone.
+func compactSequences(data []byte) []byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	keys := []int{} // The columns of the keys whose sequences are being unindented
+	scalar := -1    // The column of the key of the block scalar being copied, if any
+	var b bytes.Buffer
+	for i, line := range lines {
+		indent := len(line) - len(bytes.TrimLeft(line, " "))
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 {
+			b.Write(line)
+			continue
+		}
+		for len(keys) > 0 && indent <= keys[len(keys)-1] {
+			keys = keys[:len(keys)-1]
+		}
+		b.Write(line[2*len(keys):])
+		if scalar >= 0 && indent > scalar {
+			continue
+		}
+		scalar = -1
+
+		// The key of a mapping may follow the "- " of any sequence items it starts
+		column := indent
+		for bytes.HasPrefix(line[column:], []byte("- ")) {
+			column += 2
+		}
+		if bytes.HasSuffix(trimmed, []byte("|")) || bytes.HasSuffix(trimmed, []byte("|-")) ||
+			bytes.HasSuffix(trimmed, []byte(">")) || bytes.HasSuffix(trimmed, []byte(">-")) {
+			scalar = column
+			continue
+		}
+		if !bytes.HasSuffix(trimmed, []byte(":")) || i+1 >= len(lines) {
+			continue
+		}
+		next := lines[i+1]
+		if nextIndent := len(next) - len(bytes.TrimLeft(next, " ")); nextIndent == column+2 &&
+			bytes.HasPrefix(next[nextIndent:], []byte("-")) {
+			keys = append(keys, column)
+		}
+	}
+	return b.Bytes()
+}
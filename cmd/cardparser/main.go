@@ -1,5 +1,136 @@
 package main
 
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"marvelbot/pkg/card"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const usage = "usage: cardparser validate [-known file] [path ...]\n       cardparser import [-o dir] [-packs file] [-merge] [file]"
+
+// defaultPaths are the card data directories validated when no paths are given.
+var defaultPaths = []string{"data/cards", "data/homebrew"}
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "validate":
+		os.Exit(validate(os.Args[2:]))
+	case "import":
+		os.Exit(importCards(os.Args[2:]))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n%s\n", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// validate checks every YAML file under the paths, printing each problem found with its file and line. Problems listed
+// in the known problems file are gaps in the card data that have yet to be filled in, and are left out. It returns a
+// non-zero exit code if there are any other problems.
+func validate(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	knownPath := fs.String("known", "data/cards/known_problems.txt", "Problems to leave out, one file: message per line")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	known, err := readKnownProblems(*knownPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = defaultPaths
+	}
+	v := card.NewValidator()
+	files := 0
+	for _, path := range paths {
+		err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || !(strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")) {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			v.Validate(path, data)
+			files++
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading %s: %v\n", path, err)
+			return 1
+		}
+	}
+
+	problems := 0
+	skipped := 0
+	for _, p := range v.Problems() {
+		key := knownProblem(p.File, p.Message)
+		if _, ok := known[key]; ok {
+			known[key] = true
+			skipped++
+			continue
+		}
+		fmt.Println(p)
+		problems++
+	}
+	// Known problems that were not found have likely been fixed, and should be removed from the list
+	for key, found := range known {
+		if !found {
+			fmt.Fprintf(os.Stderr, "known problem not found: %s\n", key)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d problems found in %d files (%d known problems left out)\n", problems, files, skipped)
+	if problems > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readKnownProblems reads a known problems file, where each line is the file and message of a problem, e.g.
+// data/cards/MC11en/Kang.yaml: main scheme "The Master of Time" has no completion threat. Blank lines and lines
+// starting with # are ignored. Line numbers are left out so that the list survives edits elsewhere in the file. A
+// missing file has no known problems. Each problem is mapped to whether it has been found.
+func readKnownProblems(path string) (map[string]bool, error) {
+	known := map[string]bool{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return known, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ": ")
+		if i < 0 {
+			return nil, fmt.Errorf("%s: expected file: message, got %q", path, line)
+		}
+		known[knownProblem(line[:i], line[i+2:])] = false
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return known, nil
+}
 
+// knownProblem returns the key of a problem in the known problems, made up of its file and message.
+func knownProblem(file string, message string) string {
+	return filepath.ToSlash(filepath.Clean(file)) + ": " + message
 }
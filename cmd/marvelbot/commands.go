@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"marvelbot/pkg/card"
+	"marvelbot/pkg/config"
+	"marvelbot/pkg/server"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// runCommand runs a command line subcommand, such as `marvelbot cache warm`, and returns the exit code.
+func runCommand(args []string, cfg *config.Config) int {
+	switch strings.Join(args, " ") {
+	case "cache warm":
+		return warmCache(cfg)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command: %s\nusage: marvelbot [-t token] [-c config] [cache warm]\n", strings.Join(args, " "))
+	return 2
+}
+
+// warmCache downloads every card image that is not already in the configured image cache.
+func warmCache(cfg *config.Config) int {
+	cards := []*card.Card{}
+	for _, path := range []string{"data/cards", "data/homebrew"} {
+		c, err := server.ReadCards(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		cards = append(cards, c...)
+	}
+
+	cache := server.NewImageCache(cfg, &http.Client{Timeout: time.Second * 30})
+	result := cache.Warm(cards)
+	for _, err := range result.Errors {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	fmt.Printf("%d images downloaded, %d already cached, %d failed\n", result.Fetched, result.Cached, len(result.Errors))
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
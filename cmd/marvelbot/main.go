@@ -3,7 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/bwmarrin/discordgo"
+	"marvelbot/pkg/config"
 	"marvelbot/pkg/server"
 	"os"
 	"os/signal"
@@ -13,79 +13,43 @@ import (
 
 // Variables used for command line parameters
 var (
-	Token string
+	Token      string
+	ConfigPath string
 )
 
 func init() {
 	flag.StringVar(&Token, "t", "", "Bot Token")
+	flag.StringVar(&ConfigPath, "c", "config.yaml", "Config file")
 	flag.Parse()
 }
 
 func main() {
-	// Create a new Server.
-	srv := server.NewServer(Token)
-
-/*
-		// Get MarvelCDB cards
-		const baseURL = "https://marvelcdb.com/api/public"
-		req, err := http.NewRequest("GET", fmt.Sprintf("%s/cards/?_format=json&encounter=1", baseURL), nil)
-		if err != nil {
-			fmt.Println("failed to build request:", err)
-		}
-
-		client := &http.Client{
-			Timeout: time.Second * 30,
-		}
-
-		resp, err := client.Do(req)
-		if err != nil {
-			fmt.Println("request failed:", err)
-		}
-		// TODO - handle error closing the response?
-		defer resp.Body.Close()
-
-		// Create a new slice to hold the unmarshaled response
-		mcdbCards := []*card.MarvelCDBCard{}
-
-		// Unmarshal the JSON response into individual cards
-		if err := json.NewDecoder(resp.Body).Decode(&mcdbCards); err != nil {
-			fmt.Println("error unmarshaling:", err)
-		}
+	// Read the config file, if there is one. The token flag takes priority over the config file.
+	cfg, err := config.Load(ConfigPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-		// Convert each card
-		convertedCards := []*card.Card{}
-		for _, mcdbCard := range mcdbCards {
-			converted := mcdbCard.Convert()
-			convertedCards = append(convertedCards, converted)
-		}
+	// Subcommands such as `cache warm` run without connecting to Discord
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args(), cfg))
+	}
+	if Token == "" {
+		Token = cfg.Token
+	}
 
-		// Write the converted YAML to a file somewhere
-		yamlText, err := yaml.Marshal(&convertedCards)
-		f, err := os.Create("data/converted.yaml")
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		defer f.Close()
-		_, err = f.Write(yamlText)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-*/
+	// Create a new Server.
+	srv := server.NewServer(Token, cfg)
 
 	// Register the MessageCreate func as a callback for MessageCreate events.
 	srv.Session.AddHandler(srv.MessageCreate)
 
-	// Add handlers for all of our slash commands
-	srv.Session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if h, ok := srv.Handlers[i.Data.Name]; ok {
-			h(s, i)
-		}
-	})
+	// Add handlers for all of our slash commands, autocomplete requests and message components
+	srv.Session.AddHandler(srv.HandleInteraction)
 
 	// Open a websocket connection to Discord and begin listening.
-	err := srv.Session.Open()
+	err = srv.Session.Open()
 	if err != nil {
 		srv.Logger.Fatal("error opening Discord websocket: ", err)
 		return
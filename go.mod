@@ -3,11 +3,11 @@ module marvelbot
 go 1.16
 
 require (
-	github.com/bwmarrin/discordgo v0.23.3-0.20210520041320-df74d8bbe6cd
-	github.com/go-kit/kit v0.12.0 // indirect
-	github.com/ozankasikci/go-image-merge v0.2.2 // indirect
-	github.com/segmentio/ksuid v1.0.4 // indirect
-	github.com/sirupsen/logrus v1.8.1 // indirect
-	github.com/texttheater/golang-levenshtein/levenshtein v0.0.0-20200805054039-cae8b0eaed6c // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	github.com/aws/aws-sdk-go v1.40.45
+	github.com/bwmarrin/discordgo v0.24.0
+	github.com/go-kit/kit v0.12.0
+	github.com/sirupsen/logrus v1.8.1
+	github.com/texttheater/golang-levenshtein/levenshtein v0.0.0-20200805054039-cae8b0eaed6c
+	golang.org/x/image v0.0.0-20211028202545-6944b10bf410
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -0,0 +1,192 @@
+package card
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of images an ImageCache will download at once.
+const DefaultConcurrency = 8
+
+// DefaultImageCache is the cache used by Card.DownloadImages. It keeps images in IMAGE_BASEDIR and downloads them
+// from the bucket.
+var DefaultImageCache = NewImageCache(
+	NewDirStore(IMAGE_BASEDIR),
+	NewHTTPStore(ImageBaseURL, &http.Client{Timeout: time.Second * 30}),
+	DefaultConcurrency,
+)
+
+// ImageCache keeps card images in an ImageStore so that they can be merged into a single image for Discord, fetching
+// missing images from a source ImageStore. Images are validated before they are saved, and no more than a fixed number
+// of fetches run at once.
+type ImageCache struct {
+	store    ImageStore
+	source   ImageStore
+	sem      chan struct{}
+	mu       sync.Mutex
+	inflight map[string]*download
+}
+
+// download is an image fetch in progress, shared by every caller requesting the same image.
+type download struct {
+	done chan struct{}
+	err  error
+}
+
+// WarmResult summarizes a call to ImageCache.Warm.
+type WarmResult struct {
+	Cached  int     // Images that were already in the cache
+	Fetched int     // Images that were downloaded
+	Errors  []error // Images that could not be downloaded
+}
+
+// NewImageCache creates an ImageCache that keeps images in store, fetches missing images from source, and runs at most
+// concurrency fetches at once.
+func NewImageCache(store ImageStore, source ImageStore, concurrency int) *ImageCache {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &ImageCache{
+		store:    store,
+		source:   source,
+		sem:      make(chan struct{}, concurrency),
+		inflight: map[string]*download{},
+	}
+}
+
+// Cached returns whether every face of the card has an image in the cache.
+func (ic *ImageCache) Cached(c *Card) bool {
+	if len(c.Faces) == 0 {
+		return false
+	}
+	for _, f := range c.Faces {
+		key, err := ImageKey(f)
+		if err != nil || !ic.store.Has(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// Image returns the decoded image of a card face from the cache.
+func (ic *ImageCache) Image(f *Face) (image.Image, error) {
+	key, err := ImageKey(f)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ic.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image for %s: %v", f.Name, err)
+	}
+	return img, nil
+}
+
+// Fetch downloads any images of the card that are not already cached, waiting until they are saved.
+func (ic *ImageCache) Fetch(c *Card) error {
+	if len(c.Faces) == 0 {
+		return fmt.Errorf("unable to download images for %v: no faces", c.Names)
+	}
+	for _, f := range c.Faces {
+		if _, err := ic.fetchFace(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchAsync starts downloading any images of the card that are not already cached, without waiting for them. This
+// is used on the request path, so that a user is never kept waiting on a cold download.
+func (ic *ImageCache) FetchAsync(c *Card) {
+	go ic.Fetch(c)
+}
+
+// Warm downloads the images of every card that are not already cached, running downloads concurrently up to the
+// cache's limit. It returns once every download has finished.
+func (ic *ImageCache) Warm(cards []*Card) (result WarmResult) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range cards {
+		for _, f := range c.Faces {
+			if f.ImageURL == nil {
+				continue
+			}
+			wg.Add(1)
+			go func(f *Face) {
+				defer wg.Done()
+				fetched, err := ic.fetchFace(f)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err != nil:
+					result.Errors = append(result.Errors, err)
+				case fetched:
+					result.Fetched++
+				default:
+					result.Cached++
+				}
+			}(f)
+		}
+	}
+	wg.Wait()
+	return result
+}
+
+// fetchFace fetches the image of a single face if it is not already cached. Concurrent requests for the same image
+// share a single fetch. It returns whether a fetch took place.
+func (ic *ImageCache) fetchFace(f *Face) (fetched bool, err error) {
+	key, err := ImageKey(f)
+	if err != nil {
+		return false, err
+	}
+	if ic.store.Has(key) {
+		return false, nil
+	}
+
+	ic.mu.Lock()
+	if d, ok := ic.inflight[key]; ok {
+		ic.mu.Unlock()
+		<-d.done
+		return false, d.err
+	}
+	d := &download{done: make(chan struct{})}
+	ic.inflight[key] = d
+	ic.mu.Unlock()
+
+	// Limit the number of concurrent fetches
+	ic.sem <- struct{}{}
+	d.err = ic.fetch(f, key)
+	<-ic.sem
+
+	ic.mu.Lock()
+	delete(ic.inflight, key)
+	ic.mu.Unlock()
+	close(d.done)
+	return d.err == nil, d.err
+}
+
+// fetch retrieves an image from the source, checks that it is a valid image, and saves it to the store.
+func (ic *ImageCache) fetch(f *Face, key string) error {
+	sourceKey, err := SourceKey(f)
+	if err != nil {
+		return err
+	}
+	data, err := ic.source.Get(sourceKey)
+	if err != nil {
+		return err
+	}
+	// Make sure we received an image, rather than an error page or a truncated file
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("error decoding image %s: %v", sourceKey, err)
+	}
+	return ic.store.Put(key, data)
+}
@@ -0,0 +1,116 @@
+package card
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestImageCache_Warm(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/good.png":
+			w.Write(img.Bytes())
+		case "/bad.png":
+			w.Write([]byte("<html>Not Found</html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newCard := func(image string) *Card {
+		url := server.URL + "/" + image
+		return &Card{
+			Packs: []*Pack{{SKU: "MC01en"}},
+			Faces: []*Face{{Name: image, ImageURL: &url}},
+		}
+	}
+	good, bad, missing := newCard("good.png"), newCard("bad.png"), newCard("missing.png")
+	cache := NewImageCache(NewDirStore(dir), NewHTTPStore(server.URL, server.Client()), 2)
+
+	result := cache.Warm([]*Card{good, bad, missing})
+	if result.Fetched != 1 || result.Cached != 0 || len(result.Errors) != 2 {
+		t.Errorf("first warm: got %d fetched, %d cached, %d errors", result.Fetched, result.Cached, len(result.Errors))
+	}
+	if !cache.Cached(good) {
+		t.Errorf("expected good.png to be cached")
+	}
+	if cache.Cached(bad) || cache.Cached(missing) {
+		t.Errorf("expected invalid images not to be cached")
+	}
+
+	// Failed downloads must not leave partial or temporary files behind
+	key, _ := ImageKey(good.Faces[0])
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != key {
+		names := []string{}
+		for _, f := range files {
+			names = append(names, f.Name())
+		}
+		t.Errorf("expected only good.png in the cache, got %v", names)
+	}
+
+	result = cache.Warm([]*Card{good})
+	if result.Fetched != 0 || result.Cached != 1 || len(result.Errors) != 0 {
+		t.Errorf("second warm: got %d fetched, %d cached, %d errors", result.Fetched, result.Cached, len(result.Errors))
+	}
+}
+
+func TestImageCache_Keys(t *testing.T) {
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	source := NewMemoryStore()
+	source.Put("mc08en/1A.png", img.Bytes())
+	source.Put("mc10en/1A.png", img.Bytes())
+
+	// Meme cards are listed under their own packs, but share image file names with the hero packs
+	newFace := func(url string) *Face {
+		return &Face{Name: url, ImageURL: &url}
+	}
+	strange := &Card{Packs: []*Pack{{SKU: "Memes"}}, Faces: []*Face{newFace(ImageBaseURL + "mc08en/1A.png")}}
+	hulk := &Card{Packs: []*Pack{{SKU: "Memes"}}, Faces: []*Face{newFace(ImageBaseURL + "mc10en/1A.png")}}
+	missing := &Card{Packs: []*Pack{{SKU: "Memes"}}, Faces: []*Face{newFace(ImageBaseURL + "mc11en/1A.png")}}
+
+	store := NewMemoryStore()
+	cache := NewImageCache(store, source, 1)
+	if err := cache.Fetch(strange); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cache.Cached(hulk) {
+		t.Errorf("expected images with the same file name not to share a cache key")
+	}
+	if err := cache.Fetch(hulk); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.images) != 2 {
+		t.Errorf("expected 2 cached images, got %d", len(store.images))
+	}
+	if _, err := cache.Image(hulk.Faces[0]); err != nil {
+		t.Errorf("unexpected error decoding cached image: %v", err)
+	}
+	if err := cache.Fetch(missing); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("expected ErrImageNotFound, got %v", err)
+	}
+}
@@ -1,18 +1,16 @@
 package card
 
 import (
-	"fmt"
-	"image"
-	_ "image/jpeg"
-	"image/png"
-	"net/http"
-	"os"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 const IMAGE_BASEDIR = "images"
 
+// sideRegexp matches the side of a card face in the name of its image, such as the B of 1B.png
+var sideRegexp = regexp.MustCompile(`[0-9]([A-Za-z])\.[A-Za-z]+$`)
+
 // Card is a physical representation of a Marvel Champions card. Each instance of Card should represent the same
 // physical copy of the card. For example, Peter Parker/Spider-Man is a single card. However, Rhino I, Rhino II, and
 // Rhino III are three separate cards.
@@ -23,6 +21,8 @@ type Card struct {
 	Faces      []*Face                                       `json:"faces,omitempty" yaml:"faces,omitempty"` // Supports multiple sides of the card.
 	*Deck      `json:"deck,omitempty" yaml:"deck,omitempty"` // Deck is a pointer to allow for things like status cards.
 	Horizontal bool                                          `json:"horizontal,omitempty" yaml:"horizontal,omitempty"` // Whether the card is rotated horizontally.
+	ID         string                                        `json:"-" yaml:"-"`                                       // Assigned by the CardRepository.
+	Side       string                                        `json:"-" yaml:"-"`                                       // Set by WithSide.
 }
 
 // Deck represents which deck type the card belongs to.
@@ -88,8 +88,8 @@ type Face struct {
 	DefenseText *string `json:"defense_text,omitempty" yaml:"defense_text,omitempty"`
 	// The associated traits, e.g. S.H.I.E.L.D., Spy
 	Traits []string `json:"traits,omitempty" yaml:"traits,omitempty"`
-	// The associated keywords, e.g. Guard, Toughness
-	Keywords []string `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+	// The associated keywords, e.g. Guard, Retaliate 1
+	Keywords []Keyword `json:"keywords,omitempty" yaml:"keywords,omitempty"`
 	// The card's text
 	Text *string `json:"text" yaml:"text"`
 	// The identity's hand size
@@ -154,65 +154,9 @@ type Set struct {
 	Name string `json:"name" yaml:"name"`
 }
 
-// DownloadImages will attempt to download all images for the card from S3 to local storage.
+// DownloadImages will attempt to download all images for the card to local storage, using the DefaultImageCache.
 func (c *Card) DownloadImages() (err error) {
-	if len(c.Faces) == 0 {
-		return fmt.Errorf("unable to download images for %s: no faces\n", c.Names[0])
-	}
-	// We want to save cards to images/<SKU>/<image_name>
-	// We will use the first SKU for the card as the image path
-	for _, face := range c.Faces {
-		// No image to save - try the next face
-		if face.ImageURL == nil {
-			return fmt.Errorf("no images for %s", face.Name)
-		}
-		if len(c.Packs) == 0 {
-			return fmt.Errorf("unable to determine pack for %s", face.Name)
-		}
-		// Determine where to save the image to
-		imageSlice := strings.Split(*face.ImageURL, "/")
-		imageName := imageSlice[len(imageSlice)-1]
-		imageDir := fmt.Sprintf("%s/%s", IMAGE_BASEDIR, c.Packs[0].SKU)
-		imagePath := fmt.Sprintf("%s/%s", imageDir, imageName)
-		// Open the image file, or create it if it doesn't exist
-		if _, err := os.Stat(imageDir); os.IsNotExist(err) {
-			os.Mkdir(imageDir, 0755)
-		}
-		f, err := os.OpenFile(imagePath, os.O_RDWR|os.O_CREATE, 0644)
-		defer f.Close()
-		if err != nil {
-			return fmt.Errorf("unable to open file path: %s", imagePath)
-		}
-		// We have to use the Stat() method to have access to the file size
-		fi, err := f.Stat()
-		if err != nil {
-			return fmt.Errorf("error reading file metadata: %s\n", imagePath)
-		}
-		// If the file is larger than 0 bytes, we will not attempt to download it
-		if fi.Size() > 0 {
-			continue
-		}
-		// At this point, we attempt to make an HTTP call to download the image and save it locally
-		resp, err := http.Get(*face.ImageURL)
-		if err != nil {
-			return fmt.Errorf("error retrieving image from %s: %v\n", face.ImageURL, err)
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return fmt.Errorf("bad http status code retrieving image from %s: %d\n", *face.ImageURL, resp.StatusCode)
-		}
-		// Decode the image
-		img, _, err := image.Decode(resp.Body)
-		if err != nil {
-			return fmt.Errorf("error decoding image from %s: %w\n", face.ImageURL, err)
-		}
-		// Write the image to our file
-		err = png.Encode(f, img)
-		if err != nil {
-			return fmt.Errorf("error writing image: %w\n", err)
-		}
-	}
-	return nil
+	return DefaultImageCache.Fetch(c)
 }
 
 // NameMatch searches for an exact match between the query string and one of the
@@ -226,6 +170,54 @@ func (c *Card) NameMatch(query string) bool {
 	return false
 }
 
+// Sides returns the side of each of the card's faces, in order, e.g. B and A for Peter Parker and Spider-Man. Sides are
+// taken from the names of the face images, such as 1B.png and 1A.png, falling back on the order of the faces. Cards
+// with a single face have no sides.
+func (c *Card) Sides() []string {
+	if len(c.Faces) < 2 {
+		return nil
+	}
+	sides := make([]string, len(c.Faces))
+	for i, f := range c.Faces {
+		sides[i] = string(rune('A' + i))
+		if f.ImageURL != nil {
+			if m := sideRegexp.FindStringSubmatch(*f.ImageURL); m != nil {
+				sides[i] = strings.ToUpper(m[1])
+			}
+		}
+	}
+	return sides
+}
+
+// WithSide returns a copy of the card with only the face of the given side, e.g. WithSide("A") for the hero side of
+// Spider-Man. Sides are matched case-insensitively. The card itself is returned if it has no face of that side.
+func (c *Card) WithSide(side string) *Card {
+	for i, s := range c.Sides() {
+		if strings.EqualFold(s, side) {
+			copied := *c
+			copied.Faces = []*Face{c.Faces[i]}
+			copied.Side = s
+			return &copied
+		}
+	}
+	return c
+}
+
+// NextSide returns the side following the given one in the order of the faces, wrapping around to the first, e.g. A
+// after B and B after A. The first side is returned if the card has no face of the given side.
+func (c *Card) NextSide(side string) string {
+	sides := c.Sides()
+	if len(sides) == 0 {
+		return ""
+	}
+	for i, s := range sides {
+		if strings.EqualFold(s, side) {
+			return sides[(i+1)%len(sides)]
+		}
+	}
+	return sides[0]
+}
+
 // IsScheme returns whether the card face is a scheme or not.
 func (f *Face) IsScheme() bool {
 	if strings.ToLower(f.Type) == "main scheme" || strings.ToLower(f.Type) == "side scheme" {
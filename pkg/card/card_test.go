@@ -21,10 +21,68 @@ func TestCard_DownloadImages(t *testing.T) {
 		err := tt.input.DownloadImages()
 		// TODO - refactor these after sentinel error types exist
 		if err != nil && tt.err == false {
-			t.Errorf("%s: unexpected error: %w", tt.name, tt.err)
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
 		}
 		if err == nil && tt.err == true {
-			t.Errorf("%s: missing error: %w", tt.name, tt.err)
+			t.Errorf("%s: missing error", tt.name)
 		}
 	}
 }
+
+func TestCard_Sides(t *testing.T) {
+	url := func(s string) *string {
+		s = "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/" + s
+		return &s
+	}
+	spiderMan := &Card{
+		Names: []string{"Spider-Man", "Peter Parker"},
+		Faces: []*Face{
+			{Name: "Peter Parker", ImageURL: url("1B.png")},
+			{Name: "Spider-Man", ImageURL: url("1A.png")},
+		},
+	}
+	antMan := &Card{
+		Names: []string{"Ant-Man", "Scott Lang"},
+		Faces: []*Face{
+			{Name: "Ant-Man", ImageURL: url("1B.png")},
+			{Name: "Scott Lang", ImageURL: url("1A.png")},
+			{Name: "Giant-Man", ImageURL: url("1C.png")},
+		},
+	}
+	homebrew := &Card{
+		Names: []string{"Determination"},
+		Faces: []*Face{{Name: "Determination"}, {Name: "Determination (Back)"}},
+	}
+	lockjaw := &Card{
+		Names: []string{"Lockjaw"},
+		Faces: []*Face{{Name: "Lockjaw", ImageURL: url("18.png")}},
+	}
+
+	var testCases = []struct {
+		name  string
+		input *Card
+		side  string
+		face  string
+		next  string
+	}{
+		{name: "Hero side", input: spiderMan, side: "A", face: "Spider-Man", next: "B"},
+		{name: "Alter-ego side, lower case", input: spiderMan, side: "b", face: "Peter Parker", next: "A"},
+		{name: "Sides follow the image names", input: antMan, side: "A", face: "Scott Lang", next: "C"},
+		{name: "Last side wraps around", input: antMan, side: "C", face: "Giant-Man", next: "B"},
+		{name: "Sides fall back on face order", input: homebrew, side: "B", face: "Determination (Back)", next: "A"},
+		{name: "Single-sided cards are returned whole", input: lockjaw, side: "B", face: "Lockjaw", next: ""},
+	}
+
+	for _, tt := range testCases {
+		c := tt.input.WithSide(tt.side)
+		if len(c.Faces) != 1 || c.Faces[0].Name != tt.face {
+			t.Errorf("%s: expected only %s, got %d faces", tt.name, tt.face, len(c.Faces))
+		}
+		if next := tt.input.NextSide(tt.side); next != tt.next {
+			t.Errorf("%s: expected next side %q, got %q", tt.name, tt.next, next)
+		}
+	}
+	if len(spiderMan.Faces) != 2 {
+		t.Errorf("expected WithSide not to modify the card")
+	}
+}
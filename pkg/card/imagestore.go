@@ -0,0 +1,243 @@
+package card
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ImageBaseURL is the bucket the card data's image URLs point to. A mirror of the bucket, whether a local directory or
+// another HTTP server, can be used as an image source in its place.
+const ImageBaseURL = "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/"
+
+// ErrImageNotFound is returned by an ImageStore that does not have an image.
+var ErrImageNotFound = errors.New("image not found")
+
+// ImageStore is a place card images can be read from and, for stores that are not read-only, written to. Keys are
+// slash-separated paths such as mc01en/1A.png.
+type ImageStore interface {
+	// Has returns whether the store has an image for the key.
+	Has(key string) bool
+	// Get returns the image for the key, or an error wrapping ErrImageNotFound.
+	Get(key string) ([]byte, error)
+	// Put saves the image for the key.
+	Put(key string, data []byte) error
+}
+
+// OpenImageStore returns the ImageStore for a location from the config file: "memory" for an in-memory store, an HTTP
+// or HTTPS URL for an HTTPStore, or otherwise a local directory.
+func OpenImageStore(location string, client *http.Client) ImageStore {
+	switch {
+	case location == "memory":
+		return NewMemoryStore()
+	case strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://"):
+		return NewHTTPStore(location, client)
+	default:
+		return NewDirStore(location)
+	}
+}
+
+// ImageKey returns the key a face's image is cached under. The key is a hash of the image URL, so that images sharing a
+// file name, such as the 1A.png of every hero pack, never collide whichever pack the card is listed under.
+func ImageKey(f *Face) (string, error) {
+	if f.ImageURL == nil || *f.ImageURL == "" {
+		return "", fmt.Errorf("no images for %s", f.Name)
+	}
+	sum := sha256.Sum256([]byte(*f.ImageURL))
+	ext := strings.ToLower(path.Ext(*f.ImageURL))
+	if ext == "" {
+		ext = ".png"
+	}
+	return hex.EncodeToString(sum[:16]) + ext, nil
+}
+
+// SourceKey returns the key of a face's image in an image source. Images in the bucket are keyed by their path within
+// it, e.g. mc01en/1A.png, so that a mirror can serve them. Any other URL is used as the key as is.
+func SourceKey(f *Face) (string, error) {
+	if f.ImageURL == nil || *f.ImageURL == "" {
+		return "", fmt.Errorf("no images for %s", f.Name)
+	}
+	return strings.TrimPrefix(*f.ImageURL, ImageBaseURL), nil
+}
+
+// DirStore is an ImageStore backed by a local directory.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore creates a DirStore that keeps images under dir.
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{dir: dir}
+}
+
+// path returns the file path for a key. Keys are cleaned so that they cannot refer to files outside of the directory.
+func (ds *DirStore) path(key string) string {
+	return filepath.Join(ds.dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+// Has returns whether the directory has a non-empty file for the key.
+func (ds *DirStore) Has(key string) bool {
+	fi, err := os.Stat(ds.path(key))
+	return err == nil && fi.Size() > 0
+}
+
+// Get reads the file for the key.
+func (ds *DirStore) Get(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(ds.path(key))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", key, ErrImageNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading image %s: %v", key, err)
+	}
+	return data, nil
+}
+
+// Put writes the file for the key atomically, so that readers never see a partially written image.
+func (ds *DirStore) Put(key string, data []byte) error {
+	return writeFileAtomic(ds.path(key), data)
+}
+
+// HTTPStore is a read-only ImageStore that retrieves images from an HTTP server. Keys are resolved relative to the
+// base URL, unless they are already a full URL.
+type HTTPStore struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPStore creates an HTTPStore for the server at baseURL.
+func NewHTTPStore(baseURL string, client *http.Client) *HTTPStore {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &HTTPStore{
+		baseURL: baseURL,
+		client:  client,
+	}
+}
+
+// url returns the URL of the image for the key.
+func (hs *HTTPStore) url(key string) string {
+	if strings.HasPrefix(key, "http://") || strings.HasPrefix(key, "https://") {
+		return key
+	}
+	return hs.baseURL + strings.TrimPrefix(key, "/")
+}
+
+// Has returns whether the server responds to a HEAD request for the image.
+func (hs *HTTPStore) Has(key string) bool {
+	resp, err := hs.client.Head(hs.url(key))
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Get retrieves the image from the server.
+func (hs *HTTPStore) Get(key string) ([]byte, error) {
+	url := hs.url(key)
+	resp, err := hs.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving image from %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden:
+		// S3 responds with 403 for objects that do not exist in a bucket that cannot be listed
+		return nil, fmt.Errorf("%s: %w", url, ErrImageNotFound)
+	default:
+		return nil, fmt.Errorf("bad http status code retrieving image from %s: %d", url, resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image from %s: %v", url, err)
+	}
+	return data, nil
+}
+
+// Put always fails, as an HTTPStore is read-only.
+func (hs *HTTPStore) Put(key string, data []byte) error {
+	return fmt.Errorf("unable to save image %s: %s is read-only", key, hs.baseURL)
+}
+
+// MemoryStore is an ImageStore that keeps images in memory. It is useful for tests, and for running the bot without a
+// writable disk.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	images map[string][]byte
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		images: map[string][]byte{},
+	}
+}
+
+// Has returns whether the store has an image for the key.
+func (ms *MemoryStore) Has(key string) bool {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	_, ok := ms.images[key]
+	return ok
+}
+
+// Get returns the image for the key.
+func (ms *MemoryStore) Get(key string) ([]byte, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	data, ok := ms.images[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, ErrImageNotFound)
+	}
+	return data, nil
+}
+
+// Put saves the image for the key.
+func (ms *MemoryStore) Put(key string, data []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.images[key] = data
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as path and renames it into place, so that
+// readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unable to create directory %s: %v", dir, err)
+	}
+	tmp, err := ioutil.TempFile(dir, ".download-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file in %s: %v", dir, err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing image %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing image %s: %v", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing image %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing image %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package card
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// KnownKeywords are the names of the keywords that may appear in Face.Keywords, e.g. Retaliate for "Retaliate 1" or
+// Uses for "Uses (3 energy)".
+var KnownKeywords = []string{
+	"Assault",
+	"Guard",
+	"Hinder",
+	"Incite",
+	"Linked",
+	"Overkill",
+	"Patrol",
+	"Peril",
+	"Permanent",
+	"Piercing",
+	"Quickstrike",
+	"Ranged",
+	"Restricted",
+	"Retaliate",
+	"Setup",
+	"Stalwart",
+	"Steady",
+	"Surge",
+	"Team-Up",
+	"Teamwork",
+	"Toughness",
+	"Uses",
+	"Victory",
+	"Villainous",
+}
+
+// keywordRegexp matches a keyword such as "Retaliate 1", "Uses (3 energy)" or "Team-Up (Spider-Woman and Black
+// Widow)". MarvelCDB spells Team-Up without the hyphen, and marks values per player with [per_hero].
+var keywordRegexp = regexp.MustCompile(
+	`^([A-Za-z][A-Za-z-]*(?: [Uu]p)?)(?: ([0-9]+)(?:\[per_hero\])?)?(?: \(([0-9]+ )?([^()]*)\))?$`)
+
+// Keyword is a keyword of a card face, along with any value it takes. In the card data, a keyword is written the way it
+// is printed on the card, e.g. "Retaliate 1", and so the Value of "Uses (3 energy)" is 3 and its Qualifier is energy.
+type Keyword struct {
+	Name      string
+	Value     *int   // The X of keywords like Retaliate X or Victory X.
+	Qualifier string // The text in parentheses of keywords like Uses or Team-Up, without the value.
+}
+
+// ParseKeyword parses a keyword written the way it is printed on a card. If s is not a keyword, ok is false and the
+// returned Keyword has all of s as its name.
+func ParseKeyword(s string) (keyword Keyword, ok bool) {
+	s = strings.TrimSpace(s)
+	m := keywordRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return Keyword{Name: s}, false
+	}
+	keyword.Name = strings.Replace(m[1], " ", "-", 1)
+	for _, known := range KnownKeywords {
+		if strings.EqualFold(known, keyword.Name) {
+			keyword.Name = known
+			ok = true
+		}
+	}
+	for _, value := range []string{m[2], strings.TrimSpace(m[3])} {
+		if value != "" {
+			n, _ := strconv.Atoi(value)
+			keyword.Value = &n
+		}
+	}
+	keyword.Qualifier = strings.TrimSpace(m[4])
+	return keyword, ok
+}
+
+// String formats the keyword the way it is printed on a card.
+func (k Keyword) String() string {
+	var value string
+	if k.Value != nil {
+		value = strconv.Itoa(*k.Value)
+	}
+	switch {
+	case k.Qualifier != "" && value != "":
+		return k.Name + " (" + value + " " + k.Qualifier + ")"
+	case k.Qualifier != "":
+		return k.Name + " (" + k.Qualifier + ")"
+	case value != "":
+		return k.Name + " " + value
+	}
+	return k.Name
+}
+
+// MarshalYAML writes the keyword as a string, e.g. "Retaliate 1".
+func (k Keyword) MarshalYAML() (interface{}, error) {
+	return k.String(), nil
+}
+
+// UnmarshalYAML reads a keyword written as a string. Unknown keywords are kept, so that they can be reported by a
+// Validator.
+func (k *Keyword) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	*k, _ = ParseKeyword(s)
+	return nil
+}
+
+// MarshalJSON writes the keyword as a string, e.g. "Retaliate 1".
+func (k Keyword) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+// UnmarshalJSON reads a keyword written as a string.
+func (k *Keyword) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*k, _ = ParseKeyword(s)
+	return nil
+}
+
+// KeywordStrings returns the face's keywords the way they are printed on the card.
+func (f *Face) KeywordStrings() []string {
+	keywords := make([]string, 0, len(f.Keywords))
+	for _, keyword := range f.Keywords {
+		keywords = append(keywords, keyword.String())
+	}
+	return keywords
+}
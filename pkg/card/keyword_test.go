@@ -0,0 +1,73 @@
+package card
+
+import (
+	"gopkg.in/yaml.v3"
+	"strings"
+	"testing"
+)
+
+func TestParseKeyword(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		input     string
+		keyword   string
+		value     int // 0 for no value
+		qualifier string
+		ok        bool
+	}{
+		{name: "Plain keyword", input: "Guard", keyword: "Guard", ok: true},
+		{name: "Keyword with a value", input: "Retaliate 1", keyword: "Retaliate", value: 1, ok: true},
+		{name: "Value per player", input: "Hinder 2[per_hero]", keyword: "Hinder", value: 2, ok: true},
+		{name: "Uses", input: "Uses (3 energy counters)", keyword: "Uses", value: 3, qualifier: "energy counters", ok: true},
+		{name: "Team-Up", input: "Team-Up (Spider-Woman and Black Widow)", keyword: "Team-Up",
+			qualifier: "Spider-Woman and Black Widow", ok: true},
+		{name: "Team Up without a hyphen", input: "Team Up (Ant-Man and Wasp)", keyword: "Team-Up",
+			qualifier: "Ant-Man and Wasp", ok: true},
+		{name: "Lowercase", input: "toughness", keyword: "Toughness", ok: true},
+		{name: "Unknown keyword", input: "Flying", keyword: "Flying"},
+		{name: "Not a keyword", input: "Give an ally Guard", keyword: "Give an ally Guard"},
+	}
+
+	for _, tt := range testCases {
+		keyword, ok := ParseKeyword(tt.input)
+		if ok != tt.ok {
+			t.Errorf("%s: expected ok to be %v", tt.name, tt.ok)
+		}
+		if keyword.Name != tt.keyword {
+			t.Errorf("%s: expected name %q, got %q", tt.name, tt.keyword, keyword.Name)
+		}
+		value := 0
+		if keyword.Value != nil {
+			value = *keyword.Value
+		}
+		if value != tt.value {
+			t.Errorf("%s: expected value %d, got %d", tt.name, tt.value, value)
+		}
+		if keyword.Qualifier != tt.qualifier {
+			t.Errorf("%s: expected qualifier %q, got %q", tt.name, tt.qualifier, keyword.Qualifier)
+		}
+	}
+}
+
+func TestKeyword_YAML(t *testing.T) {
+	input := "keywords:\n    - Guard\n    - Retaliate 1\n    - Uses (3 energy)\n"
+	face := &Face{}
+	if err := yaml.Unmarshal([]byte(input), face); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(face.KeywordStrings(), ", "); got != "Guard, Retaliate 1, Uses (3 energy)" {
+		t.Errorf("expected keywords to be read as they are printed, got %q", got)
+	}
+
+	var out struct {
+		Keywords []Keyword `yaml:"keywords"`
+	}
+	out.Keywords = face.Keywords
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != input {
+		t.Errorf("expected keywords to be written as they are printed, got %q", data)
+	}
+}
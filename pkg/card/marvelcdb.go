@@ -3,34 +3,11 @@ package card
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 )
 
-var mcdbKeywords = []string{
-	"Guard.",
-	"Hinder",
-	"Incite",
-	"Overkill.",
-	"Patrol.",
-	"Peril.",
-	"Permanent.",
-	"Piercing.",
-	"Quickstrike.",
-	"Ranged.",
-	"Restricted.",
-	"Retaliate",
-	"Setup.",
-	"Stalwart.",
-	"Surge.",
-	"Team-Up.",
-	"Team Up",
-	"Toughness.",
-	"Uses",
-	"Victory",
-	"Villainous.",
-}
-
 // MarvelCDBCard implements the structure of card JSON objects returned by the MarvelCDB API.
 type MarvelCDBCard struct {
 	PackCode              string  `json:"pack_code"`
@@ -57,188 +34,296 @@ type MarvelCDBCard struct {
 	HealthPerHero         bool    `json:"health_per_hero"`
 	Thwart                *int    `json:"thwart"`
 	ThwartStar            *bool
-	ThwartText            *string `json:"thwart_text"`
-	ThwartCost            *int    `json:"thwart_cost"`
-	Scheme                *int    `json:"scheme"`
-	SchemeText            *string `json:"scheme_text"`
-	Attack                *int    `json:"attack"`
-	AttackText            *string `json:"attack_text"`
-	AttackCost            *int    `json:"attack_cost"`
-	Defense               *int    `json:"defense"`
-	DefenseText           *string `json:"defense_text"`
-	DefenseCost           *int    `json:"defense_cost"`
-	Recover               *int    `json:"recover"`
-	BaseThreat            *int    `json:"base_threat"`
-	BaseThreatFixed       *bool   `json:"base_threat_fixed"`
-	EscalationThreat      *int    `json:"escalation_threat"`
-	EscalationThreatFixed *bool   `json:"escalation_threat_fixed"`
-	Threat                *int    `json:"threat"`
-	ThreatFixed           *bool   `json:"threat_fixed"`
-	DeckLimit             int     `json:"deck_limit"`
-	HandSize              *int    `json:hand_size`
-	Traits                *string `json:"traits"`
-	RealTraits            string  `json:"real_traits"`
-	Flavor                *string `json:"flavor"`
-	Boost                 *int    `json:"boost"`
-	BoostText             *string `json:"boost_text"`
-	Health                *int    `json:"health"`
-	IsUnique              bool    `json:"is_unique"`
-	Hidden                bool    `json:"hidden"`
-	DoubleSided           bool    `json:"double_sided"`
-	BackText              *string `json:"back_text"`
-	BackFlavor            *string `json:"back_flavor"`
-	OCTGNId               string  `json:"octgn_id"`
-	URL                   *string `json:"url"`
-	ImageSrc              string  `json:"imagesrc"`
-	Spoiler               int     `json:"spoiler"`
-	BackImageSrc          string  `json:"backimagesrc"`
+	ThwartText            *string        `json:"thwart_text"`
+	ThwartCost            *int           `json:"thwart_cost"`
+	Scheme                *int           `json:"scheme"`
+	SchemeText            *string        `json:"scheme_text"`
+	Attack                *int           `json:"attack"`
+	AttackText            *string        `json:"attack_text"`
+	AttackCost            *int           `json:"attack_cost"`
+	Defense               *int           `json:"defense"`
+	DefenseText           *string        `json:"defense_text"`
+	DefenseCost           *int           `json:"defense_cost"`
+	Recover               *int           `json:"recover"`
+	BaseThreat            *int           `json:"base_threat"`
+	BaseThreatFixed       *bool          `json:"base_threat_fixed"`
+	EscalationThreat      *int           `json:"escalation_threat"`
+	EscalationThreatFixed *bool          `json:"escalation_threat_fixed"`
+	Threat                *int           `json:"threat"`
+	ThreatFixed           *bool          `json:"threat_fixed"`
+	DeckLimit             int            `json:"deck_limit"`
+	HandSize              *int           `json:"hand_size"`
+	Traits                *string        `json:"traits"`
+	RealTraits            string         `json:"real_traits"`
+	Flavor                *string        `json:"flavor"`
+	Boost                 *int           `json:"boost"`
+	BoostText             *string        `json:"boost_text"`
+	Health                *int           `json:"health"`
+	IsUnique              bool           `json:"is_unique"`
+	Hidden                bool           `json:"hidden"`
+	DoubleSided           bool           `json:"double_sided"`
+	BackText              *string        `json:"back_text"`
+	BackFlavor            *string        `json:"back_flavor"`
+	OCTGNId               string         `json:"octgn_id"`
+	URL                   *string        `json:"url"`
+	ImageSrc              string         `json:"imagesrc"`
+	Spoiler               int            `json:"spoiler"`
+	BackImageSrc          string         `json:"backimagesrc"`
+	BackName              *string        `json:"back_name"`
+	LinkedToCode          string         `json:"linked_to_code"`
+	LinkedCard            *MarvelCDBCard `json:"linked_card"`
 }
 
-// Convert converts a MarvelCDBCard into our Card.
-func (mcdb *MarvelCDBCard) Convert() *Card {
-	card := &Card{}
-	card.Names = append(card.Names, mcdb.Name)
-	pack := &Pack{}
-	// Rotate schemes and side schemes
-	if mcdb.TypeCode == "main_scheme" || mcdb.TypeCode == "side_scheme" {
-		card.Horizontal = true
-	}
-	switch mcdb.PackName {
-	case "Core Set":
-		pack.Name = "Core Set"
-		pack.SKU = "MC01en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "The Green Goblin":
-		pack.Name = "The Green Goblin"
-		pack.SKU = "MC02en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "The Wrecking Crew":
-		pack.Name = "The Wrecking Crew"
-		pack.SKU = "MC03en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Captain America":
-		pack.Name = "Captain America"
-		pack.SKU = "MC04en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Ms. Marvel":
-		pack.Name = "Ms. Marvel"
-		pack.SKU = "MC05en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Thor":
-		pack.Name = "Thor"
-		pack.SKU = "MC06en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Black Widow":
-		pack.Name = "Black Widow"
-		pack.SKU = "MC07en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Doctor Strange":
-		pack.Name = "Dr. Strange"
-		pack.SKU = "MC08en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Hulk":
-		pack.Name = "Hulk"
-		pack.SKU = "MC09en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "The Rise of Red Skull":
-		pack.Name = "The Rise of Red Skull"
-		pack.SKU = "MC10en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "The Once and Future Kang":
-		pack.Name = "The Once and Future Kang"
-		pack.SKU = "MC11en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Ant-man":
-		pack.Name = "Ant-Man"
-		pack.SKU = "MC12en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Wasp":
-		pack.Name = "Wasp"
-		pack.SKU = "MC13en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Quicksilver":
-		pack.Name = "Quicksilver"
-		pack.SKU = "MC14en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Scarlet Witch":
-		pack.Name = "Scarlet Witch"
-		pack.SKU = "MC15en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Galaxy's Most Wanted":
-		pack.Name = "Galaxy’s Most Wanted"
-		pack.SKU = "MC16en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Star-Lord":
-		pack.Name = "Star-Lord"
-		pack.SKU = "MC17en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Gamora":
-		pack.Name = "Gamora"
-		pack.SKU = "MC18en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Drax":
-		pack.Name = "Drax"
-		pack.SKU = "MC19en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	case "Venom":
-		pack.Name = "Venom"
-		pack.SKU = "MC20en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	// PnP cards
-	case "Ronan Modular Set":
-		pack.Name = "Ronan Modular Set"
-		pack.SKU = "PNP01en"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
-	default:
-		pack.Name = mcdb.PackName
-		pack.SKU = "Unknown"
-		pack.Position = &mcdb.Position
-		pack.Quantity = &mcdb.Quantity
+// Convert converts a MarvelCDBCard into our Card. The card's pack is looked up in the registry by its MarvelCDB pack
+// code or name, and packs missing from the registry are given the SKU "Unknown". A double-sided record, such as a main
+// scheme, is given a face for each side. Cards that MarvelCDB splits into a record per side should be converted
+// together with ConvertCards instead.
+func (mcdb *MarvelCDBCard) Convert(packs *PackRegistry) *Card {
+	card := mcdb.newCard(packs)
+	sku := card.Packs[0].SKU
+	front, back := mcdb.sides()
+	card.Faces = append(card.Faces, mcdb.convertFace(sku, front, false))
+	if mcdb.DoubleSided {
+		card.Faces = append(card.Faces, mcdb.convertFace(sku, back, true))
+	}
+	finishCard(card)
+	return card
+}
+
+// ConvertCards converts MarvelCDB cards into our Cards, merging the records MarvelCDB keeps for each side of a single
+// physical card. Records are merged when they are linked, like a hero and their alter-ego, or when they share a code
+// but for the side and are double-sided, like the A and B sides of The Collector. Records that only share a code, such
+// as the five Wakanda Forever! cards, remain separate cards.
+func ConvertCards(mcdbCards []*MarvelCDBCard, packs *PackRegistry) (cards []*Card) {
+	// Linked records may only appear within the record they are linked to
+	records := []*MarvelCDBCard{}
+	byCode := map[string]*MarvelCDBCard{}
+	var add func(mcdb *MarvelCDBCard)
+	add = func(mcdb *MarvelCDBCard) {
+		if mcdb == nil || byCode[mcdb.Code] != nil {
+			return
+		}
+		byCode[mcdb.Code] = mcdb
+		records = append(records, mcdb)
+		add(mcdb.LinkedCard)
+	}
+	for _, mcdb := range mcdbCards {
+		add(mcdb)
+	}
+
+	// Group the records of each physical card together, keyed by one of their codes
+	group := map[string]string{}
+	var find func(code string) string
+	find = func(code string) string {
+		if parent, ok := group[code]; ok && parent != code {
+			group[code] = find(parent)
+			return group[code]
+		}
+		return code
+	}
+	join := func(a string, b string) {
+		if byCode[a] != nil && byCode[b] != nil {
+			group[find(a)] = find(b)
+		}
+	}
+	bases := map[string][]*MarvelCDBCard{}
+	for _, mcdb := range records {
+		join(mcdb.Code, mcdb.LinkedToCode)
+		if mcdb.LinkedCard != nil {
+			join(mcdb.Code, mcdb.LinkedCard.Code)
+		}
+		if base, side := splitCode(mcdb.Code); side != "" {
+			bases[base] = append(bases[base], mcdb)
+		}
+	}
+	for _, sides := range bases {
+		doubleSided := false
+		for _, mcdb := range sides {
+			doubleSided = doubleSided || mcdb.DoubleSided
+		}
+		for _, mcdb := range sides[1:] {
+			if doubleSided {
+				join(mcdb.Code, sides[0].Code)
+			}
+		}
+	}
+
+	// Convert each group in the order its first record appeared
+	groups := map[string][]*MarvelCDBCard{}
+	order := []string{}
+	for _, mcdb := range records {
+		key := find(mcdb.Code)
+		if groups[key] == nil {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], mcdb)
+	}
+	for _, key := range order {
+		sides := groups[key]
+		if len(sides) == 1 {
+			cards = append(cards, sides[0].Convert(packs))
+			continue
+		}
+		sort.SliceStable(sides, func(i, j int) bool {
+			return sides[i].Code < sides[j].Code
+		})
+		card := sides[0].newCard(packs)
+		for _, mcdb := range sides {
+			_, side := splitCode(mcdb.Code)
+			card.Faces = append(card.Faces, mcdb.convertFace(card.Packs[0].SKU, side, false))
+		}
+		finishCard(card)
+		cards = append(cards, card)
 	}
+	return cards
+}
 
+// newCard creates a Card with the pack and set of the record, but no faces.
+func (mcdb *MarvelCDBCard) newCard(packs *PackRegistry) *Card {
+	card := &Card{}
+	pack := &Pack{
+		Name:     mcdb.PackName,
+		SKU:      "Unknown",
+		Position: &mcdb.Position,
+		Quantity: &mcdb.Quantity,
+	}
+	info := packs.ByCode(mcdb.PackCode)
+	if info == nil {
+		info = packs.ByName(mcdb.PackName)
+	}
+	if info != nil {
+		pack.Name = info.Name
+		pack.SKU = info.SKU
+	}
 	card.Packs = append(card.Packs, pack)
+	if mcdb.SetName != "" {
+		card.Sets = append(card.Sets, &Set{Name: mcdb.SetName})
+	}
+	return card
+}
+
+// sides returns the sides of the record's front and back, e.g. A and B. Main schemes without a side in their code are
+// the exception, as MarvelCDB describes their B side on the front and their A side on the back. The sides are empty for
+// a single-sided card.
+func (mcdb *MarvelCDBCard) sides() (front string, back string) {
+	if _, side := splitCode(mcdb.Code); side != "" {
+		if side == "A" {
+			return side, "B"
+		}
+		return side, "A"
+	}
+	if !mcdb.DoubleSided {
+		return "", ""
+	}
+	if mcdb.TypeCode == "main_scheme" {
+		return "B", "A"
+	}
+	return "A", "B"
+}
+
+// splitCode splits a MarvelCDB code such as 01001a into the code shared by every side of the card, 01001, and the side,
+// A. The side is empty for a code without one.
+func splitCode(code string) (base string, side string) {
+	if code == "" {
+		return code, ""
+	}
+	last := rune(code[len(code)-1])
+	if !unicode.IsLetter(last) {
+		return code, ""
+	}
+	return code[:len(code)-1], strings.ToUpper(string(last))
+}
 
+// finishCard sorts the faces of a converted card, alter-egos first and then by side, in the order of the card data,
+// e.g. Peter Parker and then Spider-Man. The card's names are then taken from its faces, A side first, and schemes are
+// rotated.
+func finishCard(card *Card) {
+	side := func(f *Face) string {
+		if f.ImageURL != nil {
+			if m := sideRegexp.FindStringSubmatch(*f.ImageURL); m != nil {
+				return strings.ToUpper(m[1])
+			}
+		}
+		return ""
+	}
+	bySide := make([]*Face, len(card.Faces))
+	copy(bySide, card.Faces)
+	sort.SliceStable(bySide, func(i, j int) bool {
+		return side(bySide[i]) < side(bySide[j])
+	})
+	for _, f := range bySide {
+		if !card.NameMatch(f.Name) {
+			card.Names = append(card.Names, f.Name)
+		}
+		switch typeName(f.Type) {
+		case "main scheme", "side scheme":
+			card.Horizontal = true
+		}
+	}
+	sort.SliceStable(bySide, func(i, j int) bool {
+		return typeName(bySide[i].Type) == "alter ego" && typeName(bySide[j].Type) != "alter ego"
+	})
+	card.Faces = bySide
+}
+
+// convertFace converts one side of the record into a Face. Side is the side of the face's image, if any, such as the A
+// of 1A.png. MarvelCDB only records the name, text and flavor text of the back of a double-sided record, so the back
+// is given those and the details shared by both sides, while the stats belong to the front.
+func (mcdb *MarvelCDBCard) convertFace(sku string, side string, back bool) *Face {
 	face := &Face{}
 	// Name
 	face.Name = mcdb.Name
+	if back && mcdb.BackName != nil && *mcdb.BackName != "" {
+		face.Name = *mcdb.BackName
+	}
 	// Subtitle
 	face.Subtitle = mcdb.Subname
-	// Cost
-	if mcdb.Cost != nil {
-		face.Cost = mcdb.Cost
-	}
 	// Unique
 	face.Unique = mcdb.IsUnique
 	// Type
-	face.Type = func(s string) string {
-		return strings.Title(strings.ReplaceAll(s, "_", " "))
-	}(mcdb.TypeCode)
+	face.Type = strings.Title(strings.ReplaceAll(mcdb.TypeCode, "_", " "))
 	// Aspect
 	switch mcdb.FactionName {
 	case "Aggression", "Basic", "Justice", "Leadership", "Protection":
 		face.Aspect = append(face.Aspect, mcdb.FactionName)
 	}
+	// Traits
+	if mcdb.Traits != nil {
+		face.Traits = parseTraits(*mcdb.Traits)
+	}
+	// Text
+	text, flavor := mcdb.Text, mcdb.Flavor
+	if back {
+		text, flavor = mcdb.BackText, mcdb.BackFlavor
+	}
+	if text != nil {
+		face.Keywords = parseKeywords(*text)
+		face.Text = func(s string) *string {
+			s = strings.ReplaceAll(s, "<b>", "**")
+			s = strings.ReplaceAll(s, "</b>", "**")
+			s = strings.ReplaceAll(s, "<i>", "_")
+			s = strings.ReplaceAll(s, "</i>", "_")
+			return &s
+		}(*text)
+	}
+	// Flavor Text
+	face.FlavorText = flavor
+	// Image URL
+	if sku != "Unknown" {
+		imageURL := fmt.Sprintf("%s%s/%d%s.png", ImageBaseURL, strings.ToLower(sku), mcdb.Position, side)
+		face.ImageURL = &imageURL
+	}
+	// MarvelCDB.com URL
+	if mcdb.URL != nil {
+		mcdbUrl := strings.ReplaceAll(*mcdb.URL, "\\", "")
+		face.MarvelCDBURL = &mcdbUrl
+	}
+	if back {
+		return face
+	}
+
+	// Cost
+	face.Cost = mcdb.Cost
 	// RecoverValue
 	face.RecoverValue = mcdb.Recover
 	// SchemeValue
@@ -261,52 +346,6 @@ func (mcdb *MarvelCDBCard) Convert() *Card {
 	face.DefenseValue = mcdb.Defense
 	// DefenseText
 	face.DefenseText = mcdb.DefenseText
-	// Traits
-	if mcdb.Traits != nil {
-		face.Traits = func(s string) []string {
-			var traits = []string{}
-			// We can't use strings.Fields because of traits like "Accuser Corps"
-			rawTraits := strings.SplitAfter(s, ". ")
-			// Trim trailing period if it is not an acronym like "S.H.I.E.L.D."
-			for _, trait := range rawTraits {
-				trait = strings.TrimSpace(trait)
-				periodSearch := regexp.MustCompile(`\.`)
-				matches := periodSearch.FindAllStringIndex(trait, -1)
-				if len(matches) == 1 {
-					trait = strings.TrimRight(trait, ".")
-					traits = append(traits, trait)
-				}
-			}
-			return traits
-		}(*mcdb.Traits)
-	}
-	// Keywords
-	// Keywords are tricky, because MarvelCDB doesn't actually track them, but we do.
-	keywords := []string{}
-	if mcdb.Text != nil {
-		for _, keyword := range mcdbKeywords {
-			if strings.Contains(*mcdb.Text, keyword) {
-				keyword = strings.TrimRight(keyword, ".")
-				if keyword == "Team Up" {
-					keyword = "Team-Up"
-				}
-				keywords = append(keywords, keyword)
-			}
-		}
-	}
-	if len(keywords) > 0 {
-		face.Keywords = keywords
-	}
-	// Text
-	if mcdb.Text != nil {
-		face.Text = func(s string) *string {
-			s = strings.ReplaceAll(s, "<b>", "**")
-			s = strings.ReplaceAll(s, "</b>", "**")
-			s = strings.ReplaceAll(s, "<i>", "_")
-			s = strings.ReplaceAll(s, "</i>", "_")
-			return &s
-		}(*mcdb.Text)
-	}
 	// Hand Size
 	face.HandSize = mcdb.HandSize
 	// Hit Points
@@ -317,30 +356,11 @@ func (mcdb *MarvelCDBCard) Convert() *Card {
 	if mcdb.Health != nil && mcdb.HealthPerHero == true {
 		face.HitPointsPerPlayer = mcdb.Health
 	}
-	// Starting Threat
-	if mcdb.BaseThreat != nil && *mcdb.BaseThreatFixed == true {
-		face.StartingThreat = mcdb.BaseThreat
-	}
-	// Starting Threat Per Player
-	if mcdb.BaseThreat != nil && *mcdb.BaseThreatFixed == false {
-		face.StartingThreatPerPlayer = mcdb.BaseThreat
-	}
-	// Acceleration Threat
-	if mcdb.EscalationThreat != nil && *mcdb.EscalationThreatFixed == true {
-		face.AccelerationThreat = mcdb.EscalationThreat
-	}
-	// Acceleration Threat Per Player
-	if mcdb.EscalationThreat != nil && *mcdb.EscalationThreatFixed == false {
-		face.AccelerationThreatPerPlayer = mcdb.EscalationThreat
-	}
-	// Target Threat
-	if mcdb.Threat != nil && *mcdb.ThreatFixed == true {
-		face.TargetThreat = mcdb.Threat
-	}
-	// Target Threat Per Player
-	if mcdb.Threat != nil && *mcdb.ThreatFixed == false {
-		face.TargetThreatPerPlayer = mcdb.Threat
-	}
+	// Threat values, each either fixed or per player
+	face.StartingThreat, face.StartingThreatPerPlayer = splitThreat(mcdb.BaseThreat, mcdb.BaseThreatFixed)
+	face.AccelerationThreat, face.AccelerationThreatPerPlayer =
+		splitThreat(mcdb.EscalationThreat, mcdb.EscalationThreatFixed)
+	face.TargetThreat, face.TargetThreatPerPlayer = splitThreat(mcdb.Threat, mcdb.ThreatFixed)
 	// BoostIcons
 	if mcdb.Boost != nil {
 		face.BoostIcons = mcdb.Boost
@@ -355,241 +375,70 @@ func (mcdb *MarvelCDBCard) Convert() *Card {
 		}
 	}
 	// StarBoost
-	if mcdb.BoostText != nil {
-		face.StarText = mcdb.BoostText
-	}
-	// Flavor Text
-	if mcdb.Flavor != nil {
-		face.FlavorText = mcdb.Flavor
-	}
+	face.StarText = mcdb.BoostText
 	// Resources
-	resources := &Resources{}
 	if mcdb.ResourceEnergy != nil || mcdb.ResourceMental != nil || mcdb.ResourcePhysical != nil || mcdb.ResourceWild != nil {
-		resources.Energy = mcdb.ResourceEnergy
-		resources.Mental = mcdb.ResourceMental
-		resources.Physical = mcdb.ResourcePhysical
-		resources.Wild = mcdb.ResourceWild
-		face.Resources = resources
-	}
-	// Image URL
-	if pack.SKU != "Unknown" {
-		lowerSKU := strings.ToLower(card.Packs[0].SKU)
-		// If the last character of the MCDB URL is a letter, we'll append that
-		var imageURL string
-		if mcdb.URL != nil {
-			mcdbURL := *mcdb.URL
-			lastCharacter := mcdbURL[len(mcdbURL)-1:]
-			if unicode.IsLetter([]rune(lastCharacter)[0]) {
-				imageURL = fmt.Sprintf("https://marvel-champions-cards.s3.us-west-2.amazonaws.com/%s/%d%s.png", lowerSKU, mcdb.Position, strings.ToUpper(lastCharacter))
-			} else {
-				// If it's a main scheme, this is the B side
-				if mcdb.TypeCode != "main_scheme" {
-					imageURL = fmt.Sprintf("https://marvel-champions-cards.s3.us-west-2.amazonaws.com/%s/%d.png", lowerSKU, mcdb.Position)
-				} else {
-					imageURL = fmt.Sprintf("https://marvel-champions-cards.s3.us-west-2.amazonaws.com/%s/%dB.png", lowerSKU, mcdb.Position)
-				}
-			}
+		face.Resources = &Resources{
+			Energy:   mcdb.ResourceEnergy,
+			Mental:   mcdb.ResourceMental,
+			Physical: mcdb.ResourcePhysical,
+			Wild:     mcdb.ResourceWild,
 		}
-		face.ImageURL = &imageURL
 	}
-	// MarvelCDB.com URL
-	if mcdb.URL != nil {
-		mcdbUrl := strings.ReplaceAll(*mcdb.URL, "\\", "")
-		face.MarvelCDBURL = &mcdbUrl
+	return face
+}
+
+// splitThreat returns a MarvelCDB threat value as either a fixed value or a value per player.
+func splitThreat(threat *int, fixed *bool) (value *int, perPlayer *int) {
+	if threat == nil {
+		return nil, nil
+	}
+	if fixed != nil && *fixed {
+		return threat, nil
 	}
-	card.Faces = append(card.Faces, face)
+	return nil, threat
+}
 
-	// If the card is a main scheme, we want both sides
-	if mcdb.DoubleSided == true && mcdb.TypeCode == "main_scheme" {
-		backface := &Face{}
-		// Name
-		backface.Name = mcdb.Name
-		// Subtitle
-		backface.Subtitle = mcdb.Subname
-		// Cost
-		if mcdb.Cost != nil {
-			backface.Cost = mcdb.Cost
-		}
-		// Unique
-		backface.Unique = mcdb.IsUnique
-		// Type
-		backface.Type = func(s string) string {
-			return strings.Title(strings.ReplaceAll(s, "_", " "))
-		}(mcdb.TypeCode)
-		// Aspect
-		switch mcdb.FactionName {
-		case "Aggression", "Basic", "Justice", "Leadership", "Protection":
-			backface.Aspect = append(face.Aspect, mcdb.FactionName)
-		}
-		// RecoverValue
-		backface.RecoverValue = mcdb.Recover
-		// SchemeValue
-		backface.SchemeValue = mcdb.Scheme
-		// SchemeText
-		backface.SchemeText = mcdb.SchemeText
-		// ThwartValue
-		backface.ThwartValue = mcdb.Thwart
-		// ThwartText
-		backface.ThwartText = mcdb.ThwartText
-		// ThwartConsequential
-		backface.ThwartConsequential = mcdb.ThwartCost
-		// AttackValue
-		backface.AttackValue = mcdb.Attack
-		// AttackText
-		backface.AttackText = mcdb.AttackText
-		// AttackConsequential
-		backface.AttackConsequential = mcdb.AttackCost
-		// DefenseValue
-		backface.DefenseValue = mcdb.Defense
-		// DefenseText
-		backface.DefenseText = mcdb.DefenseText
-		// Traits
-		if mcdb.Traits != nil {
-			backface.Traits = func(s string) []string {
-				var traits = []string{}
-				// We can't use strings.Fields because of traits like "Accuser Corps"
-				rawTraits := strings.SplitAfter(s, ". ")
-				// Trim trailing period if it is not an acronym like "S.H.I.E.L.D."
-				for _, trait := range rawTraits {
-					trait = strings.TrimSpace(trait)
-					periodSearch := regexp.MustCompile(`\.`)
-					matches := periodSearch.FindAllStringIndex(trait, -1)
-					if len(matches) == 1 {
-						trait = strings.TrimRight(trait, ".")
-						traits = append(traits, trait)
-					}
-				}
-				return traits
-			}(*mcdb.Traits)
-		}
-		// Keywords
-		// Keywords are tricky, because MarvelCDB doesn't actually track them, but we do.
-		keywords := []string{}
-		if mcdb.Text != nil {
-			for _, keyword := range mcdbKeywords {
-				if strings.Contains(*mcdb.Text, keyword) {
-					keyword = strings.TrimRight(keyword, ".")
-					if keyword == "Team Up" {
-						keyword = "Team-Up"
-					}
-					keywords = append(keywords, keyword)
-				}
-			}
-		}
-		if len(keywords) > 0 {
-			backface.Keywords = keywords
-		}
-		// Text
-		if mcdb.BackText != nil {
-			backface.Text = func(s string) *string {
-				s = strings.ReplaceAll(s, "<b>", "**")
-				s = strings.ReplaceAll(s, "</b>", "**")
-				s = strings.ReplaceAll(s, "<i>", "_")
-				s = strings.ReplaceAll(s, "</i>", "_")
-				return &s
-			}(*mcdb.BackText)
-		}
-		// Hand Size
-		backface.HandSize = mcdb.HandSize
-		// Hit Points
-		if mcdb.Health != nil && mcdb.HealthPerHero == false {
-			backface.HitPoints = mcdb.Health
-		}
-		// Hit Points Per Player
-		if mcdb.Health != nil && mcdb.HealthPerHero == true {
-			backface.HitPointsPerPlayer = mcdb.Health
-		}
-		// Starting Threat
-		if mcdb.BaseThreat != nil && *mcdb.BaseThreatFixed == true {
-			backface.StartingThreat = mcdb.BaseThreat
-		}
-		// Starting Threat Per Player
-		if mcdb.BaseThreat != nil && *mcdb.BaseThreatFixed == false {
-			backface.StartingThreatPerPlayer = mcdb.BaseThreat
-		}
-		// Acceleration Threat
-		if mcdb.EscalationThreat != nil && *mcdb.EscalationThreatFixed == true {
-			backface.AccelerationThreat = mcdb.EscalationThreat
-		}
-		// Acceleration Threat Per Player
-		if mcdb.EscalationThreat != nil && *mcdb.EscalationThreatFixed == false {
-			backface.AccelerationThreatPerPlayer = mcdb.EscalationThreat
-		}
-		// Target Threat
-		if mcdb.Threat != nil && *mcdb.ThreatFixed == true {
-			backface.TargetThreat = mcdb.Threat
-		}
-		// Target Threat Per Player
-		if mcdb.Threat != nil && *mcdb.ThreatFixed == false {
-			backface.TargetThreatPerPlayer = mcdb.Threat
-		}
-		// BoostIcons
-		if mcdb.Boost != nil {
-			backface.BoostIcons = mcdb.Boost
-		} else {
-			// MCDB doesn't differentiate between a card that can't have boost icons, and a card with 0 boost icons - we do
-			if mcdb.FactionCode == "encounter" {
-				switch mcdb.TypeCode {
-				case "attachment", "minion", "obligation", "side_scheme", "treachery":
-					boostIcons := 0
-					backface.BoostIcons = &boostIcons
-				}
-			}
-		}
-		// StarBoost
-		if mcdb.BoostText != nil {
-			backface.StarText = mcdb.BoostText
-		}
-		// Flavor Text
-		if mcdb.BackFlavor != nil {
-			backface.FlavorText = mcdb.BackFlavor
-		}
-		// Resources
-		resources := &Resources{}
-		if mcdb.ResourceEnergy != nil || mcdb.ResourceMental != nil || mcdb.ResourcePhysical != nil || mcdb.ResourceWild != nil {
-			resources.Energy = mcdb.ResourceEnergy
-			resources.Mental = mcdb.ResourceMental
-			resources.Physical = mcdb.ResourcePhysical
-			resources.Wild = mcdb.ResourceWild
-			backface.Resources = resources
-		}
-		// Image URL
-		if pack.SKU != "Unknown" {
-			lowerSKU := strings.ToLower(card.Packs[0].SKU)
-			// If the last character of the MCDB URL is a letter, we'll append that
-			var imageURL string
-			if mcdb.URL != nil {
-				mcdbURL := *mcdb.URL
-				lastCharacter := mcdbURL[len(mcdbURL)-1:]
-				if unicode.IsLetter([]rune(lastCharacter)[0]) {
-					imageURL = fmt.Sprintf("https://marvel-champions-cards.s3.us-west-2.amazonaws.com/%s/%d%s.png", lowerSKU, mcdb.Position, strings.ToUpper(lastCharacter))
-				} else {
-					// If it's a main scheme, this is the B side
-					if mcdb.TypeCode != "main_scheme" {
-						imageURL = fmt.Sprintf("https://marvel-champions-cards.s3.us-west-2.amazonaws.com/%s/%d.png", lowerSKU, mcdb.Position)
-					} else {
-						imageURL = fmt.Sprintf("https://marvel-champions-cards.s3.us-west-2.amazonaws.com/%s/%dA.png", lowerSKU, mcdb.Position)
-					}
-				}
-			}
-			backface.ImageURL = &imageURL
+// htmlTagRegexp matches the HTML tags used to format MarvelCDB card text, e.g. <b> or </i>
+var htmlTagRegexp = regexp.MustCompile(`</?[a-z]+>`)
+
+// parseTraits splits MarvelCDB traits, such as "Avenger. Spy." or "S.H.I.E.L.D. Spy.", into a slice. Each trait ends
+// with a period, which is dropped unless the trait is an acronym like S.H.I.E.L.D.
+func parseTraits(s string) []string {
+	traits := []string{}
+	// We can't use strings.Fields because of traits like "Accuser Corps"
+	for _, trait := range strings.SplitAfter(htmlTagRegexp.ReplaceAllString(s, ""), ". ") {
+		trait = strings.TrimSpace(trait)
+		if !strings.Contains(strings.TrimSuffix(trait, "."), ".") {
+			trait = strings.TrimSuffix(trait, ".")
 		}
-		// MarvelCDB.com URL
-		if mcdb.URL != nil {
-			mcdbUrl := strings.ReplaceAll(*mcdb.URL, "\\", "")
-			backface.MarvelCDBURL = &mcdbUrl
+		if trait != "" {
+			traits = append(traits, trait)
 		}
-		card.Faces = append(card.Faces, backface)
 	}
+	if len(traits) == 0 {
+		return nil
+	}
+	return traits
+}
 
-	/*
-		yamlText, err := yaml.Marshal(card)
-		if err != nil {
-			fmt.Println(err)
-		} else {
-			fmt.Println(string(yamlText))
+// parseKeywords returns the keywords found in MarvelCDB card text.
+// Keywords are tricky, because MarvelCDB doesn't actually track them, but we do. They are printed as sentences of
+// their own at the start of a line, e.g. "Guard. Retaliate 1." or "Uses (3 energy counters). Action: ...", so each
+// line is read up to its first sentence that isn't a keyword. Any other mention of a keyword, as in "Give an ally
+// Guard", is part of the card's abilities.
+func parseKeywords(text string) []Keyword {
+	var keywords []Keyword
+	text = htmlTagRegexp.ReplaceAllString(text, "")
+	for _, line := range strings.Split(text, "\n") {
+		for _, sentence := range strings.SplitAfter(strings.TrimSpace(line), ". ") {
+			sentence = strings.TrimSuffix(strings.TrimSpace(sentence), ".")
+			keyword, ok := ParseKeyword(sentence)
+			if !ok {
+				break
+			}
+			keywords = append(keywords, keyword)
 		}
-	*/
-
-	return card
+	}
+	return keywords
 }
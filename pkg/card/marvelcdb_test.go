@@ -0,0 +1,158 @@
+package card
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testMarvelCDBCards is a trimmed down MarvelCDB dump, covering each way MarvelCDB records double-sided cards.
+const testMarvelCDBCards = `[
+	{"pack_code": "core", "pack_name": "Core Set", "type_code": "hero", "card_set_name": "Spider-Man", "position": 1,
+	 "code": "01001a", "name": "Spider-Man", "linked_to_code": "01001b",
+	 "linked_card": {"pack_code": "core", "pack_name": "Core Set", "type_code": "alter_ego", "card_set_name": "Spider-Man",
+	                 "position": 1, "code": "01001b", "name": "Peter Parker", "linked_to_code": "01001a"}},
+	{"pack_code": "core", "pack_name": "Core Set", "type_code": "event", "faction_name": "Justice", "position": 43,
+	 "code": "01043a", "name": "Wakanda Forever!"},
+	{"pack_code": "core", "pack_name": "Core Set", "type_code": "event", "faction_name": "Justice", "position": 43,
+	 "code": "01043b", "name": "Wakanda Forever!"},
+	{"pack_code": "core", "pack_name": "Core Set", "type_code": "main_scheme", "card_set_name": "Rhino", "position": 97,
+	 "code": "01097", "name": "The Break-In!", "double_sided": true, "text": "Threat side", "back_text": "Setup side",
+	 "base_threat": 0, "base_threat_fixed": true, "threat": 7, "threat_fixed": false},
+	{"pack_code": "gmw", "pack_name": "Galaxy's Most Wanted", "type_code": "villain", "card_set_name": "The Collector",
+	 "position": 80, "code": "16080b", "name": "The Collector", "double_sided": true},
+	{"pack_code": "gmw", "pack_name": "Galaxy's Most Wanted", "type_code": "villain", "card_set_name": "The Collector",
+	 "position": 80, "code": "16080a", "name": "The Collector", "double_sided": true},
+	{"pack_code": "gmw", "pack_name": "Galaxy's Most Wanted", "type_code": "side_scheme", "card_set_name": "Badoon Blitz",
+	 "position": 178, "code": "16178", "name": "Badoon Blitz", "double_sided": true, "back_name": "Badoon Ambush",
+	 "text": "Front", "back_text": "Back"},
+	{"pack_code": "hood", "pack_name": "The Hood", "type_code": "villain", "position": 1, "code": "24001",
+	 "name": "The Hood"}
+]`
+
+func TestConvertCards(t *testing.T) {
+	mcdbCards := []*MarvelCDBCard{}
+	if err := json.Unmarshal([]byte(testMarvelCDBCards), &mcdbCards); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cards := ConvertCards(mcdbCards, testPackRegistry())
+
+	var testCases = []struct {
+		name       string
+		names      []string
+		faces      []string // Face name and image side
+		horizontal bool
+	}{
+		{name: "Linked hero", names: []string{"Spider-Man", "Peter Parker"}, faces: []string{"Peter Parker 1B", "Spider-Man 1A"}},
+		{name: "Separate cards sharing a code", names: []string{"Wakanda Forever!"}, faces: []string{"Wakanda Forever! 43A"}},
+		{name: "Separate cards sharing a code", names: []string{"Wakanda Forever!"}, faces: []string{"Wakanda Forever! 43B"}},
+		{name: "Main scheme", names: []string{"The Break-In!"}, faces: []string{"The Break-In! 97A", "The Break-In! 97B"}, horizontal: true},
+		{name: "Double-sided villain", names: []string{"The Collector"}, faces: []string{"The Collector 80A", "The Collector 80B"}},
+		{name: "Side scheme with a back name", names: []string{"Badoon Blitz", "Badoon Ambush"}, faces: []string{"Badoon Blitz 178A", "Badoon Ambush 178B"}, horizontal: true},
+		{name: "Unknown pack", names: []string{"The Hood"}, faces: []string{"The Hood"}},
+	}
+
+	if len(cards) != len(testCases) {
+		t.Fatalf("expected %d cards, got %d", len(testCases), len(cards))
+	}
+	for i, tt := range testCases {
+		c := cards[i]
+		if strings.Join(c.Names, ", ") != strings.Join(tt.names, ", ") {
+			t.Errorf("%s: expected names %v, got %v", tt.name, tt.names, c.Names)
+		}
+		faces := []string{}
+		for _, f := range c.Faces {
+			face := f.Name
+			if f.ImageURL != nil {
+				face += " " + strings.TrimSuffix((*f.ImageURL)[strings.LastIndex(*f.ImageURL, "/")+1:], ".png")
+			}
+			faces = append(faces, face)
+		}
+		if strings.Join(faces, ", ") != strings.Join(tt.faces, ", ") {
+			t.Errorf("%s: expected faces %v, got %v", tt.name, tt.faces, faces)
+		}
+		if c.Horizontal != tt.horizontal {
+			t.Errorf("%s: expected horizontal to be %v", tt.name, tt.horizontal)
+		}
+	}
+
+	// Each side of a single double-sided record keeps its own text
+	scheme := cards[3]
+	if *scheme.Faces[0].Text != "Setup side" || *scheme.Faces[1].Text != "Threat side" {
+		t.Errorf("Main scheme: expected setup text on the A side and threat text on the B side")
+	}
+	if scheme.Faces[1].TargetThreatPerPlayer == nil || *scheme.Faces[1].TargetThreatPerPlayer != 7 {
+		t.Errorf("Main scheme: expected target threat of 7 per player on the B side")
+	}
+	if scheme.Faces[0].TargetThreatPerPlayer != nil {
+		t.Errorf("Main scheme: expected no threat on the A side")
+	}
+	if scheme.Packs[0].SKU != "MC01en" || cards[6].Packs[0].SKU != "Unknown" {
+		t.Errorf("expected packs to be looked up in the registry")
+	}
+}
+
+// TestParseKeywords runs the keyword parser over a corpus of card text, as formatted by MarvelCDB.
+func TestParseKeywords(t *testing.T) {
+	var testCases = []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "Black Cat", text: "<b>Forced Response</b>: After Black Cat enters play, discard the top 2 cards of your deck.",
+			want: ""},
+		{name: "Hulk ally", text: "<b>Forced Response</b>: After Hulk attacks, discard the top card of your deck.", want: ""},
+		{name: "Shocker", text: "<b>Guard</b>.\n<b>When Revealed</b>: Deal 1 damage to each hero.", want: "Guard"},
+		{name: "Armored Guard", text: "<b>Guard</b>. <b>Toughness</b>.", want: "Guard, Toughness"},
+		{name: "Vulture", text: "<b>Quickstrike</b>. <b>Retaliate 1</b>.", want: "Quickstrike, Retaliate 1"},
+		{name: "Hydra Soldier", text: "<b>Guard</b>.\nHinder 2[per_hero].", want: "Guard, Hinder 2"},
+		{name: "Incite", text: "<b>Incite 1</b>.", want: "Incite 1"},
+		{name: "Uses", text: "<b>Uses (3 energy counters)</b>.\n<b>Action</b>: Spend 1 energy counter → deal 1 damage.",
+			want: "Uses (3 energy counters)"},
+		{name: "Setup on the same line as an ability", text: "<b>Setup</b>. <b>Action</b>: Ready Captain Marvel.",
+			want: "Setup"},
+		{name: "Team-Up", text: "<b>Team-Up (Spider-Woman and Black Widow)</b>.\n<b>Action</b>: Confuse the villain.",
+			want: "Team-Up (Spider-Woman and Black Widow)"},
+		{name: "Surge at the end", text: "<b>When Revealed</b>: Place 1 threat on the main scheme.\n<b>Surge</b>.",
+			want: "Surge"},
+		{name: "Keyword mentioned in an ability", text: "<b>Action</b>: Give an ally Guard until the end of the phase.",
+			want: ""},
+		{name: "Keyword ending an ability", text: "Attach to a minion. That minion gains Retaliate 1.", want: ""},
+		{name: "Restricted and Uses", text: "<b>Restricted</b>.\n<b>Uses (2 charge counters)</b>.",
+			want: "Restricted, Uses (2 charge counters)"},
+		{name: "Victory", text: "<b>Victory 2</b>.", want: "Victory 2"},
+	}
+
+	for _, tt := range testCases {
+		keywords := []string{}
+		for _, keyword := range parseKeywords(tt.text) {
+			keywords = append(keywords, keyword.String())
+		}
+		if got := strings.Join(keywords, ", "); got != tt.want {
+			t.Errorf("%s: expected keywords %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestParseTraits(t *testing.T) {
+	var testCases = []struct {
+		name   string
+		traits string
+		want   string
+	}{
+		{name: "Single trait", traits: "Avenger.", want: "Avenger"},
+		{name: "Multiple traits", traits: "Hero for Hire. Defender.", want: "Hero for Hire, Defender"},
+		{name: "Acronym", traits: "S.H.I.E.L.D.", want: "S.H.I.E.L.D."},
+		{name: "Acronym first", traits: "S.H.I.E.L.D. Spy.", want: "S.H.I.E.L.D., Spy"},
+		{name: "Acronym last", traits: "Elite. A.I.M.", want: "Elite, A.I.M."},
+		{name: "Multi-word trait", traits: "Accuser Corps. Kree.", want: "Accuser Corps, Kree"},
+		{name: "Formatted", traits: "<i>Criminal.</i>", want: "Criminal"},
+		{name: "Empty", traits: "", want: ""},
+	}
+
+	for _, tt := range testCases {
+		if got := strings.Join(parseTraits(tt.traits), ", "); got != tt.want {
+			t.Errorf("%s: expected traits %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
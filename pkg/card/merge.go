@@ -0,0 +1,138 @@
+package card
+
+import (
+	"reflect"
+	"strings"
+)
+
+// MergeCards merges imported cards, such as those converted from MarvelCDB, into cards that have already been curated
+// by hand. An imported card matching an existing card, by sharing a face image or a pack position, is merged into it
+// with MergeCard; any other imported card is added to the end. The existing cards are updated in place.
+func MergeCards(existing []*Card, imported []*Card) []*Card {
+	for _, c := range imported {
+		if match := FindMatch(existing, c); match != nil {
+			MergeCard(match, c)
+			continue
+		}
+		existing = append(existing, c)
+	}
+	return existing
+}
+
+// MergeCard fills in the fields of a card that are missing from the imported card, without replacing any value that is
+// already set. Names, packs and sets are added to the card's own, so that aliases and the like are never lost. Faces
+// are paired up by their image URLs, or by name for faces without images, and imported faces without a pair are only
+// added to a card that has no faces.
+func MergeCard(c *Card, imported *Card) {
+	for _, name := range imported.Names {
+		if !c.NameMatch(name) {
+			c.Names = append(c.Names, name)
+		}
+	}
+	for _, pack := range imported.Packs {
+		if !hasPack(c, pack.SKU) {
+			c.Packs = append(c.Packs, pack)
+		}
+	}
+	for _, set := range imported.Sets {
+		if !hasSet(c, set.Name) {
+			c.Sets = append(c.Sets, set)
+		}
+	}
+	if c.Deck == nil {
+		c.Deck = imported.Deck
+	}
+	if !c.Horizontal {
+		c.Horizontal = imported.Horizontal
+	}
+
+	if len(c.Faces) == 0 {
+		c.Faces = imported.Faces
+		return
+	}
+	for _, face := range imported.Faces {
+		for _, existing := range c.Faces {
+			if sameFace(existing, face) {
+				fillMissing(reflect.ValueOf(existing).Elem(), reflect.ValueOf(face).Elem())
+				break
+			}
+		}
+	}
+}
+
+// FindMatch returns the card that an imported card should be merged into, or nil if there is none.
+func FindMatch(cards []*Card, imported *Card) *Card {
+	for _, c := range cards {
+		for _, face := range c.Faces {
+			for _, importedFace := range imported.Faces {
+				if sameImage(face, importedFace) {
+					return c
+				}
+			}
+		}
+	}
+	// Cards without images, such as those from packs the converter does not know, are matched by position and name
+	for _, c := range cards {
+		if len(c.Faces) == 0 || len(imported.Faces) == 0 {
+			continue
+		}
+		if samePosition(c, imported) && c.Faces[0].Name == imported.Faces[0].Name {
+			return c
+		}
+	}
+	return nil
+}
+
+// sameImage returns whether two faces have the same image.
+func sameImage(a *Face, b *Face) bool {
+	return a.ImageURL != nil && b.ImageURL != nil && *a.ImageURL != "" && strings.EqualFold(*a.ImageURL, *b.ImageURL)
+}
+
+// sameFace returns whether two faces are the same side of a card, by their image or, if neither has an image, by name.
+func sameFace(a *Face, b *Face) bool {
+	if a.ImageURL == nil && b.ImageURL == nil {
+		return a.Name == b.Name
+	}
+	return sameImage(a, b)
+}
+
+// samePosition returns whether two cards share a position in the same pack.
+func samePosition(a *Card, b *Card) bool {
+	for _, pa := range a.Packs {
+		for _, pb := range b.Packs {
+			if strings.EqualFold(pa.SKU, pb.SKU) && pa.Position != nil && pb.Position != nil && *pa.Position == *pb.Position {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasPack returns whether the card has appeared in the pack with the SKU.
+func hasPack(c *Card, sku string) bool {
+	for _, pack := range c.Packs {
+		if strings.EqualFold(pack.SKU, sku) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSet returns whether the card is a member of the named set.
+func hasSet(c *Card, name string) bool {
+	for _, set := range c.Sets {
+		if strings.EqualFold(set.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// fillMissing sets each field of the struct dst that has its zero value to the value of the same field in src.
+func fillMissing(dst reflect.Value, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		if dst.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
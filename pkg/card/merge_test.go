@@ -0,0 +1,76 @@
+package card
+
+import (
+	"testing"
+)
+
+func TestMergeCards(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(i int) *int { return &i }
+	existing := []*Card{
+		{
+			Names: []string{"Black Widow", "Natasha Romanoff", "Nat"},
+			Packs: []*Pack{{Name: "Black Widow", SKU: "MC07en", Position: num(1)}},
+			Faces: []*Face{
+				{Name: "Natasha Romanoff", Text: str("Mission Prep"), ImageURL: str("https://example.com/mc07en/1B.png")},
+				{Name: "Black Widow", Traits: []string{"Avenger", "Spy"}, ImageURL: str("https://example.com/mc07en/1A.png")},
+			},
+		},
+		{
+			Names: []string{"Unknown Card"},
+			Packs: []*Pack{{Name: "Unknown", SKU: "Unknown", Position: num(5)}},
+			Faces: []*Face{{Name: "Unknown Card"}},
+		},
+	}
+	imported := []*Card{
+		{
+			Names: []string{"Black Widow"},
+			Packs: []*Pack{{Name: "Black Widow", SKU: "MC07en", Position: num(1)}},
+			Sets:  []*Set{{Name: "Black Widow"}},
+			Faces: []*Face{
+				{Name: "Black Widow", Traits: []string{"Avenger", "Spy", "S.H.I.E.L.D."}, HitPoints: num(9), ImageURL: str("https://example.com/mc07en/1A.png")},
+				{Name: "Somebody Else", ImageURL: str("https://example.com/mc07en/99.png")},
+			},
+		},
+		{
+			Names: []string{"Unknown Card"},
+			Packs: []*Pack{{Name: "Unknown", SKU: "Unknown", Position: num(5)}},
+			Faces: []*Face{{Name: "Unknown Card", Cost: num(2)}},
+		},
+		{
+			Names: []string{"Widow's Bite"},
+			Faces: []*Face{{Name: "Widow's Bite", ImageURL: str("https://example.com/mc07en/3.png")}},
+		},
+	}
+
+	merged := MergeCards(existing, imported)
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 cards, got %d", len(merged))
+	}
+	widow := merged[0]
+	if len(widow.Names) != 3 || widow.Names[2] != "Nat" {
+		t.Errorf("Names: expected aliases to be kept, got %v", widow.Names)
+	}
+	if len(widow.Sets) != 1 {
+		t.Errorf("Sets: expected the imported set to be added, got %d sets", len(widow.Sets))
+	}
+	if len(widow.Faces) != 2 {
+		t.Errorf("Faces: expected unpaired faces to be left out, got %d faces", len(widow.Faces))
+	}
+	hero := widow.Faces[1]
+	if len(hero.Traits) != 2 {
+		t.Errorf("Traits: expected existing traits to be kept, got %v", hero.Traits)
+	}
+	if hero.HitPoints == nil || *hero.HitPoints != 9 {
+		t.Errorf("HitPoints: expected missing value to be filled in, got %v", hero.HitPoints)
+	}
+	if *widow.Faces[0].Text != "Mission Prep" {
+		t.Errorf("Text: expected unmatched face to be unchanged, got %s", *widow.Faces[0].Text)
+	}
+	if merged[1].Faces[0].Cost == nil || *merged[1].Faces[0].Cost != 2 {
+		t.Errorf("Cost: expected card without images to be matched by position")
+	}
+	if merged[2] != imported[2] {
+		t.Errorf("expected new card to be added to the end")
+	}
+}
@@ -0,0 +1,122 @@
+package card
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"sort"
+)
+
+// PackType is the kind of product a pack was published as.
+type PackType string
+
+const (
+	CorePack         = PackType("core")
+	CampaignPack     = PackType("campaign")
+	HeroPack         = PackType("hero")
+	ScenarioPack     = PackType("scenario")
+	PrintAndPlayPack = PackType("print-and-play")
+)
+
+// PackInfo describes a published pack of cards, such as the Core Set or the Thor hero pack. Cards refer to packs by
+// name and SKU with a Pack, and the PackRegistry holds everything else known about them.
+type PackInfo struct {
+	Name          string   `json:"name" yaml:"name"`                           // The name used in the card data.
+	Aliases       []string `json:"aliases,omitempty" yaml:"aliases,omitempty"` // Other names, e.g. the name used by MarvelCDB.
+	MarvelCDBCode string   `json:"marvelcdb_code" yaml:"marvelcdb_code"`       // The pack_code of the pack's cards on MarvelCDB.
+	SKU           string   `json:"sku" yaml:"sku"`
+	Release       int      `json:"release" yaml:"release"` // The order in which the pack was released, starting at 1.
+	Wave          int      `json:"wave" yaml:"wave"`
+	Type          PackType `json:"type" yaml:"type"`
+}
+
+// PackRegistry holds the packs loaded from the pack data, and looks them up by SKU, MarvelCDB code or name. A nil
+// PackRegistry has no packs.
+type PackRegistry struct {
+	packs []*PackInfo
+	skus  map[string]*PackInfo
+	codes map[string]*PackInfo
+	names map[string]*PackInfo // PackInfo.Name and PackInfo.Aliases
+}
+
+// NewPackRegistry builds a PackRegistry from a slice of packs, which are kept in release order.
+func NewPackRegistry(packs []*PackInfo) *PackRegistry {
+	r := &PackRegistry{
+		packs: make([]*PackInfo, len(packs)),
+		skus:  map[string]*PackInfo{},
+		codes: map[string]*PackInfo{},
+		names: map[string]*PackInfo{},
+	}
+	copy(r.packs, packs)
+	sort.SliceStable(r.packs, func(i, j int) bool {
+		return r.packs[i].Release < r.packs[j].Release
+	})
+	for _, p := range r.packs {
+		r.skus[Normalize(p.SKU)] = p
+		if p.MarvelCDBCode != "" {
+			r.codes[Normalize(p.MarvelCDBCode)] = p
+		}
+		r.names[Normalize(p.Name)] = p
+		for _, alias := range p.Aliases {
+			r.names[Normalize(alias)] = p
+		}
+	}
+	return r
+}
+
+// LoadPackRegistry reads a YAML list of packs, such as data/packs.yaml, into a PackRegistry.
+func LoadPackRegistry(path string) (*PackRegistry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+	}
+	packs := []*PackInfo{}
+	if err := yaml.Unmarshal(data, &packs); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s: %w", path, err)
+	}
+	return NewPackRegistry(packs), nil
+}
+
+// Packs returns every pack in release order.
+func (r *PackRegistry) Packs() []*PackInfo {
+	if r == nil {
+		return nil
+	}
+	return r.packs
+}
+
+// BySKU returns the pack with the SKU, e.g. MC01en, or nil if there is none.
+func (r *PackRegistry) BySKU(sku string) *PackInfo {
+	if r == nil {
+		return nil
+	}
+	return r.skus[Normalize(sku)]
+}
+
+// ByCode returns the pack with the MarvelCDB pack code, e.g. core, or nil if there is none.
+func (r *PackRegistry) ByCode(code string) *PackInfo {
+	if r == nil {
+		return nil
+	}
+	return r.codes[Normalize(code)]
+}
+
+// ByName returns the pack with the name or alias, e.g. Dr. Strange, or nil if there is none.
+func (r *PackRegistry) ByName(name string) *PackInfo {
+	if r == nil {
+		return nil
+	}
+	return r.names[Normalize(name)]
+}
+
+// Lookup returns the pack a user most likely means by s, whether it is a SKU, a MarvelCDB code or a name, or nil if
+// there is none.
+func (r *PackRegistry) Lookup(s string) *PackInfo {
+	if p := r.BySKU(s); p != nil {
+		return p
+	}
+	if p := r.ByName(s); p != nil {
+		return p
+	}
+	return r.ByCode(s)
+}
@@ -0,0 +1,94 @@
+package card
+
+import (
+	"testing"
+)
+
+func testPackRegistry() *PackRegistry {
+	return NewPackRegistry([]*PackInfo{
+		{Name: "Galaxy’s Most Wanted", MarvelCDBCode: "gmw", SKU: "MC16en", Release: 16, Wave: 3, Type: CampaignPack},
+		{Name: "Core Set", MarvelCDBCode: "core", SKU: "MC01en", Release: 1, Wave: 1, Type: CorePack},
+		{Name: "Doctor Strange", Aliases: []string{"Dr. Strange"}, MarvelCDBCode: "drs", SKU: "MC08en", Release: 8, Wave: 1, Type: HeroPack},
+	})
+}
+
+func TestPackRegistry_Lookup(t *testing.T) {
+	packs := testPackRegistry()
+	var testCases = []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "SKU", query: "mc01en", want: "MC01en"},
+		{name: "MarvelCDB code", query: "DRS", want: "MC08en"},
+		{name: "Name", query: "core set", want: "MC01en"},
+		{name: "Alias", query: "Dr. Strange", want: "MC08en"},
+		{name: "Name with straight apostrophe", query: "Galaxy's Most Wanted", want: "MC16en"},
+		{name: "Missing", query: "Hood", want: ""},
+	}
+
+	for _, tt := range testCases {
+		got := ""
+		if p := packs.Lookup(tt.query); p != nil {
+			got = p.SKU
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+
+	// Packs are kept in release order
+	for i, want := range []string{"MC01en", "MC08en", "MC16en"} {
+		if got := packs.Packs()[i].SKU; got != want {
+			t.Errorf("Packs: expected %s at index %d, got %s", want, i, got)
+		}
+	}
+
+	// A nil registry has no packs
+	var empty *PackRegistry
+	if empty.Lookup("core") != nil || len(empty.Packs()) != 0 {
+		t.Errorf("nil registry: expected no packs")
+	}
+}
+
+func TestCardRepository_PackRegistry(t *testing.T) {
+	cards := []*Card{
+		{
+			Names: []string{"Cloak of Levitation"},
+			Packs: []*Pack{{Name: "Doctor Strange", SKU: "MC08en"}},
+			Faces: []*Face{{Name: "Cloak of Levitation", Type: "Upgrade"}},
+		},
+		{
+			Names: []string{"Lockjaw"},
+			Packs: []*Pack{{Name: "Ms. Marvel", SKU: "MC05en"}},
+			Faces: []*Face{{Name: "Lockjaw", Type: "Ally"}},
+		},
+	}
+	repo := NewCardRepository(cards)
+	repo.SetPackRegistry(testPackRegistry())
+
+	var testCases = []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{name: "Alias", query: `pack:"Dr. Strange"`, want: 1},
+		{name: "MarvelCDB code", query: "pack:drs type:upgrade", want: 1},
+		{name: "Negated code", query: "NOT pack:drs", want: 1},
+		{name: "Unregistered pack name", query: "pack:marvel", want: 1},
+	}
+	for _, tt := range testCases {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := repo.Search(q); len(got) != tt.want {
+			t.Errorf("%s: expected %d cards, got %d", tt.name, tt.want, len(got))
+		}
+	}
+
+	if got := repo.ByPack("drs"); len(got) != 1 || got[0] != cards[0] {
+		t.Errorf("ByPack: expected Cloak of Levitation for drs, got %d cards", len(got))
+	}
+}
@@ -0,0 +1,603 @@
+package card
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed card search, such as `aspect:Aggression type:Ally cost<=3`. Queries are built from terms that
+// compare a card field against a value, and the terms may be combined with AND, OR, NOT and parentheses. Terms that are
+// written next to each other without an operator are joined with AND.
+//
+// Bare words that are not part of a term are treated as a card name, so `Black Cat` is the same as `name:"Black Cat"`.
+// Bare words following a term on a text field (name, set, pack, trait or text) extend the value of that term, which
+// keeps the original `[[set:A Mess of Things]]` syntax working without quotes.
+//
+// The text field searches the rules text of a card for a phrase, e.g. `text:"ready your hero"`. When a query contains
+// text terms, the results are ranked by relevance to those terms.
+type Query struct {
+	root node
+}
+
+// Term is a single comparison within a Query, e.g. `cost<=2` or `trait:Avenger`.
+type Term struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// node is a single element of the parsed Query tree.
+type node interface {
+	match(c *Card, f *Face) bool
+}
+
+type andNode struct {
+	left, right node
+}
+
+type orNode struct {
+	left, right node
+}
+
+type notNode struct {
+	child node
+}
+
+// fieldAliases maps the short forms of a field to the canonical field name.
+var fieldAliases = map[string]string{
+	"n":       "name",
+	"t":       "type",
+	"s":       "set",
+	"p":       "pack",
+	"sku":     "pack",
+	"tr":      "trait",
+	"a":       "aspect",
+	"k":       "keyword",
+	"kw":      "keyword",
+	"thw":     "thwart",
+	"atk":     "attack",
+	"def":     "defense",
+	"rec":     "recover",
+	"sch":     "scheme",
+	"hp":      "health",
+	"hand":    "hand_size",
+	"boosts":  "boost",
+	"traits":  "trait",
+	"aspects": "aspect",
+	"x":       "text",
+	"o":       "text",
+}
+
+// textFields are fields whose values may contain spaces.
+var textFields = map[string]bool{
+	"name":  true,
+	"set":   true,
+	"pack":  true,
+	"trait": true,
+	"text":  true,
+}
+
+// numericFields map numeric field names to the Face value they compare against.
+var numericFields = map[string]func(f *Face) *int{
+	"cost":      func(f *Face) *int { return f.Cost },
+	"thwart":    func(f *Face) *int { return f.ThwartValue },
+	"attack":    func(f *Face) *int { return f.AttackValue },
+	"defense":   func(f *Face) *int { return f.DefenseValue },
+	"recover":   func(f *Face) *int { return f.RecoverValue },
+	"scheme":    func(f *Face) *int { return f.SchemeValue },
+	"hand_size": func(f *Face) *int { return f.HandSize },
+	"boost":     func(f *Face) *int { return f.BoostIcons },
+	"health": func(f *Face) *int {
+		if f.HitPoints != nil {
+			return f.HitPoints
+		}
+		return f.HitPointsPerPlayer
+	},
+	"stage": func(f *Face) *int {
+		if f.Stage == nil {
+			return nil
+		}
+		stage, err := strconv.Atoi(strings.TrimRight(*f.Stage, "ABab"))
+		if err != nil {
+			return nil
+		}
+		return &stage
+	},
+}
+
+// typeFields are card types that may be used as a field, so that `ally:Lockjaw` means `type:Ally name:Lockjaw`.
+var typeFields = map[string]bool{
+	"ally":        true,
+	"alter-ego":   true,
+	"attachment":  true,
+	"environment": true,
+	"event":       true,
+	"hero":        true,
+	"main-scheme": true,
+	"minion":      true,
+	"obligation":  true,
+	"resource":    true,
+	"side-scheme": true,
+	"support":     true,
+	"treachery":   true,
+	"upgrade":     true,
+	"villain":     true,
+}
+
+// termRegexp splits a word such as `cost<=2` into its field, operator and value.
+var termRegexp = regexp.MustCompile(`^([A-Za-z][A-Za-z_-]*)(:|<=|>=|!=|=|<|>)(.*)$`)
+
+// ParseQuery parses a query string into a Query.
+func ParseQuery(s string) (*Query, error) {
+	tokens, err := tokenizeQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in query", p.tokens[p.pos].text)
+	}
+	return &Query{root: root}, nil
+}
+
+// Simple returns the field and value of the query if it consists of a single term with the default `:` operator. This
+// lets callers keep their own ranking for plain searches such as `[[Lockjaw]]` or `[[set:Expert]]`.
+func (q *Query) Simple() (field string, value string, ok bool) {
+	t, ok := q.root.(*Term)
+	if !ok || t.Op != ":" {
+		return "", "", false
+	}
+	return t.Field, t.Value, true
+}
+
+// Match returns whether any face of the card satisfies the query. The whole query is evaluated against a single face,
+// so `type:Ally cost<=2` will not match a card that has an Ally face and a different face with a low cost.
+func (q *Query) Match(c *Card) bool {
+	if len(c.Faces) == 0 {
+		return q.root.match(c, nil)
+	}
+	for _, f := range c.Faces {
+		if q.root.match(c, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns every card in the repository matching the query. Terms that have a matching index are used to narrow
+// down the cards that need to be checked.
+func (r *CardRepository) Search(q *Query) (matches []*Card) {
+	q = &Query{root: resolvePacks(q.root, r.registry)}
+	candidates := r.cards
+	if narrowed, ok := r.candidates(q.root); ok {
+		candidates = narrowed
+	}
+	for _, c := range candidates {
+		if q.Match(c) {
+			matches = append(matches, c)
+		}
+	}
+	// Rank the results by relevance when searching rules text
+	if terms := textTerms(q.root, false); len(terms) > 0 {
+		scores := r.text.Scores(strings.Join(terms, " "))
+		sort.SliceStable(matches, func(i, j int) bool {
+			return scores[matches[i]] > scores[matches[j]]
+		})
+	}
+	return matches
+}
+
+// resolvePacks returns the node with each pack term that names a registered pack also matching the pack's SKU, so that
+// the pack can be found by any name the registry knows it by.
+func resolvePacks(n node, packs *PackRegistry) node {
+	switch n := n.(type) {
+	case *andNode:
+		return &andNode{left: resolvePacks(n.left, packs), right: resolvePacks(n.right, packs)}
+	case *orNode:
+		return &orNode{left: resolvePacks(n.left, packs), right: resolvePacks(n.right, packs)}
+	case *notNode:
+		return &notNode{child: resolvePacks(n.child, packs)}
+	case *Term:
+		if n.Field != "pack" || (n.Op != ":" && n.Op != "=") {
+			return n
+		}
+		if p := packs.Lookup(n.Value); p != nil {
+			return &orNode{left: n, right: &Term{Field: "pack", Op: "=", Value: Normalize(p.SKU)}}
+		}
+	}
+	return n
+}
+
+// textTerms returns the values of the text terms in the query that are not negated.
+func textTerms(n node, negated bool) (terms []string) {
+	switch n := n.(type) {
+	case *andNode:
+		return append(textTerms(n.left, negated), textTerms(n.right, negated)...)
+	case *orNode:
+		return append(textTerms(n.left, negated), textTerms(n.right, negated)...)
+	case *notNode:
+		return textTerms(n.child, !negated)
+	case *Term:
+		if n.Field == "text" && n.Op != "!=" && !negated {
+			return []string{n.Value}
+		}
+	}
+	return nil
+}
+
+// candidates returns the cards that could match the node using the repository indexes. If the node cannot be answered
+// from an index, ok is false and every card must be checked.
+func (r *CardRepository) candidates(n node) (cards []*Card, ok bool) {
+	switch n := n.(type) {
+	case *andNode:
+		left, leftOK := r.candidates(n.left)
+		right, rightOK := r.candidates(n.right)
+		switch {
+		case leftOK && rightOK:
+			return Intersect(left, right), true
+		case leftOK:
+			return left, true
+		case rightOK:
+			return right, true
+		}
+	case *orNode:
+		left, leftOK := r.candidates(n.left)
+		right, rightOK := r.candidates(n.right)
+		if leftOK && rightOK {
+			return r.Merge(left, right), true
+		}
+	case *Term:
+		if n.Op != ":" && n.Op != "=" {
+			return nil, false
+		}
+		switch n.Field {
+		case "id":
+			if c := r.ByID(n.Value); c != nil {
+				return []*Card{c}, true
+			}
+			return nil, true
+		case "trait":
+			return r.ByTrait(n.Value), true
+		case "aspect":
+			return r.ByAspect(n.Value), true
+		case "text":
+			return r.text.Containing(n.Value), true
+		}
+	}
+	return nil, false
+}
+
+func (n *andNode) match(c *Card, f *Face) bool {
+	return n.left.match(c, f) && n.right.match(c, f)
+}
+
+func (n *orNode) match(c *Card, f *Face) bool {
+	return n.left.match(c, f) || n.right.match(c, f)
+}
+
+func (n *notNode) match(c *Card, f *Face) bool {
+	return !n.child.match(c, f)
+}
+
+func (t *Term) match(c *Card, f *Face) bool {
+	value := Normalize(t.Value)
+	// Numeric comparisons
+	if getter, ok := numericFields[t.Field]; ok {
+		if f == nil {
+			return false
+		}
+		want, err := strconv.Atoi(value)
+		if err != nil {
+			return false
+		}
+		got := getter(f)
+		if got == nil {
+			return false
+		}
+		return compareInts(*got, t.Op, want)
+	}
+	// Text comparisons
+	var matched bool
+	switch t.Field {
+	case "id":
+		matched = c.ID != "" && Normalize(c.ID) == value
+	case "name":
+		for _, name := range c.Names {
+			matched = matched || compareStrings(name, t.Op, value)
+		}
+		if f != nil {
+			matched = matched || compareStrings(f.Name, t.Op, value)
+		}
+	case "set":
+		for _, set := range c.Sets {
+			matched = matched || compareStrings(set.Name, t.Op, value)
+		}
+	case "pack":
+		for _, pack := range c.Packs {
+			matched = matched || Normalize(pack.SKU) == value || compareStrings(pack.Name, t.Op, value)
+		}
+	case "type":
+		if f != nil {
+			matched = typeName(f.Type) == typeName(value)
+		}
+	case "trait":
+		if f != nil {
+			for _, trait := range f.Traits {
+				matched = matched || Normalize(trait) == value
+			}
+		}
+	case "aspect":
+		if f != nil {
+			for _, aspect := range f.Aspect {
+				matched = matched || Normalize(aspect) == value
+			}
+		}
+	case "keyword":
+		if f != nil {
+			for _, keyword := range f.Keywords {
+				matched = matched || Normalize(keyword.Name) == value || Normalize(keyword.String()) == value
+			}
+		}
+	case "text":
+		if f != nil {
+			matched = containsPhrase(Tokenize(f.RulesText()), Tokenize(t.Value))
+		}
+	case "unique":
+		if f != nil {
+			unique, err := strconv.ParseBool(value)
+			matched = err == nil && f.Unique == unique
+		}
+	default:
+		// Card types used as a field, e.g. ally:Lockjaw
+		if f != nil && typeFields[t.Field] && typeName(f.Type) == typeName(t.Field) {
+			for _, name := range c.Names {
+				matched = matched || compareStrings(name, ":", value)
+			}
+		}
+	}
+	if t.Op == "!=" {
+		return !matched
+	}
+	return matched
+}
+
+// compareInts compares two integers using a query operator.
+func compareInts(got int, op string, want int) bool {
+	switch op {
+	case "<":
+		return got < want
+	case "<=":
+		return got <= want
+	case ">":
+		return got > want
+	case ">=":
+		return got >= want
+	case "!=":
+		return got != want
+	default:
+		return got == want
+	}
+}
+
+// compareStrings compares a string against a normalized query value. The `:` operator performs a "contains" match,
+// while every other operator requires an exact match. The `!=` operator is negated by the caller.
+func compareStrings(s string, op string, value string) bool {
+	s = Normalize(s)
+	if op == ":" {
+		return strings.Contains(s, value)
+	}
+	return s == value
+}
+
+// typeName normalizes a card type so that "Alter-Ego", "Alter Ego" and "alter-ego" are the same.
+func typeName(s string) string {
+	return strings.ReplaceAll(Normalize(s), "-", " ")
+}
+
+// queryToken is a single token from a query string.
+type queryToken struct {
+	text    string
+	quoted  bool // Whether any part of the token was quoted, which prevents it being read as an operator
+	leading bool // Whether the token started with a quote, which prevents it being read as a field term
+}
+
+// tokenizeQuery splits a query string into words, quoted phrases and parentheses.
+func tokenizeQuery(s string) (tokens []queryToken, err error) {
+	var current strings.Builder
+	var quoted, leading, inQuotes, started bool
+	flush := func() {
+		if started {
+			tokens = append(tokens, queryToken{text: current.String(), quoted: quoted, leading: leading})
+		}
+		current.Reset()
+		quoted, leading, started = false, false, false
+	}
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			inQuotes = !inQuotes
+			leading = leading || !started
+			quoted, started = true, true
+		case inQuotes:
+			current.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		case (r == '(' && !started) || r == ')':
+			flush()
+			tokens = append(tokens, queryToken{text: string(r)})
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in query")
+	}
+	flush()
+	return tokens, nil
+}
+
+// queryParser is a recursive descent parser over query tokens.
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// peek returns the next unquoted token text, or an empty string.
+func (p *queryParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+func (p *queryParser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.pos < len(p.tokens) && p.peek() != "OR" && p.peek() != ")" {
+		if p.peek() == "AND" {
+			p.pos++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseUnary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("query ended unexpectedly")
+	}
+	switch token := p.peek(); {
+	case token == "NOT":
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child}, nil
+	case token == "(":
+		p.pos++
+		child, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in query")
+		}
+		p.pos++
+		return child, nil
+	case token == ")" || token == "AND" || token == "OR":
+		return nil, fmt.Errorf("unexpected %q in query", token)
+	case len(token) > 1 && strings.HasPrefix(token, "-"):
+		// -trait:Avenger is shorthand for NOT trait:Avenger
+		p.tokens[p.pos].text = token[1:]
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *queryParser) parseTerm() (node, error) {
+	token := p.tokens[p.pos]
+	p.pos++
+	term := &Term{Field: "name", Op: ":", Value: token.text}
+	if parts := termRegexp.FindStringSubmatch(token.text); parts != nil && !token.leading {
+		term.Field = strings.ToLower(parts[1])
+		if alias, ok := fieldAliases[term.Field]; ok {
+			term.Field = alias
+		}
+		term.Op = parts[2]
+		term.Value = parts[3]
+		if !isKnownField(term.Field) {
+			return nil, fmt.Errorf("unknown query field %q", parts[1])
+		}
+		// A value may be written after the operator as a separate quoted phrase, e.g. `set: "A Mess of Things"`
+		if term.Value == "" && p.pos < len(p.tokens) && !isOperatorToken(p.peek()) {
+			term.Value = p.tokens[p.pos].text
+			p.pos++
+		}
+		if term.Value == "" {
+			return nil, fmt.Errorf("missing value for query field %q", parts[1])
+		}
+	}
+	// Bare words following a name or other text field extend the value of that term
+	if term.Field == "name" || textFields[term.Field] || typeFields[term.Field] {
+		for p.pos < len(p.tokens) && p.isBareWord(p.tokens[p.pos]) {
+			term.Value += " " + p.tokens[p.pos].text
+			p.pos++
+		}
+	}
+	return term, nil
+}
+
+// isBareWord returns whether the token is a plain word rather than an operator, parenthesis or field term.
+func (p *queryParser) isBareWord(token queryToken) bool {
+	if token.quoted {
+		return false
+	}
+	if isOperatorToken(token.text) || strings.HasPrefix(token.text, "-") {
+		return false
+	}
+	return termRegexp.FindStringSubmatch(token.text) == nil
+}
+
+// isOperatorToken returns whether a token is a boolean operator or parenthesis.
+func isOperatorToken(s string) bool {
+	switch s {
+	case "AND", "OR", "NOT", "(", ")":
+		return true
+	}
+	return false
+}
+
+// isKnownField returns whether a query field is supported.
+func isKnownField(field string) bool {
+	if _, ok := numericFields[field]; ok {
+		return true
+	}
+	switch field {
+	case "id", "name", "set", "pack", "type", "trait", "aspect", "keyword", "text", "unique":
+		return true
+	}
+	return typeFields[field]
+}
+
+// String returns the term in query syntax.
+func (t *Term) String() string {
+	return fmt.Sprintf("%s%s%s", t.Field, t.Op, t.Value)
+}
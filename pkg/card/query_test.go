@@ -0,0 +1,130 @@
+package card
+
+import (
+	"testing"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func testQueryCards() []*Card {
+	return []*Card{
+		{
+			Names: []string{"Hulk"},
+			Packs: []*Pack{{Name: "Hulk", SKU: "MC09en"}},
+			Sets:  []*Set{{Name: "Hulk"}},
+			Faces: []*Face{
+				{Name: "Bruce Banner", Type: "Alter-Ego", Traits: []string{"Genius"}, HandSize: intPtr(4)},
+				{Name: "Hulk", Type: "Hero", Traits: []string{"Avenger", "Gamma"}, AttackValue: intPtr(3), HandSize: intPtr(4)},
+			},
+		},
+		{
+			Names: []string{"Hawkeye's Quiver"},
+			Packs: []*Pack{{Name: "The Rise of Red Skull", SKU: "MC10en"}},
+			Sets:  []*Set{{Name: "Hawkeye"}},
+			Faces: []*Face{{Name: "Hawkeye's Quiver", Type: "Upgrade", Cost: intPtr(1), Unique: true}},
+		},
+		{
+			Names: []string{"Hulk"},
+			Packs: []*Pack{{Name: "Core Set", SKU: "MC01en"}},
+			Sets:  []*Set{{Name: "She-Hulk"}},
+			Faces: []*Face{{Name: "Hulk", Subtitle: nil, Type: "Ally", Cost: intPtr(5), AttackValue: intPtr(4), Aspect: []string{"Aggression"}, Traits: []string{"Gamma"}}},
+		},
+		{
+			Names: []string{"Rocket Raccoon"},
+			Packs: []*Pack{{Name: "Galaxy’s Most Wanted", SKU: "MC16en"}},
+			Sets:  []*Set{{Name: "Rocket Raccoon"}},
+			Faces: []*Face{{Name: "Rocket Raccoon", Type: "Ally", Cost: intPtr(3), AttackValue: intPtr(2), Aspect: []string{"Aggression"}, Keywords: []Keyword{{Name: "Guard"}}}},
+		},
+		{
+			Names: []string{"Mutagen Cloud"},
+			Packs: []*Pack{{Name: "The Green Goblin", SKU: "MC02en"}},
+			Sets:  []*Set{{Name: "A Mess of Things"}},
+			Faces: []*Face{{Name: "Mutagen Cloud", Type: "Side Scheme", BoostIcons: intPtr(2)}},
+		},
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	cards := testQueryCards()
+	repo := NewCardRepository(cards)
+	var testCases = []struct {
+		name  string
+		query string
+		want  []int
+		err   bool
+	}{
+		{name: "Bare name", query: "Hulk", want: []int{0, 2}},
+		{name: "Bare multi-word name", query: "rocket raccoon", want: []int{3}},
+		{name: "Legacy set filter", query: "set:A Mess of Things", want: []int{4}},
+		{name: "Legacy type filter", query: "ally:Hulk", want: []int{2}},
+		{name: "Implicit AND", query: "aspect:Aggression type:Ally cost<=3", want: []int{3}},
+		{name: "Explicit OR", query: "cost=1 OR cost>=5", want: []int{1, 2}},
+		{name: "NOT", query: "trait:Gamma NOT type:Ally", want: []int{0}},
+		{name: "Dash negation", query: "trait:Gamma -type:Ally", want: []int{0}},
+		{name: "Parentheses", query: "(pack:MC01en OR pack:MC16en) attack>=4", want: []int{2}},
+		{name: "Quoted value", query: `pack:"Galaxy's Most Wanted"`, want: []int{3}},
+		{name: "Quoted phrase", query: `"Hawkeye's Quiver"`, want: []int{1}},
+		{name: "Faces evaluated separately", query: "trait:Genius attack>=3", want: []int{}},
+		{name: "Hyphenated type", query: "type:side-scheme boost>=2", want: []int{4}},
+		{name: "Keyword", query: "keyword:Guard", want: []int{3}},
+		{name: "Unique", query: "unique:true", want: []int{1}},
+		{name: "Unknown field", query: "colour:red", err: true},
+		{name: "Missing parenthesis", query: "(cost=1", err: true},
+		{name: "Unterminated quote", query: `"Hulk`, err: true},
+		{name: "Dangling operator", query: "cost=1 OR", err: true},
+	}
+
+	for _, tt := range testCases {
+		q, err := ParseQuery(tt.query)
+		if err != nil && tt.err == false {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if err == nil && tt.err == true {
+			t.Errorf("%s: missing error", tt.name)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		got := repo.Search(q)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %d cards, got %d", tt.name, len(tt.want), len(got))
+			continue
+		}
+		for i, index := range tt.want {
+			if got[i] != cards[index] {
+				t.Errorf("%s: unexpected card at index %d: %v", tt.name, i, got[i].Names)
+			}
+		}
+	}
+}
+
+func TestQuery_Simple(t *testing.T) {
+	var testCases = []struct {
+		query string
+		field string
+		value string
+		ok    bool
+	}{
+		{query: "Black Cat", field: "name", value: "Black Cat", ok: true},
+		{query: "set:A Mess of Things", field: "set", value: "A Mess of Things", ok: true},
+		{query: "ally:Lockjaw", field: "ally", value: "Lockjaw", ok: true},
+		{query: "cost=2", ok: false},
+		{query: "type:Ally cost<=2", ok: false},
+	}
+
+	for _, tt := range testCases {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.query, err)
+			continue
+		}
+		field, value, ok := q.Simple()
+		if field != tt.field || value != tt.value || ok != tt.ok {
+			t.Errorf("%s: expected (%q, %q, %t), got (%q, %q, %t)", tt.query, tt.field, tt.value, tt.ok, field, value, ok)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package card
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Discord embed limits. Text longer than these must be split across several fields.
+const (
+	EmbedDescriptionLimit = 4096
+	EmbedFieldLimit       = 1024
+)
+
+// DefaultEmoji are the icons shown for card text tokens when no custom emoji has been configured for them. The
+// encounter icons use the custom emoji already found in the rules data.
+var DefaultEmoji = map[string]string{
+	"energy":       "Energy",
+	"mental":       "Mental",
+	"physical":     "Physical",
+	"wild":         "Wild",
+	"boost":        "Boost",
+	"per_hero":     "per player",
+	"per_player":   "per player",
+	"star":         "<:star:667077039236579340>",
+	"acceleration": "<:acceleration:667076458522607622>",
+	"amplify":      "<:amplify:828152580726980639>",
+	"crisis":       "<:crisis:667076210357960714>",
+	"hazard":       "<:hazard:667076504139595776>",
+	"unique":       "<:unique:667076268981878794>",
+}
+
+var (
+	// traitRegexp matches trait references such as [[tech]]
+	traitRegexp = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+	// iconRegexp matches resource and icon tokens such as [mental] or [per_hero]
+	iconRegexp = regexp.MustCompile(`\[([a-z_]+)\]`)
+	// emojiRegexp matches Discord custom emoji, which must never be split or have their underscores treated as italics
+	emojiRegexp = regexp.MustCompile(`<a?:[A-Za-z0-9_]+:[0-9]+>`)
+)
+
+// Renderer converts card text from the YAML data into Discord markdown. Resource and icon tokens are replaced with
+// emoji, trait references are bolded and italicized as they are on the printed cards, and existing bold and italic
+// markdown is kept as is.
+type Renderer struct {
+	emoji map[string]string
+}
+
+// NewRenderer creates a Renderer. The emoji map is keyed by token name without brackets, e.g. "mental", and its values
+// override DefaultEmoji.
+func NewRenderer(emoji map[string]string) *Renderer {
+	r := &Renderer{
+		emoji: map[string]string{},
+	}
+	for token, value := range DefaultEmoji {
+		r.emoji[token] = value
+	}
+	for token, value := range emoji {
+		r.emoji[strings.ToLower(strings.Trim(token, "[]"))] = value
+	}
+	return r
+}
+
+// Render converts card text into Discord markdown.
+func (r *Renderer) Render(text string) string {
+	text = traitRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		trait := traitRegexp.FindStringSubmatch(s)[1]
+		return "***" + titleCase(trait) + "***"
+	})
+	text = iconRegexp.ReplaceAllStringFunc(text, func(s string) string {
+		token := iconRegexp.FindStringSubmatch(s)[1]
+		if emoji, ok := r.emoji[token]; ok {
+			return emoji
+		}
+		return s
+	})
+	text = strings.ReplaceAll(text, "->", "→")
+	// Text in the card data is often indented by the YAML it came from
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// RenderPtr renders optional card text, returning an empty string if there is none.
+func (r *Renderer) RenderPtr(text *string) string {
+	if text == nil {
+		return ""
+	}
+	return r.Render(*text)
+}
+
+// Split breaks rendered text into chunks of at most limit characters. Text is split between lines where possible, then
+// between words, and never inside a custom emoji. Bold and italic markdown left open at the end of a chunk is closed
+// and reopened at the start of the next one, so each chunk renders correctly on its own.
+func Split(text string, limit int) (chunks []string) {
+	if len([]rune(text)) <= limit {
+		return []string{text}
+	}
+	// Leave room to close and reopen markdown
+	const reserved = 6
+	var current []string
+	length := 0
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, ""))
+			current, length = nil, 0
+		}
+	}
+	max := limit - reserved
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			line = "\n" + line
+		}
+		// Lines are kept whole unless they are too long to fit in a chunk by themselves
+		pieces := []string{line}
+		if len([]rune(line)) > max {
+			pieces = splitPieces(line)
+		}
+		for _, piece := range pieces {
+			n := len([]rune(piece))
+			if length+n > max {
+				flush()
+				piece = strings.TrimLeft(piece, " \n")
+				n = len([]rune(piece))
+			}
+			// A single word longer than the limit has to be cut
+			for n > max {
+				runes := []rune(piece)
+				chunks = append(chunks, string(runes[:max]))
+				piece = string(runes[max:])
+				n -= max
+			}
+			current = append(current, piece)
+			length += n
+		}
+	}
+	flush()
+	return balanceMarkdown(chunks)
+}
+
+// splitPieces splits text into words, keeping the whitespace before each word and custom emoji intact.
+func splitPieces(text string) (pieces []string) {
+	var b strings.Builder
+	inWord := false
+	for _, r := range text {
+		isSpace := r == ' ' || r == '\n'
+		if isSpace && inWord {
+			pieces = append(pieces, b.String())
+			b.Reset()
+		}
+		inWord = !isSpace
+		b.WriteRune(r)
+	}
+	if b.Len() > 0 {
+		pieces = append(pieces, b.String())
+	}
+	// Emoji never contain spaces, so they are always whole within a single piece
+	return pieces
+}
+
+// balanceMarkdown closes any bold or italic markdown left open at the end of each chunk, and reopens it at the start
+// of the next.
+func balanceMarkdown(chunks []string) []string {
+	for i := 0; i < len(chunks)-1; i++ {
+		var open string
+		stripped := emojiRegexp.ReplaceAllString(chunks[i], "")
+		if strings.Count(stripped, "**")%2 == 1 {
+			open += "**"
+		}
+		if strings.Count(strings.ReplaceAll(stripped, "**", ""), "_")%2 == 1 {
+			open += "_"
+		}
+		if open == "" {
+			continue
+		}
+		chunks[i] = strings.TrimRight(chunks[i], " \n") + reverse(open)
+		chunks[i+1] = open + strings.TrimLeft(chunks[i+1], " \n")
+	}
+	return chunks
+}
+
+// reverse reverses the order of markdown markers, so that "**_" is closed with "_**".
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// titleCase capitalizes the first letter of each word, e.g. "aerial" becomes "Aerial".
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = []rune(strings.ToUpper(string(runes[0])))[0]
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,53 @@
+package card
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderer_Render(t *testing.T) {
+	r := NewRenderer(map[string]string{"mental": "<:mental:123>"})
+	var testCases = []struct {
+		input string
+		want  string
+	}{
+		{input: "**Resource**: Generate a [mental] resource.", want: "**Resource**: Generate a <:mental:123> resource."},
+		{input: "Place 1 [per_hero] threat here.", want: "Place 1 per player threat here."},
+		{input: "Search your deck for a [[tech]] upgrade.", want: "Search your deck for a ***Tech*** upgrade."},
+		{input: "Exhaust Iron Man -> draw 1 card.", want: "Exhaust Iron Man → draw 1 card."},
+		{input: "Alliance.\n **Hero Action**: _(Limit once per round.)_", want: "Alliance.\n**Hero Action**: _(Limit once per round.)_"},
+		{input: "Spend an [unknown] resource.", want: "Spend an [unknown] resource."},
+	}
+
+	for _, tt := range testCases {
+		if got := r.Render(tt.input); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	if got := Split("short", 10); len(got) != 1 || got[0] != "short" {
+		t.Errorf("expected short text to be returned whole, got %q", got)
+	}
+
+	// Lines are kept together where possible
+	got := Split("first line\nsecond line\nthird line", 30)
+	if len(got) != 2 || got[0] != "first line\nsecond line" || got[1] != "third line" {
+		t.Errorf("expected a split between lines, got %q", got)
+	}
+
+	// Emoji are never split, and open markdown is closed and reopened
+	text := "**" + strings.Repeat("word <:mental:123> ", 10) + "**"
+	for _, chunk := range Split(text, 50) {
+		if len([]rune(chunk)) > 50 {
+			t.Errorf("chunk exceeds limit: %q", chunk)
+		}
+		if strings.Count(chunk, "**")%2 != 0 {
+			t.Errorf("unbalanced markdown in chunk: %q", chunk)
+		}
+		if strings.Count(chunk, "<") != strings.Count(chunk, ">") {
+			t.Errorf("emoji split in chunk: %q", chunk)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package card
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CardRepository holds a set of cards along with prebuilt indexes for the lookups performed by the bot. It is built
+// once from the card data and is safe for concurrent reads, since none of its methods modify the indexes.
+type CardRepository struct {
+	cards []*Card
+	ids   map[string]*Card // Card.ID
+	// order records the position of each card in the original corpus, so results can be returned in a stable order
+	order    map[*Card]int
+	names    map[string][]*Card // Card.Names
+	faces    map[string][]*Card // Face.Name
+	packs    map[string][]*Card // Pack.Name
+	skus     map[string][]*Card // Pack.SKU
+	sets     map[string][]*Card // Set.Name
+	types    map[string][]*Card // Face.Type
+	traits   map[string][]*Card // Face.Traits
+	aspects  map[string][]*Card // Face.Aspect
+	text     *TextIndex         // Face.RulesText
+	registry *PackRegistry
+	nameKeys []string
+	packKeys []string
+	setKeys  []string
+}
+
+// NewCardRepository builds a CardRepository and all of its indexes from a slice of cards. Each card is assigned an ID
+// that is unique within the repository.
+func NewCardRepository(cards []*Card) *CardRepository {
+	r := &CardRepository{
+		cards:   cards,
+		ids:     make(map[string]*Card, len(cards)),
+		order:   make(map[*Card]int, len(cards)),
+		names:   map[string][]*Card{},
+		faces:   map[string][]*Card{},
+		packs:   map[string][]*Card{},
+		skus:    map[string][]*Card{},
+		sets:    map[string][]*Card{},
+		types:   map[string][]*Card{},
+		traits:  map[string][]*Card{},
+		aspects: map[string][]*Card{},
+	}
+	for i, c := range cards {
+		r.order[c] = i
+		r.assignID(c)
+		for _, name := range c.Names {
+			addToIndex(r.names, name, c)
+		}
+		for _, pack := range c.Packs {
+			addToIndex(r.packs, pack.Name, c)
+			addToIndex(r.skus, pack.SKU, c)
+		}
+		for _, set := range c.Sets {
+			addToIndex(r.sets, set.Name, c)
+		}
+		for _, face := range c.Faces {
+			addToIndex(r.faces, face.Name, c)
+			addToIndex(r.types, face.Type, c)
+			for _, trait := range face.Traits {
+				addToIndex(r.traits, trait, c)
+			}
+			for _, aspect := range face.Aspect {
+				addToIndex(r.aspects, aspect, c)
+			}
+		}
+	}
+	r.nameKeys = sortedKeys(r.names)
+	r.packKeys = sortedKeys(r.packs)
+	r.setKeys = sortedKeys(r.sets)
+	r.text = NewTextIndex(cards)
+	return r
+}
+
+// SetPackRegistry lets pack terms in queries find a pack by any of its names, its SKU or its MarvelCDB code, e.g.
+// pack:"Dr. Strange" or pack:drs for the Doctor Strange pack.
+func (r *CardRepository) SetPackRegistry(packs *PackRegistry) {
+	r.registry = packs
+}
+
+// Normalize lowercases a string, collapses its whitespace, and replaces typographic apostrophes so that user input
+// like "Galaxy's Most Wanted" matches the "Galaxy’s Most Wanted" found in the card data.
+func Normalize(s string) string {
+	s = strings.ReplaceAll(s, "’", "'")
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// slugRegexp matches the characters that are replaced when building a card ID.
+var slugRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// assignID gives the card an ID based on the SKU and position of the first pack it appeared in, e.g. mc01en-94. Cards
+// without a position fall back to their name. Some cards share a position in the data, so later cards with the same
+// ID have a suffix added, e.g. mc01en-144-2.
+func (r *CardRepository) assignID(c *Card) {
+	var parts []string
+	if len(c.Packs) > 0 {
+		parts = append(parts, c.Packs[0].SKU)
+		if c.Packs[0].Position != nil {
+			parts = append(parts, strconv.Itoa(*c.Packs[0].Position))
+		}
+	}
+	if len(parts) < 2 && len(c.Names) > 0 {
+		parts = append(parts, c.Names[0])
+	}
+	base := strings.Trim(slugRegexp.ReplaceAllString(Normalize(strings.Join(parts, " ")), "-"), "-")
+	if base == "" {
+		base = "card"
+	}
+	id := base
+	for n := 2; r.ids[id] != nil; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	c.ID = id
+	r.ids[id] = c
+}
+
+// All returns every card in the repository, in the order they were loaded.
+func (r *CardRepository) All() []*Card {
+	return r.cards
+}
+
+// Len returns the number of cards in the repository.
+func (r *CardRepository) Len() int {
+	return len(r.cards)
+}
+
+// ByID returns the card with the given ID, or nil if there is no such card.
+func (r *CardRepository) ByID(id string) *Card {
+	return r.ids[Normalize(id)]
+}
+
+// ByName returns the cards where one of Card.Names matches the name.
+func (r *CardRepository) ByName(name string) []*Card {
+	return r.names[Normalize(name)]
+}
+
+// ByFaceName returns the cards with a face matching the name.
+func (r *CardRepository) ByFaceName(name string) []*Card {
+	return r.faces[Normalize(name)]
+}
+
+// ByPackName returns the cards that have appeared in the named pack.
+func (r *CardRepository) ByPackName(name string) []*Card {
+	return r.packs[Normalize(name)]
+}
+
+// BySKU returns the cards that have appeared in the pack with the given SKU, e.g. MC16en.
+func (r *CardRepository) BySKU(sku string) []*Card {
+	return r.skus[Normalize(sku)]
+}
+
+// ByPack returns the cards that have appeared in the pack with the given name or SKU, or with any name, SKU or
+// MarvelCDB code the pack registry knows the pack by.
+func (r *CardRepository) ByPack(s string) []*Card {
+	cards := r.Merge(r.ByPackName(s), r.BySKU(s))
+	if p := r.registry.Lookup(s); p != nil {
+		cards = r.Merge(cards, r.BySKU(p.SKU))
+	}
+	return cards
+}
+
+// BySet returns the cards that are a member of the named set.
+func (r *CardRepository) BySet(name string) []*Card {
+	return r.sets[Normalize(name)]
+}
+
+// ByType returns the cards with a face of the given type, e.g. Ally.
+func (r *CardRepository) ByType(t string) []*Card {
+	return r.types[Normalize(t)]
+}
+
+// ByTrait returns the cards with a face that has the given trait.
+func (r *CardRepository) ByTrait(trait string) []*Card {
+	return r.traits[Normalize(trait)]
+}
+
+// ByAspect returns the cards with a face belonging to the given aspect.
+func (r *CardRepository) ByAspect(aspect string) []*Card {
+	return r.aspects[Normalize(aspect)]
+}
+
+// SearchText returns up to limit cards ranked by how well their rules text matches the query.
+func (r *CardRepository) SearchText(query string, limit int) []*TextMatch {
+	return r.text.Search(query, limit)
+}
+
+// Names returns the sorted, normalized Card.Names keys of the repository. Searches that cannot use an exact lookup
+// (such as substring or Levenshtein matching) should iterate over these rather than every card.
+func (r *CardRepository) Names() []string {
+	return r.nameKeys
+}
+
+// PackNames returns the sorted, normalized pack names of the repository.
+func (r *CardRepository) PackNames() []string {
+	return r.packKeys
+}
+
+// SetNames returns the sorted, normalized set names of the repository.
+func (r *CardRepository) SetNames() []string {
+	return r.setKeys
+}
+
+// HasType returns whether the repository contains a card face of the given type.
+func (r *CardRepository) HasType(t string) bool {
+	_, ok := r.types[Normalize(t)]
+	return ok
+}
+
+// Merge combines multiple card slices into a single slice with no duplicates. The result is ordered by each card's
+// position in the repository, so results do not depend on the order of the indexes that produced them.
+func (r *CardRepository) Merge(results ...[]*Card) (merged []*Card) {
+	seen := map[*Card]bool{}
+	for _, cards := range results {
+		for _, c := range cards {
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			merged = append(merged, c)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return r.order[merged[i]] < r.order[merged[j]]
+	})
+	return merged
+}
+
+// Intersect returns the cards in a that are also present in b, in the order of a.
+func Intersect(a []*Card, b []*Card) (cards []*Card) {
+	lookup := make(map[*Card]bool, len(b))
+	for _, c := range b {
+		lookup[c] = true
+	}
+	for _, c := range a {
+		if lookup[c] {
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+// addToIndex adds a card to the index under the normalized key, unless the card is already present for that key.
+func addToIndex(index map[string][]*Card, key string, c *Card) {
+	key = Normalize(key)
+	if key == "" {
+		return
+	}
+	cards := index[key]
+	if len(cards) > 0 && cards[len(cards)-1] == c {
+		return
+	}
+	index[key] = append(cards, c)
+}
+
+// sortedKeys returns the keys of an index in sorted order.
+func sortedKeys(index map[string][]*Card) []string {
+	keys := make([]string, 0, len(index))
+	for k := range index {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,99 @@
+package card
+
+import (
+	"testing"
+)
+
+func testRepository() (*CardRepository, []*Card) {
+	cards := []*Card{
+		{
+			Names: []string{"Rhino", "Rhino I"},
+			Packs: []*Pack{{Name: "Core Set", SKU: "MC01en"}},
+			Sets:  []*Set{{Name: "Rhino"}},
+			Faces: []*Face{{Name: "Rhino", Type: "Villain", Traits: []string{"Brute", "Criminal"}}},
+		},
+		{
+			Names: []string{"Lockjaw"},
+			Packs: []*Pack{{Name: "Ms. Marvel", SKU: "MC05en"}},
+			Sets:  []*Set{{Name: "Ms. Marvel"}},
+			Faces: []*Face{{Name: "Lockjaw", Type: "Ally", Traits: []string{"Inhuman"}}},
+		},
+		{
+			Names: []string{"Rocket Raccoon"},
+			Packs: []*Pack{{Name: "Galaxy’s Most Wanted", SKU: "MC16en"}},
+			Sets:  []*Set{{Name: "Rocket Raccoon"}},
+			Faces: []*Face{{Name: "Rocket Raccoon", Type: "Hero", Traits: []string{"Guardian"}, Aspect: []string{}}},
+		},
+		{
+			Names: []string{"Ready for Action"},
+			Packs: []*Pack{{Name: "Galaxy’s Most Wanted", SKU: "MC16en"}},
+			Faces: []*Face{{Name: "Ready for Action", Type: "Event", Aspect: []string{"Aggression"}}},
+		},
+	}
+	return NewCardRepository(cards), cards
+}
+
+func TestCardRepository_Lookups(t *testing.T) {
+	repo, cards := testRepository()
+	var testCases = []struct {
+		name   string
+		lookup func(string) []*Card
+		query  string
+		want   []*Card
+	}{
+		{name: "Alias", lookup: repo.ByName, query: "rhino i", want: cards[0:1]},
+		{name: "Face name", lookup: repo.ByFaceName, query: "LOCKJAW", want: cards[1:2]},
+		{name: "Pack name with typographic apostrophe", lookup: repo.ByPackName, query: "Galaxy's Most Wanted", want: cards[2:4]},
+		{name: "SKU", lookup: repo.BySKU, query: "mc16en", want: cards[2:4]},
+		{name: "Set", lookup: repo.BySet, query: "Ms.  Marvel", want: cards[1:2]},
+		{name: "Type", lookup: repo.ByType, query: "ally", want: cards[1:2]},
+		{name: "Trait", lookup: repo.ByTrait, query: "criminal", want: cards[0:1]},
+		{name: "Aspect", lookup: repo.ByAspect, query: "Aggression", want: cards[3:4]},
+		{name: "Missing", lookup: repo.ByName, query: "Wakanda Forever!", want: nil},
+	}
+
+	for _, tt := range testCases {
+		got := tt.lookup(tt.query)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %d cards, got %d", tt.name, len(tt.want), len(got))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: unexpected card at index %d: %v", tt.name, i, got[i].Names)
+			}
+		}
+	}
+}
+
+func TestCardRepository_Merge(t *testing.T) {
+	repo, cards := testRepository()
+	merged := repo.Merge([]*Card{cards[3], cards[0]}, []*Card{cards[0], cards[1]})
+	if len(merged) != 3 {
+		t.Fatalf("expected 3 cards, got %d", len(merged))
+	}
+	for i, want := range []*Card{cards[0], cards[1], cards[3]} {
+		if merged[i] != want {
+			t.Errorf("unexpected card at index %d: %v", i, merged[i].Names)
+		}
+	}
+}
+
+func TestCardRepository_IDs(t *testing.T) {
+	position := 94
+	cards := []*Card{
+		{Names: []string{"Rhino"}, Packs: []*Pack{{SKU: "MC01en", Position: &position}}},
+		{Names: []string{"Rhino"}, Packs: []*Pack{{SKU: "MC01en", Position: &position}}},
+		{Names: []string{"The Punisher"}, Packs: []*Pack{{SKU: "designhacker01en"}}},
+		{Names: []string{"Wakanda Forever!"}},
+	}
+	repo := NewCardRepository(cards)
+	for i, want := range []string{"mc01en-94", "mc01en-94-2", "designhacker01en-the-punisher", "wakanda-forever"} {
+		if cards[i].ID != want {
+			t.Errorf("expected ID %q, got %q", want, cards[i].ID)
+		}
+		if repo.ByID(want) != cards[i] {
+			t.Errorf("expected ByID(%q) to return card %d", want, i)
+		}
+	}
+}
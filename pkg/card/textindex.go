@@ -0,0 +1,215 @@
+package card
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 tuning parameters. These are the commonly used defaults, which work well for short documents like card text.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// TextIndex is an inverted index over the rules text of cards, used to find cards by what they do rather than by name.
+// Each card is a single document made up of the text, attack, thwart, scheme, star and flavor text of all its faces.
+type TextIndex struct {
+	cards     []*Card
+	lookup    map[*Card]int
+	postings  map[string][]posting
+	lengths   []int
+	avgLength float64
+}
+
+// posting records the number of times a token appears in a document.
+type posting struct {
+	doc  int
+	freq int
+}
+
+// TextMatch is a card returned from a full-text search along with its relevance score.
+type TextMatch struct {
+	Card  *Card
+	Score float64
+}
+
+// NewTextIndex builds a TextIndex over the cards.
+func NewTextIndex(cards []*Card) *TextIndex {
+	ti := &TextIndex{
+		cards:    cards,
+		lookup:   make(map[*Card]int, len(cards)),
+		postings: map[string][]posting{},
+		lengths:  make([]int, len(cards)),
+	}
+	total := 0
+	for doc, c := range cards {
+		ti.lookup[c] = doc
+		frequencies := map[string]int{}
+		for _, face := range c.Faces {
+			for _, token := range Tokenize(face.RulesText()) {
+				frequencies[token]++
+				ti.lengths[doc]++
+			}
+		}
+		for token, freq := range frequencies {
+			ti.postings[token] = append(ti.postings[token], posting{doc: doc, freq: freq})
+		}
+		total += ti.lengths[doc]
+	}
+	if len(cards) > 0 {
+		ti.avgLength = float64(total) / float64(len(cards))
+	}
+	return ti
+}
+
+// Search returns up to limit cards ranked by relevance to the query, using BM25 scoring. Cards do not need to contain
+// every word of the query, but cards containing the words as an exact phrase are ranked above those that do not.
+func (ti *TextIndex) Search(query string, limit int) (matches []*TextMatch) {
+	scores := ti.Scores(query)
+	for c, score := range scores {
+		matches = append(matches, &TextMatch{Card: c, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return ti.lookup[matches[i].Card] < ti.lookup[matches[j].Card]
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Scores returns the relevance score of every card containing at least one word of the query.
+func (ti *TextIndex) Scores(query string) map[*Card]float64 {
+	tokens := Tokenize(query)
+	scores := map[*Card]float64{}
+	n := float64(len(ti.cards))
+	seen := map[string]bool{}
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		postings := ti.postings[token]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.freq)
+			norm := 1 - bm25B + bm25B*float64(ti.lengths[p.doc])/ti.avgLength
+			scores[ti.cards[p.doc]] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+	// Boost cards that contain the query as a phrase, so "ready your hero" prefers that exact wording
+	if len(tokens) > 1 {
+		for c, score := range scores {
+			for _, face := range c.Faces {
+				if containsPhrase(Tokenize(face.RulesText()), tokens) {
+					scores[c] = score * 2
+					break
+				}
+			}
+		}
+	}
+	return scores
+}
+
+// Containing returns the cards that contain every word of the query, in the order of the index.
+func (ti *TextIndex) Containing(query string) (cards []*Card) {
+	tokens := Tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+	counts := map[int]int{}
+	seen := map[string]bool{}
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		for _, p := range ti.postings[token] {
+			counts[p.doc]++
+		}
+	}
+	for doc := range ti.cards {
+		if counts[doc] == len(seen) {
+			cards = append(cards, ti.cards[doc])
+		}
+	}
+	return cards
+}
+
+// RulesText returns all of the searchable text on a face as plain text, with the markdown markers written by Convert
+// removed.
+func (f *Face) RulesText() string {
+	parts := []string{}
+	for _, text := range []*string{f.Text, f.AttackText, f.ThwartText, f.SchemeText, f.StarText, f.FlavorText} {
+		if text != nil && *text != "" {
+			parts = append(parts, StripMarkdown(*text))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// StripMarkdown removes the bold (**) and italic (_) markers from card text. Underscores inside of icon tokens such as
+// [per_hero] are kept.
+func StripMarkdown(s string) string {
+	s = strings.ReplaceAll(s, "**", "")
+	var b strings.Builder
+	var inToken bool
+	for _, r := range s {
+		switch {
+		case r == '[':
+			inToken = true
+		case r == ']':
+			inToken = false
+		case r == '_' && !inToken:
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Tokenize splits text into lowercase words for indexing. Typographic apostrophes are normalized, and possessives are
+// reduced to their base word so that "hero's" matches "hero".
+func Tokenize(s string) (tokens []string) {
+	s = strings.ToLower(StripMarkdown(strings.ReplaceAll(s, "’", "'")))
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '_'
+	})
+	for _, word := range words {
+		word = strings.Trim(word, "'")
+		word = strings.TrimSuffix(word, "'s")
+		if word != "" {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// containsPhrase returns whether the phrase appears as a contiguous run of tokens.
+func containsPhrase(tokens []string, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(tokens); i++ {
+		matched := true
+		for j := range phrase {
+			if tokens[i+j] != phrase[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package card
+
+import (
+	"reflect"
+	"testing"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestStripMarkdown(t *testing.T) {
+	var testCases = []struct {
+		input string
+		want  string
+	}{
+		{input: "**Hero Action**: Ready your hero.", want: "Hero Action: Ready your hero."},
+		{input: "_(Limit once per round.)_", want: "(Limit once per round.)"},
+		{input: "Place 1 [per_hero] threat here.", want: "Place 1 [per_hero] threat here."},
+	}
+
+	for _, tt := range testCases {
+		if got := StripMarkdown(tt.input); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("**Response**: After your hero’s attack, ready _Hawkeye's Quiver_ → [mental]")
+	want := []string{"response", "after", "your", "hero", "attack", "ready", "hawkeye", "quiver", "mental"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestTextIndex_Search(t *testing.T) {
+	cards := []*Card{
+		{Names: []string{"Swarm Tactics"}, Faces: []*Face{{Text: strPtr("**Hero Action**: Change to your other hero form. Ready your hero.")}}},
+		{Names: []string{"Lockjaw"}, Faces: []*Face{{Text: strPtr("**Response**: After Lockjaw enters play, ready him.")}}},
+		{Names: []string{"Rhino"}, Faces: []*Face{{FlavorText: strPtr("I knock things down.")}}},
+		{Names: []string{"Hero Hunter"}, Faces: []*Face{{AttackText: strPtr("Your hero cannot be readied this round.")}}},
+	}
+	ti := NewTextIndex(cards)
+
+	matches := ti.Search("ready your hero", 0)
+	if len(matches) != 3 {
+		t.Fatalf("expected 3 matches, got %d", len(matches))
+	}
+	if matches[0].Card != cards[0] {
+		t.Errorf("expected the exact phrase to rank first, got %v", matches[0].Card.Names)
+	}
+
+	if got := ti.Search("knock", 0); len(got) != 1 || got[0].Card != cards[2] {
+		t.Errorf("expected flavor text to be searchable")
+	}
+
+	if got := ti.Containing("your hero"); len(got) != 2 {
+		t.Errorf("expected 2 cards containing every word, got %d", len(got))
+	}
+}
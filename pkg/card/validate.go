@@ -0,0 +1,248 @@
+package card
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Aspects are the valid values of Face.Aspect.
+var Aspects = []string{"Aggression", "Justice", "Leadership", "Protection", "Pool", "Basic"}
+
+// typeErrorRegexp matches the messages of a yaml.TypeError, e.g. "line 12: field foo not found in type card.Face"
+var typeErrorRegexp = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// Problem is a mistake in the card data, found by a Validator.
+type Problem struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String formats the problem as file:line:column: message, in the style of a compiler error. The column is left out
+// when it is not known.
+func (p *Problem) String() string {
+	if p.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+}
+
+// Validator checks card data files for mistakes that unmarshaling alone does not catch, such as faces without images
+// or misspelled keywords. Pack positions are checked across every file validated, so a single Validator should be
+// used for the whole of the card data.
+type Validator struct {
+	problems  []*Problem
+	positions map[string]map[string]*Problem // The first use of each side of each pack SKU and position
+}
+
+// NewValidator creates a Validator with no problems found.
+func NewValidator() *Validator {
+	return &Validator{
+		positions: map[string]map[string]*Problem{},
+	}
+}
+
+// Problems returns the problems found so far, in the order they were found.
+func (v *Validator) Problems() []*Problem {
+	return v.problems
+}
+
+// report records a problem at the position of a node.
+func (v *Validator) report(file string, node *yaml.Node, format string, a ...interface{}) {
+	v.problems = append(v.problems, &Problem{
+		File:    file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// Validate checks the contents of a card data file, a YAML list of cards. The file name is only used for reporting.
+func (v *Validator) Validate(file string, data []byte) {
+	// Decoding with known fields reports misspelled fields and values of the wrong type, which yaml.Unmarshal ignores
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var typeErr *yaml.TypeError
+	if err := dec.Decode(&[]*Card{}); errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			p := &Problem{File: file, Message: msg}
+			if m := typeErrorRegexp.FindStringSubmatch(msg); m != nil {
+				p.Line, _ = strconv.Atoi(m[1])
+				p.Message = m[2]
+			}
+			v.problems = append(v.problems, p)
+		}
+	} else if err != nil {
+		v.problems = append(v.problems, &Problem{File: file, Message: err.Error()})
+		return
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.SequenceNode {
+		v.report(file, root, "expected a list of cards")
+		return
+	}
+	for _, node := range root.Content {
+		v.validateCard(file, node)
+	}
+}
+
+// positionUse returns the first use of a pack position that clashes with the given side, or nil if there is none. The
+// sides of a split card, such as 43A and 43B, only clash with themselves, while a card without sides clashes with any
+// use of the position.
+func (v *Validator) positionUse(key string, side string) *Problem {
+	uses := v.positions[key]
+	if first, ok := uses[side]; ok {
+		return first
+	}
+	if first, ok := uses[""]; ok {
+		return first
+	}
+	if side != "" {
+		return nil
+	}
+	var first *Problem
+	for _, use := range uses {
+		if first == nil || use.File < first.File || (use.File == first.File && use.Line < first.Line) {
+			first = use
+		}
+	}
+	return first
+}
+
+// validateCard checks a single card.
+func (v *Validator) validateCard(file string, node *yaml.Node) {
+	c := &Card{}
+	// Type errors were reported by Validate, and the rest of the card is still worth checking
+	_ = node.Decode(c)
+
+	if len(c.Names) == 0 {
+		v.report(file, node, "card has no names")
+	}
+	if c.Deck != nil && (*c.Deck < Player || *c.Deck > Invocation) {
+		v.report(file, field(node, "deck"), "invalid deck %d", int(*c.Deck))
+	}
+
+	// A card split into a card per side, such as Black Panther's Wakanda Forever!, shares its position with its other
+	// sides, e.g. 43A and 43B, so the side is part of the position
+	side := ""
+	if len(c.Faces) == 1 && c.Faces[0].ImageURL != nil {
+		if m := sideRegexp.FindStringSubmatch(*c.Faces[0].ImageURL); m != nil {
+			side = strings.ToUpper(m[1])
+		}
+	}
+	packs := field(node, "packs")
+	for i, pack := range c.Packs {
+		packNode := item(packs, i)
+		if pack.SKU == "" {
+			v.report(file, packNode, "pack %q has no SKU", pack.Name)
+			continue
+		}
+		if pack.Position == nil {
+			continue
+		}
+		position := fmt.Sprintf("%d%s", *pack.Position, side)
+		key := fmt.Sprintf("%s#%d", strings.ToLower(pack.SKU), *pack.Position)
+		if first := v.positionUse(key, side); first != nil {
+			v.report(file, packNode, "pack %s position %s is already used at %s:%d", pack.SKU, position, first.File, first.Line)
+			continue
+		}
+		if v.positions[key] == nil {
+			v.positions[key] = map[string]*Problem{}
+		}
+		v.positions[key][side] = &Problem{File: file, Line: packNode.Line, Column: packNode.Column}
+	}
+
+	faces := field(node, "faces")
+	if len(c.Faces) == 0 {
+		v.report(file, node, "card has no faces")
+	}
+	sides := c.Sides()
+	for i, face := range c.Faces {
+		side := ""
+		if sides != nil {
+			side = sides[i]
+		}
+		v.validateFace(file, item(faces, i), face, side)
+	}
+}
+
+// validateFace checks a single face of a card. The side is empty for a card with a single face.
+func (v *Validator) validateFace(file string, node *yaml.Node, f *Face, side string) {
+	if f.ImageURL == nil || *f.ImageURL == "" {
+		v.report(file, node, "face %q has no image_url", f.Name)
+	}
+
+	aspects := field(node, "aspect")
+	for i, aspect := range f.Aspect {
+		if !containsFold(Aspects, aspect) {
+			v.report(file, item(aspects, i), "invalid aspect %q", aspect)
+		}
+	}
+
+	keywords := field(node, "keywords")
+	for i, keyword := range f.Keywords {
+		if !containsFold(KnownKeywords, keyword.Name) {
+			v.report(file, item(keywords, i), "unknown keyword %q", keyword.String())
+		}
+	}
+
+	switch strings.ToLower(f.Type) {
+	case "main scheme":
+		// The A side of a main scheme sets up the scenario, and has no threat of its own
+		if side == "A" {
+			break
+		}
+		if f.StartingThreat == nil && f.StartingThreatPerPlayer == nil {
+			v.report(file, node, "main scheme %q has no starting threat", f.Name)
+		}
+		if f.TargetThreat == nil && f.TargetThreatPerPlayer == nil {
+			v.report(file, node, "main scheme %q has no completion threat", f.Name)
+		}
+	case "side scheme":
+		if f.StartingThreat == nil && f.StartingThreatPerPlayer == nil {
+			v.report(file, node, "side scheme %q has no starting threat", f.Name)
+		}
+	}
+}
+
+// field returns the value of a key in a mapping node, or an empty node at the mapping's position if there is none, so
+// that problems with a missing field are reported against the object it is missing from.
+func field(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i+1]
+			}
+		}
+	}
+	return &yaml.Node{Line: node.Line, Column: node.Column}
+}
+
+// item returns the ith element of a sequence node, or the node itself if there is no such element.
+func item(node *yaml.Node, i int) *yaml.Node {
+	if node.Kind == yaml.SequenceNode && i < len(node.Content) {
+		return node.Content[i]
+	}
+	return node
+}
+
+// containsFold returns whether the slice contains the string, ignoring case.
+func containsFold(s []string, str string) bool {
+	for _, value := range s {
+		if strings.EqualFold(value, str) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package card
+
+import (
+	"testing"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		input    string
+		problems []string
+	}{
+		{name: "Valid card",
+			input: `
+- names: [Lockjaw]
+  packs:
+    - name: Core Set
+      sku: MC01en
+      position: 1
+  deck: 0
+  faces:
+    - name: Lockjaw
+      type: Ally
+      aspect: [Basic]
+      keywords: [Retaliate 1, Uses (3 energy)]
+      image_url: https://example.com/mc01en/1.png
+`,
+		},
+		{name: "Missing names, SKU and image",
+			input: `
+- packs:
+    - name: Core Set
+  faces:
+    - name: Lockjaw
+      type: Ally
+`,
+			problems: []string{
+				"test.yaml:2:3: card has no names",
+				`test.yaml:3:7: pack "Core Set" has no SKU`,
+				`test.yaml:5:7: face "Lockjaw" has no image_url`,
+			},
+		},
+		{name: "Invalid values",
+			input: `
+- names: [Lockjaw]
+  deck: 7
+  faces:
+    - name: Lockjaw
+      type: Ally
+      aspect: [Agression]
+      keywords: [Guard, Gaurd]
+      image_url: https://example.com/mc01en/1.png
+`,
+			problems: []string{
+				"test.yaml:3:9: invalid deck 7",
+				`test.yaml:7:16: invalid aspect "Agression"`,
+				`test.yaml:8:25: unknown keyword "Gaurd"`,
+			},
+		},
+		{name: "Unknown field",
+			input: `
+- names: [Rhino]
+  faces:
+    - name: Rhino
+      type: Villain
+      hitpoints: 14
+      image_url: https://example.com/mc01en/94.png
+`,
+			problems: []string{
+				"test.yaml:6: field hitpoints not found in type card.Face",
+			},
+		},
+		{name: "Duplicate positions",
+			input: `
+- names: [Wakanda Forever!]
+  packs:
+    - {name: Core Set, sku: MC01en, position: 43}
+  faces:
+    - {name: Wakanda Forever!, type: Event, image_url: https://example.com/mc01en/43A.png}
+- names: [Wakanda Forever!]
+  packs:
+    - {name: Core Set, sku: MC01en, position: 43}
+  faces:
+    - {name: Wakanda Forever!, type: Event, image_url: https://example.com/mc01en/43B.png}
+- names: [Drax]
+  packs:
+    - {name: Core Set, sku: MC01en, position: 43}
+  faces:
+    - {name: Drax, type: Ally, image_url: https://example.com/mc01en/44.png}
+- names: [Gamora]
+  packs:
+    - {name: Core Set, sku: MC01en, position: 45}
+    - {name: Core Set, sku: MC01en, position: 45}
+  faces:
+    - {name: Gamora, type: Ally, image_url: https://example.com/mc01en/45.png}
+`,
+			problems: []string{
+				"test.yaml:14:7: pack MC01en position 43 is already used at test.yaml:4",
+				"test.yaml:20:7: pack MC01en position 45 is already used at test.yaml:19",
+			},
+		},
+		{name: "Schemes without threat",
+			input: `
+- names: [The Break-In!]
+  faces:
+    - name: The Break-In!
+      type: Main Scheme
+      image_url: https://example.com/mc01en/97A.png
+    - name: The Break-In!
+      type: Main Scheme
+      starting_threat_per_player: 1
+      image_url: https://example.com/mc01en/97B.png
+- names: [Bomb Scare]
+  faces:
+    - name: Bomb Scare
+      type: Side Scheme
+      image_url: https://example.com/mc01en/133.png
+`,
+			problems: []string{
+				`test.yaml:7:7: main scheme "The Break-In!" has no completion threat`,
+				`test.yaml:13:7: side scheme "Bomb Scare" has no starting threat`,
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		v := NewValidator()
+		v.Validate("test.yaml", []byte(tt.input))
+		problems := v.Problems()
+		if len(problems) != len(tt.problems) {
+			t.Errorf("%s: expected %d problems, got %d: %v", tt.name, len(tt.problems), len(problems), problems)
+			continue
+		}
+		for i, p := range problems {
+			if p.String() != tt.problems[i] {
+				t.Errorf("%s: expected %q, got %q", tt.name, tt.problems[i], p.String())
+			}
+		}
+	}
+}
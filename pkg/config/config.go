@@ -1,6 +1,49 @@
 package config
 
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"os"
+)
+
 // Config is the top-level configuration for marvelbot's config files.
 type Config struct {
 	Token string `json:"token" yaml:"token"`
+	// Emoji maps card text tokens such as mental or per_hero to the Discord emoji used to display them, e.g.
+	// <:mental:123456789012345678>. Tokens without an entry use card.DefaultEmoji.
+	Emoji map[string]string `json:"emoji" yaml:"emoji"`
+	// Images configures where card images are cached and where missing images are downloaded from.
+	Images Images `json:"images" yaml:"images"`
+	// Collections is the file where the packs owned by each user are saved. Defaults to collections.yaml.
+	Collections string `json:"collections" yaml:"collections"`
+	// Preferences is the file where the mission preferences of each server and user are saved. Defaults to
+	// preferences.yaml.
+	Preferences string `json:"preferences" yaml:"preferences"`
+}
+
+// Images configures the card image stores. Each store is either "memory", an HTTP or HTTPS URL, or a local directory.
+type Images struct {
+	// Cache is where downloaded images are kept. Defaults to the images directory.
+	Cache string `json:"cache" yaml:"cache"`
+	// Source is where missing images are downloaded from, such as a local mirror of the image bucket. Defaults to the
+	// image bucket itself.
+	Source string `json:"source" yaml:"source"`
+}
+
+// Load reads a YAML config file. A missing file is not an error, and returns an empty Config so the bot can run with
+// its defaults.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("error unmarshaling config %s: %w", path, err)
+	}
+	return cfg, nil
 }
@@ -0,0 +1,86 @@
+package rule
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ChangeType describes whether a piece of rule text was added, removed, or left unchanged between two versions.
+type ChangeType int
+
+const (
+	Unchanged = ChangeType(iota)
+	Added
+	Removed
+)
+
+// Change is a single sentence of rule text and how it changed between two versions of a rule.
+type Change struct {
+	Type ChangeType
+	Text string
+}
+
+// sentenceRegexp matches the end of a sentence, or the start of a bullet point.
+var sentenceRegexp = regexp.MustCompile(`([.:!?)”])\s+|\s*•\s*`)
+
+// Diff compares the text of two versions of a rule and returns the changes between them. The comparison is made
+// sentence by sentence rather than line by line, since older versions of the rules were hard-wrapped when they were
+// extracted from the Rules Reference Guide and newer versions were not.
+func Diff(from *Rule, to *Rule) (changes []Change) {
+	a := sentences(from.Text + "\n" + from.Text2)
+	b := sentences(to.Text + "\n" + to.Text2)
+
+	// Build the longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the table to produce the changes
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			changes = append(changes, Change{Unchanged, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			changes = append(changes, Change{Removed, a[i]})
+			i++
+		default:
+			changes = append(changes, Change{Added, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		changes = append(changes, Change{Removed, a[i]})
+	}
+	for ; j < len(b); j++ {
+		changes = append(changes, Change{Added, b[j]})
+	}
+	return changes
+}
+
+// sentences splits rule text into sentences with normalized whitespace.
+func sentences(text string) (result []string) {
+	text = strings.Join(strings.Fields(text), " ")
+	text = sentenceRegexp.ReplaceAllString(text, "$1\n")
+	for _, sentence := range strings.Split(text, "\n") {
+		sentence = strings.TrimSpace(sentence)
+		if sentence != "" {
+			result = append(result, sentence)
+		}
+	}
+	return result
+}
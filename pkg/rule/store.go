@@ -0,0 +1,114 @@
+package rule
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Store holds rules keyed by Rule.Name and Rule.Version. Lookups without a version return the newest version of the
+// rule, so a rule that exists in both v1.3 and v1.4 of the Rules Reference Guide always resolves to v1.4 by default.
+type Store struct {
+	rules map[string]map[string]*Rule // Normalized name, then version
+	names []string                    // Display names of every rule, sorted
+}
+
+// NewStore builds a Store from a slice of rules. If a name and version pair appears more than once, the first rule is
+// kept.
+func NewStore(rules []*Rule) *Store {
+	s := &Store{
+		rules: map[string]map[string]*Rule{},
+	}
+	for _, r := range rules {
+		key := NormalizeName(r.Name)
+		if key == "" {
+			continue
+		}
+		versions, ok := s.rules[key]
+		if !ok {
+			versions = map[string]*Rule{}
+			s.rules[key] = versions
+		}
+		if _, ok := versions[r.Version]; ok {
+			continue
+		}
+		versions[r.Version] = r
+	}
+	for key := range s.rules {
+		s.names = append(s.names, s.Latest(key).Name)
+	}
+	sort.Strings(s.names)
+	return s
+}
+
+// NormalizeName normalizes a rule name for comparison. Names are lowercased, whitespace is collapsed, and the quotation
+// marks used by rules like “And” are removed.
+func NormalizeName(s string) string {
+	s = strings.ReplaceAll(s, "’", "'")
+	s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+	return strings.Trim(s, "\"“”")
+}
+
+// Get returns the rule with the given name and version. If version is empty, the newest version is returned.
+func (s *Store) Get(name string, version string) *Rule {
+	if version == "" {
+		return s.Latest(name)
+	}
+	return s.rules[NormalizeName(name)][strings.TrimPrefix(strings.ToLower(version), "v")]
+}
+
+// Latest returns the newest version of the named rule.
+func (s *Store) Latest(name string) *Rule {
+	versions := s.Versions(name)
+	if len(versions) == 0 {
+		return nil
+	}
+	return s.rules[NormalizeName(name)][versions[len(versions)-1]]
+}
+
+// Versions returns every version of the named rule, oldest first.
+func (s *Store) Versions(name string) (versions []string) {
+	for version := range s.rules[NormalizeName(name)] {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return CompareVersions(versions[i], versions[j]) < 0
+	})
+	return versions
+}
+
+// Names returns the display name of every rule, sorted.
+func (s *Store) Names() []string {
+	return s.names
+}
+
+// All returns the newest version of every rule, sorted by name.
+func (s *Store) All() (rules []*Rule) {
+	for _, name := range s.names {
+		rules = append(rules, s.Latest(name))
+	}
+	return rules
+}
+
+// CompareVersions compares two dotted version strings numerically, so that "1.10" is newer than "1.9". It returns -1
+// if a is older than b, 1 if a is newer than b, and 0 if they are equal.
+func CompareVersions(a string, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart int
+		if i < len(aParts) {
+			aPart, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bPart, _ = strconv.Atoi(bParts[i])
+		}
+		switch {
+		case aPart < bPart:
+			return -1
+		case aPart > bPart:
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package rule
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore([]*Rule{
+		{Name: "Ability", Version: "1.3", Text: "An ability is the special game text."},
+		{Name: "Ability", Version: "1.10", Text: "An ability is the special game text that a card contributes."},
+		{Name: "Ability", Version: "1.4", Text: "An ability is the special\ngame text."},
+		{Name: "Form", Version: "1.3", Text: "First"},
+		{Name: "Form", Version: "1.3", Text: "Second"},
+		{Name: "“And”", Version: "1.3"},
+	})
+
+	if got := s.Versions("ability"); !reflect.DeepEqual(got, []string{"1.3", "1.4", "1.10"}) {
+		t.Errorf("expected versions sorted numerically, got %v", got)
+	}
+	if r := s.Get("Ability", ""); r == nil || r.Version != "1.10" {
+		t.Errorf("expected the newest version by default, got %v", r)
+	}
+	if r := s.Get("ABILITY", "v1.3"); r == nil || r.Version != "1.3" {
+		t.Errorf("expected version 1.3, got %v", r)
+	}
+	if r := s.Get("Ability", "1.2"); r != nil {
+		t.Errorf("expected no rule for a missing version, got %v", r)
+	}
+	if r := s.Get("Form", "1.3"); r == nil || r.Text != "First" {
+		t.Errorf("expected the first duplicate to be kept, got %v", r)
+	}
+	if r := s.Get("and", ""); r == nil {
+		t.Errorf("expected quotation marks to be ignored")
+	}
+	if got := s.Names(); !reflect.DeepEqual(got, []string{"Ability", "Form", "“And”"}) {
+		t.Errorf("unexpected names %v", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var testCases = []struct {
+		name string
+		from string
+		to   string
+		want []Change
+	}{
+		{
+			name: "Rewrapped text",
+			from: "A player can be in either hero or alter-ego form at a given\ntime. Each form has abilities.",
+			to:   "A player can be in either hero or alter-ego form at a given time. Each form has abilities.",
+			want: []Change{
+				{Unchanged, "A player can be in either hero or alter-ego form at a given time."},
+				{Unchanged, "Each form has abilities."},
+			},
+		},
+		{
+			name: "Changed sentence",
+			from: "Ready your hero. Draw 1 card.",
+			to:   "Ready your hero. Draw 2 cards.",
+			want: []Change{
+				{Unchanged, "Ready your hero."},
+				{Removed, "Draw 1 card."},
+				{Added, "Draw 2 cards."},
+			},
+		},
+		{
+			name: "Added bullet",
+			from: "Keywords:\n• Guard",
+			to:   "Keywords:\n• Guard\n• Patrol",
+			want: []Change{
+				{Unchanged, "Keywords:"},
+				{Unchanged, "Guard"},
+				{Added, "Patrol"},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		got := Diff(&Rule{Text: tt.from}, &Rule{Text: tt.to})
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"fmt"
+	"github.com/bwmarrin/discordgo"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"marvelbot/pkg/card"
+	"strings"
 )
 
 // ReadCards parses cards in YAML format and returns them to the caller.
@@ -29,3 +31,215 @@ func ReadCards(path string) (cards []*card.Card, err error) {
 	}
 	return
 }
+
+// suggestCards returns up to limit cards whose names match the query, for use in autocomplete. Names starting with the
+// query come first, followed by names containing it, and then by fuzzy matches for anything misspelled.
+func suggestCards(query string, repo *card.CardRepository, limit int) (cards []*card.Card) {
+	query = card.Normalize(query)
+	seen := map[*card.Card]bool{}
+	add := func(name string) {
+		for _, c := range repo.ByName(name) {
+			if !seen[c] && len(cards) < limit {
+				seen[c] = true
+				cards = append(cards, c)
+			}
+		}
+	}
+	for _, name := range repo.Names() {
+		if strings.HasPrefix(name, query) {
+			add(name)
+		}
+	}
+	for _, name := range repo.Names() {
+		if strings.Contains(name, query) {
+			add(name)
+		}
+	}
+	if query != "" {
+		for _, m := range fuzzyRank(query, repo.Names(), FuzzyCutoff) {
+			add(m.Value)
+		}
+	}
+	return cards
+}
+
+// describeCard returns the name of a card along with its pack, set and stage, so that cards sharing a name (such as
+// Rhino I, II and III) can be told apart.
+func describeCard(c *card.Card) string {
+	name := "Unknown"
+	if len(c.Names) > 0 {
+		name = c.Names[0]
+	}
+	details := []string{}
+	if len(c.Packs) > 0 {
+		details = append(details, c.Packs[0].Name)
+	}
+	if len(c.Sets) > 0 && c.Sets[0].Name != name {
+		details = append(details, c.Sets[0].Name)
+	}
+	if len(c.Faces) > 0 && c.Faces[0].Stage != nil {
+		details = append(details, fmt.Sprintf("Stage %s", *c.Faces[0].Stage))
+	}
+	if len(details) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(details, " · "))
+}
+
+// isNameQuery returns whether a card query is a plain name, such as Lockjaw, rather than a structured query.
+func isNameQuery(query string) bool {
+	if strings.TrimSpace(query) == "" {
+		return true
+	}
+	q, err := card.ParseQuery(query)
+	if err != nil {
+		return false
+	}
+	field, _, ok := q.Simple()
+	return ok && field == "name"
+}
+
+// ambiguousQuery is a card name query that matched several cards, which the user will be asked to choose between.
+type ambiguousQuery struct {
+	Query string
+	Side  string // The side requested for whichever card is chosen, if any
+	Cards []*card.Card
+}
+
+// matchCardCommands runs each card query and sorts the results. Queries for a name that matched several cards, such as
+// Rhino, are returned as ambiguous rather than matched. Structured queries such as type:Ally cost<=2 are expected to
+// return several cards, so they are never ambiguous.
+func (srv *Server) matchCardCommands(commands []string) (matched []*card.Card, ambiguous []*ambiguousQuery, unmatched []string) {
+	for _, command := range commands {
+		cards, err := searchCards(command, srv.Cards)
+		if err != nil || len(cards) == 0 {
+			unmatched = append(unmatched, command)
+			continue
+		}
+		if query, side := splitSide(command); len(cards) > 1 && isNameQuery(query) {
+			ambiguous = append(ambiguous, &ambiguousQuery{Query: query, Side: side, Cards: cards})
+			continue
+		}
+		matched = append(matched, cards...)
+	}
+	return matched, ambiguous, unmatched
+}
+
+// cardMenuComponents returns a select menu offering the candidates of an ambiguous query, for use in slash command
+// replies and [[...]] messages alike. The chosen cards are sent by CardComponentHandler.
+func cardMenuComponents(customID string, q *ambiguousQuery) []discordgo.MessageComponent {
+	options := []discordgo.SelectMenuOption{}
+	for _, c := range q.Cards {
+		if len(options) == CardMenuLimit {
+			break
+		}
+		option := discordgo.SelectMenuOption{
+			Label: truncate(describeCard(c), 100),
+			Value: c.ID,
+		}
+		if q.Side != "" {
+			option.Value += "#" + q.Side
+		}
+		if len(c.Faces) > 0 {
+			option.Description = truncate(c.Faces[0].Type, 100)
+		}
+		options = append(options, option)
+	}
+	minValues := 1
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    customID,
+					Placeholder: truncate(q.Query, 150),
+					MinValues:   &minValues,
+					MaxValues:   len(options),
+					Options:     options,
+				},
+			},
+		},
+	}
+}
+
+// describeUnmatchedCards returns a message listing card queries that matched nothing, or an empty string if there are
+// none.
+func describeUnmatchedCards(userID string, queries []string) string {
+	if len(queries) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(
+		"Agent <@%s>, the S.H.I.E.L.D database was unable to retrieve the records you requested:\n\n%s\n\nPlease notify Director <@%s> if you believe this to be an error.",
+		userID,
+		strings.Join(queries, "\n"),
+		Director,
+	)
+}
+
+// describeUnavailableCards returns a message listing cards whose images could not be included, or an empty string if
+// there are none. Missing images are downloaded in the background, so they are usually available on the next request.
+func describeUnavailableCards(userID string, cards []*card.Card) string {
+	if len(cards) == 0 {
+		return ""
+	}
+	names := []string{}
+	for _, c := range cards {
+		names = append(names, describeCard(c))
+	}
+	return fmt.Sprintf(
+		"Agent <@%s>, the S.H.I.E.L.D. archives are still retrieving the following records. Please try again shortly:\n%s",
+		userID,
+		strings.Join(names, "\n"),
+	)
+}
+
+// cardsByID looks up cards by their ID, as used in the values of select menus and the custom IDs of buttons. Each ID
+// may request a single side of the card, e.g. mc01en-1#B.
+func cardsByID(ids []string, repo *card.CardRepository) (cards []*card.Card) {
+	for _, value := range ids {
+		id, side := splitSide(value)
+		if c := repo.ByID(id); c != nil {
+			if side != "" {
+				c = c.WithSide(side)
+			}
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
+
+// CustomIDLimit is the maximum length of the custom ID of a Discord message component.
+const CustomIDLimit = 100
+
+// flipComponents returns a button that flips the cards to their next side, for replies showing a single side of a
+// double-sided card. The cards are stored in the button's custom ID, so nothing is returned if there are too many of
+// them to fit.
+func flipComponents(cards []*card.Card, opts layoutOptions) []discordgo.MessageComponent {
+	ids := []string{}
+	flippable := false
+	for _, c := range cards {
+		if c.Side == "" {
+			ids = append(ids, c.ID)
+			continue
+		}
+		ids = append(ids, c.ID+"#"+c.Side)
+		flippable = true
+	}
+	customID := "flip:" + strings.Join(ids, ";")
+	if opts.Captions {
+		customID = "flip:captions:" + strings.Join(ids, ";")
+	}
+	if !flippable || len(customID) > CustomIDLimit {
+		return nil
+	}
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Flip",
+					Style:    discordgo.SecondaryButton,
+					CustomID: customID,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"marvelbot/pkg/card"
+	"strings"
+	"testing"
+)
+
+func TestMatchCardCommands(t *testing.T) {
+	cards := []*card.Card{
+		{Names: []string{"Rhino", "Rhino I"}, Faces: []*card.Face{{Name: "Rhino", Type: "Villain"}}},
+		{Names: []string{"Rhino", "Rhino II"}, Faces: []*card.Face{{Name: "Rhino", Type: "Villain"}}},
+		{Names: []string{"Lockjaw"}, Faces: []*card.Face{{Name: "Lockjaw", Type: "Ally"}}},
+		{Names: []string{"Jessica Jones"}, Faces: []*card.Face{{Name: "Jessica Jones", Type: "Ally"}}},
+	}
+	srv := &Server{Cards: card.NewCardRepository(cards)}
+
+	matched, ambiguous, unmatched := srv.matchCardCommands([]string{"Rhino", "Lockjaw", "type:Ally", "Wakanda Forever!", "id:" + cards[1].ID})
+	if len(matched) != 4 {
+		t.Errorf("expected Lockjaw, both allies and the chosen Rhino to match, got %d cards", len(matched))
+	}
+	if len(ambiguous) != 1 || ambiguous[0].Query != "Rhino" || len(ambiguous[0].Cards) != 2 {
+		t.Errorf("expected Rhino to be ambiguous, got %v", ambiguous)
+	}
+	if len(unmatched) != 1 || unmatched[0] != "Wakanda Forever!" {
+		t.Errorf("expected Wakanda Forever! to be unmatched, got %v", unmatched)
+	}
+}
+
+func TestMatchCardCommands_Sides(t *testing.T) {
+	spiderMan := &card.Card{
+		Names: []string{"Spider-Man", "Peter Parker"},
+		Faces: []*card.Face{
+			{Name: "Peter Parker", Type: "Alter-Ego", ImageURL: strPtr(card.ImageBaseURL + "mc01en/1B.png")},
+			{Name: "Spider-Man", Type: "Hero", ImageURL: strPtr(card.ImageBaseURL + "mc01en/1A.png")},
+		},
+	}
+	cards := []*card.Card{
+		spiderMan,
+		{Names: []string{"Rhino", "Rhino I"}, Faces: []*card.Face{{Name: "Rhino", Type: "Villain"}}},
+		{Names: []string{"Rhino", "Rhino II"}, Faces: []*card.Face{{Name: "Rhino", Type: "Villain"}}},
+	}
+	srv := &Server{Cards: card.NewCardRepository(cards)}
+
+	matched, ambiguous, _ := srv.matchCardCommands([]string{"Spider-Man #a", "Rhino#A"})
+	if len(matched) != 1 || len(matched[0].Faces) != 1 || matched[0].Faces[0].Name != "Spider-Man" || matched[0].Side != "A" {
+		t.Errorf("expected only the hero side of Spider-Man, got %v", matched)
+	}
+	if len(ambiguous) != 1 || ambiguous[0].Query != "Rhino" || ambiguous[0].Side != "A" {
+		t.Errorf("expected Rhino to be ambiguous with side A, got %v", ambiguous)
+	}
+	if len(spiderMan.Faces) != 2 {
+		t.Errorf("expected the repository's cards not to be modified")
+	}
+
+	// Flipping keeps the sides of the cards in the button, and is only offered for double-sided cards
+	components := flipComponents(matched, layoutOptions{})
+	if len(components) != 1 {
+		t.Fatalf("expected a flip button, got %d components", len(components))
+	}
+	button := components[0].(discordgo.ActionsRow).Components[0].(discordgo.Button)
+	if button.CustomID != "flip:"+spiderMan.ID+"#A" {
+		t.Errorf("unexpected custom ID %s", button.CustomID)
+	}
+	flipped := cardsByID([]string{spiderMan.ID + "#" + spiderMan.NextSide("A")}, srv.Cards)
+	if len(flipped) != 1 || flipped[0].Faces[0].Name != "Peter Parker" {
+		t.Errorf("expected the flipped card to show Peter Parker, got %v", flipped)
+	}
+	if components := flipComponents(cards[1:2], layoutOptions{}); components != nil {
+		t.Errorf("expected no flip button for single-sided cards")
+	}
+}
+
+func TestSplitSide(t *testing.T) {
+	var testCases = []struct {
+		input string
+		query string
+		side  string
+	}{
+		{input: "Spider-Man#B", query: "Spider-Man", side: "B"},
+		{input: " Spider-Man # a ", query: "Spider-Man", side: "A"},
+		{input: "id:mc01en-1#B", query: "id:mc01en-1", side: "B"},
+		{input: "Lockjaw", query: "Lockjaw", side: ""},
+		{input: "Spider-Man#Bad", query: "Spider-Man#Bad", side: ""},
+	}
+
+	for _, tt := range testCases {
+		query, side := splitSide(tt.input)
+		if query != tt.query || side != tt.side {
+			t.Errorf("%s: expected %q and %q, got %q and %q", tt.input, tt.query, tt.side, query, side)
+		}
+	}
+}
+
+func TestFindCards_Type(t *testing.T) {
+	cards := []*card.Card{
+		{Names: []string{"Rhino"}, Faces: []*card.Face{{Name: "Rhino", Type: "Villain"}}},
+		{Names: []string{"Rhinox"}, Faces: []*card.Face{{Name: "Rhinox", Type: "Ally"}}},
+		{Names: []string{"Lockjaw"}, Faces: []*card.Face{{Name: "Lockjaw", Type: "Ally"}}},
+	}
+	repo := card.NewCardRepository(cards)
+
+	// The villain is the closest name overall, but only allies are wanted
+	got := findCards("ally", "rhinp", repo)
+	if len(got) != 1 || got[0].Names[0] != "Rhinox" {
+		t.Errorf("expected the closest ally, Rhinox, got %v", got)
+	}
+	if got := findCards("villain", "rhinp", repo); len(got) != 1 || got[0].Names[0] != "Rhino" {
+		t.Errorf("expected Rhino, got %v", got)
+	}
+}
+
+func TestCardMenuComponents(t *testing.T) {
+	q := &ambiguousQuery{Query: "Rhino", Side: "A"}
+	for n := 0; n < CardMenuLimit+5; n++ {
+		q.Cards = append(q.Cards, &card.Card{ID: fmt.Sprintf("mc01en-%d", n), Names: []string{"Rhino"}, Faces: []*card.Face{{Name: "Rhino", Type: "Villain"}}})
+	}
+
+	components := cardMenuComponents("card:image:0", q)
+	menu := components[0].(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+	if menu.CustomID != "card:image:0" || len(menu.Options) != CardMenuLimit || menu.MaxValues != CardMenuLimit {
+		t.Errorf("expected %d options in menu card:image:0, got %d in %s", CardMenuLimit, len(menu.Options), menu.CustomID)
+	}
+	if menu.Options[0].Value != "mc01en-0#A" || menu.Options[0].Description != "Villain" {
+		t.Errorf("expected the first option to request side A of the first card, got %v", menu.Options[0])
+	}
+	if describeUnmatchedCards("1", nil) != "" {
+		t.Errorf("expected no message when every query matched")
+	}
+	if msg := describeUnmatchedCards("1", []string{"Wakanda Forever!"}); !strings.Contains(msg, "Wakanda Forever!") {
+		t.Errorf("expected the unmatched query to be listed, got %q", msg)
+	}
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"marvelbot/pkg/card"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultCollectionsPath is where collections are saved when no other file is configured.
+const DefaultCollectionsPath = "collections.yaml"
+
+// mentionRegexp matches a Discord user mention, e.g. <@243490403800711169> or <@!243490403800711169>
+var mentionRegexp = regexp.MustCompile(`<@!?([0-9]+)>`)
+
+// CollectionStore records the packs owned by each user, by SKU, and saves them to a YAML file whenever they change. It
+// is safe for concurrent use.
+type CollectionStore struct {
+	path        string
+	mu          sync.RWMutex
+	collections map[string][]string // User ID to the SKUs of their packs
+}
+
+// OpenCollectionStore reads the collections saved at path. A missing file is not an error, and opens an empty store
+// that is saved to path once a collection is added.
+func OpenCollectionStore(path string) (*CollectionStore, error) {
+	store := &CollectionStore{path: path, collections: map[string][]string{}}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read collections %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &store.collections); err != nil {
+		return nil, fmt.Errorf("error unmarshaling collections %s: %w", path, err)
+	}
+	if store.collections == nil {
+		store.collections = map[string][]string{}
+	}
+	return store, nil
+}
+
+// Get returns the SKUs of the packs owned by the user, or nil if the user has not recorded a collection.
+func (cs *CollectionStore) Get(userID string) []string {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return append([]string(nil), cs.collections[userID]...)
+}
+
+// Add records that the user owns the packs with the given SKUs, and saves the collections.
+func (cs *CollectionStore) Add(userID string, skus ...string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	owned := append([]string(nil), cs.collections[userID]...)
+	for _, sku := range skus {
+		if !containsString(owned, sku) {
+			owned = append(owned, sku)
+		}
+	}
+	sort.Strings(owned)
+	return cs.save(userID, owned)
+}
+
+// Remove records that the user no longer owns the packs with the given SKUs, and saves the collections. A user who no
+// longer owns any packs has no collection.
+func (cs *CollectionStore) Remove(userID string, skus ...string) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	owned := []string{}
+	for _, sku := range cs.collections[userID] {
+		if !containsString(skus, sku) {
+			owned = append(owned, sku)
+		}
+	}
+	return cs.save(userID, owned)
+}
+
+// Pool returns the SKUs of the packs owned by any of the users, or nil if none of them have recorded a collection.
+// Users without a collection add nothing to the pool.
+func (cs *CollectionStore) Pool(userIDs ...string) map[string]bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	var pool map[string]bool
+	for _, id := range userIDs {
+		owned, ok := cs.collections[id]
+		if !ok {
+			continue
+		}
+		if pool == nil {
+			pool = map[string]bool{}
+		}
+		for _, sku := range owned {
+			pool[sku] = true
+		}
+	}
+	return pool
+}
+
+// save writes the collections to the store's file with the user's collection replaced by owned, or removed if owned is
+// empty. The change is only made in memory once it has been written, so a failed write leaves the collections as they
+// were. The caller must hold the write lock.
+func (cs *CollectionStore) save(userID string, owned []string) error {
+	collections := make(map[string][]string, len(cs.collections)+1)
+	for id, skus := range cs.collections {
+		collections[id] = skus
+	}
+	if len(owned) == 0 {
+		delete(collections, userID)
+	} else {
+		collections[userID] = owned
+	}
+	if err := writeYAMLFile(cs.path, collections); err != nil {
+		return err
+	}
+	cs.collections = collections
+	return nil
+}
+
+// Owned returns a copy of the catalog with only the heroes, villains and modules from the packs with the given SKUs,
+// along with every difficulty. A nil set of SKUs means every pack is owned, and returns the catalog itself.
+func (mc *MissionCatalog) Owned(skus map[string]bool) *MissionCatalog {
+	if skus == nil {
+		return mc
+	}
+	owned := &MissionCatalog{Difficulties: mc.Difficulties}
+	for _, h := range mc.Heroes {
+		if skus[h.SKU] {
+			owned.Heroes = append(owned.Heroes, h)
+		}
+	}
+	for _, v := range mc.Villains {
+		if skus[v.SKU] {
+			owned.Villains = append(owned.Villains, v)
+		}
+	}
+	for _, m := range mc.Modules {
+		if skus[m.SKU] {
+			owned.Modules = append(owned.Modules, m)
+		}
+	}
+	return owned
+}
+
+// resolvePacks looks up each of the semi-colon separated pack names, SKUs or MarvelCDB codes, and returns the packs
+// that were found and the names that were not.
+func resolvePacks(s string, packs *card.PackRegistry) (found []*card.PackInfo, unknown []string) {
+	for _, name := range strings.Split(s, ";") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if p := packs.Lookup(name); p != nil {
+			found = append(found, p)
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	return found, unknown
+}
+
+// suggestPacks returns up to limit packs whose names or aliases contain the query, in release order.
+func suggestPacks(query string, packs *card.PackRegistry, limit int) (suggestions []*card.PackInfo) {
+	query = card.Normalize(query)
+	for _, p := range packs.Packs() {
+		if len(suggestions) >= limit {
+			break
+		}
+		names := append([]string{p.Name, p.SKU}, p.Aliases...)
+		for _, name := range names {
+			if strings.Contains(card.Normalize(name), query) {
+				suggestions = append(suggestions, p)
+				break
+			}
+		}
+	}
+	return suggestions
+}
+
+// describePacks lists the names of the packs with the given SKUs in release order, one per line. SKUs that are not in
+// the registry are listed as they are.
+func describePacks(skus []string, packs *card.PackRegistry) string {
+	lines := []string{}
+	for _, p := range packs.Packs() {
+		if containsString(skus, p.SKU) {
+			lines = append(lines, fmt.Sprintf("• %s (%s)", p.Name, p.SKU))
+		}
+	}
+	for _, sku := range skus {
+		if packs.BySKU(sku) == nil {
+			lines = append(lines, "• "+sku)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mentionedUsers returns the IDs of the users mentioned in s, in order and without duplicates.
+func mentionedUsers(s string) (ids []string) {
+	for _, m := range mentionRegexp.FindAllStringSubmatch(s, -1) {
+		if !containsString(ids, m[1]) {
+			ids = append(ids, m[1])
+		}
+	}
+	return ids
+}
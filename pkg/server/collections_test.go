@@ -0,0 +1,132 @@
+package server
+
+import (
+	"io/ioutil"
+	"marvelbot/pkg/card"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectionStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "collections")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "collections.yaml")
+
+	store, err := OpenCollectionStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening a missing file: %v", err)
+	}
+	if store.Pool("1", "2") != nil {
+		t.Errorf("expected no pool before any collection is recorded")
+	}
+	if err := store.Add("1", "MC06en", "MC01en", "MC01en"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Add("2", "MC16en", "MC04en"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Remove("2", "MC04en"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Collections are saved, and read back when the store is opened again
+	store, err = OpenCollectionStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var testCases = []struct {
+		name  string
+		users []string
+		want  []string
+	}{
+		{name: "One user", users: []string{"1"}, want: []string{"MC01en", "MC06en"}},
+		{name: "Pooled", users: []string{"1", "2"}, want: []string{"MC01en", "MC06en", "MC16en"}},
+		{name: "User without a collection", users: []string{"2", "3"}, want: []string{"MC16en"}},
+	}
+	for _, tt := range testCases {
+		pool := store.Pool(tt.users...)
+		if len(pool) != len(tt.want) {
+			t.Errorf("%s: expected %d packs, got %d", tt.name, len(tt.want), len(pool))
+		}
+		for _, sku := range tt.want {
+			if !pool[sku] {
+				t.Errorf("%s: expected %s to be in the pool", tt.name, sku)
+			}
+		}
+	}
+	if got := strings.Join(store.Get("1"), ","); got != "MC01en,MC06en" {
+		t.Errorf("expected the collection to be sorted without duplicates, got %s", got)
+	}
+
+	// Removing every pack removes the collection
+	if err := store.Remove("2", "MC16en"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.Get("2") != nil || store.Pool("2") != nil {
+		t.Errorf("expected no collection once every pack is removed")
+	}
+
+	// A failed save leaves the collections as they were
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Add("1", "MC16en"); err == nil {
+		t.Errorf("expected an error adding to a collection that cannot be saved")
+	}
+	if err := store.Remove("1", "MC01en"); err == nil {
+		t.Errorf("expected an error removing from a collection that cannot be saved")
+	}
+	if got := strings.Join(store.Get("1"), ","); got != "MC01en,MC06en" {
+		t.Errorf("expected the collection to be unchanged by failed saves, got %s", got)
+	}
+}
+
+func TestMissionCatalog_Owned(t *testing.T) {
+	catalog := &MissionCatalog{
+		Heroes:   []*Hero{{Name: "Spider-Man", SKU: "MC01en"}, {Name: "Thor", SKU: "MC06en"}},
+		Villains: []*Villain{{Name: "Rhino", SKU: "MC01en"}, {Name: "Drang", SKU: "MC16en"}},
+		Modules:  []*Module{{Name: "Bomb Scare", SKU: "MC01en"}, {Name: "Band of Badoon", SKU: "MC16en"}},
+	}
+	owned := catalog.Owned(map[string]bool{"MC06en": true, "MC16en": true})
+	if len(owned.Heroes) != 1 || owned.Heroes[0].Name != "Thor" {
+		t.Errorf("expected only Thor, got %d heroes", len(owned.Heroes))
+	}
+	if len(owned.Villains) != 1 || owned.Villains[0].Name != "Drang" {
+		t.Errorf("expected only Drang, got %d villains", len(owned.Villains))
+	}
+	if len(owned.Modules) != 1 || owned.Modules[0].Name != "Band of Badoon" {
+		t.Errorf("expected only Band of Badoon, got %d modules", len(owned.Modules))
+	}
+	if catalog.Owned(nil) != catalog {
+		t.Errorf("expected the whole catalog without a collection")
+	}
+}
+
+func TestResolvePacks(t *testing.T) {
+	packs := card.NewPackRegistry([]*card.PackInfo{
+		{Name: "Core Set", MarvelCDBCode: "core", SKU: "MC01en", Release: 1},
+		{Name: "Doctor Strange", Aliases: []string{"Dr. Strange"}, MarvelCDBCode: "drs", SKU: "MC08en", Release: 8},
+	})
+	found, unknown := resolvePacks("core set; Dr. Strange;;Hood", packs)
+	if len(found) != 2 || found[0].SKU != "MC01en" || found[1].SKU != "MC08en" {
+		t.Errorf("expected the Core Set and Doctor Strange, got %d packs", len(found))
+	}
+	if len(unknown) != 1 || unknown[0] != "Hood" {
+		t.Errorf("expected Hood to be unknown, got %v", unknown)
+	}
+	if got := suggestPacks("strange", packs, AutocompleteLimit); len(got) != 1 || got[0].SKU != "MC08en" {
+		t.Errorf("expected Doctor Strange to be suggested, got %d packs", len(got))
+	}
+}
+
+func TestMentionedUsers(t *testing.T) {
+	got := mentionedUsers("<@123> and <@!456>, <@123> @everyone")
+	if strings.Join(got, ",") != "123,456" {
+		t.Errorf("expected 123 and 456, got %v", got)
+	}
+}
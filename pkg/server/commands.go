@@ -15,11 +15,43 @@ var (
 					Description: "Fetches the requested card image(s)",
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:         discordgo.ApplicationCommandOptionString,
+							Name:         "card-names",
+							Description:  "Card name(s) or queries, separated by semi-colons (e.g., Relentless Assault;type:Ally cost<=2)",
+							Required:     true,
+							Autocomplete: true,
+						},
 						{
 							Type:        discordgo.ApplicationCommandOptionString,
-							Name:        "card-names",
-							Description: "Card name(s), separated by semi-colons (e.g., Relentless Assault;Follow Through)",
-							Required:    true,
+							Name:        "side",
+							Description: "Show only one side of double-sided cards (or add #A or #B to a card name)",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "A", Value: "A"},
+								{Name: "B", Value: "B"},
+								{Name: "C", Value: "C"},
+							},
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "captions",
+							Description: "Label each card with its name and pack",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Name:        "info",
+					Description: "Displays the full text and stats of the card",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "card-names",
+							Description:  "Card name(s) or queries, separated by semi-colons (e.g., Relentless Assault;type:Ally cost<=2)",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
 						},
 					},
 				},
@@ -29,15 +61,48 @@ var (
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Options: []*discordgo.ApplicationCommandOption{
 						{
-							Name:        "card-names",
-							Description: "Card name(s), separated by semi-colons (e.g., Relentless Assault;Follow Through)",
-							Type:        discordgo.ApplicationCommandOptionString,
-							Required:    true,
+							Name:         "card-names",
+							Description:  "Card name(s) or queries, separated by semi-colons (e.g., Relentless Assault;type:Ally cost<=2)",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
 						},
 					},
 				},
 			},
 		},
+		{
+			Name:        "search",
+			Description: "Search the text of Marvel Champions cards",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "text",
+					Description: "The card text to search for (e.g., ready your hero)",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        "rule",
+			Description: "Look up a rule from the Rules Reference",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:         "name",
+					Description:  "The name of the rule (e.g., Villain Phase)",
+					Type:         discordgo.ApplicationCommandOptionString,
+					Required:     true,
+					Autocomplete: true,
+				},
+				{
+					Name:         "version",
+					Description:  "The version of the Rules Reference (defaults to the newest)",
+					Type:         discordgo.ApplicationCommandOptionString,
+					Required:     false,
+					Autocomplete: true,
+				},
+			},
+		},
 		{
 			Name:        "mission",
 			Description: "S.H.I.E.L.D. is ready to brief you on your next mission, Agent",
@@ -98,10 +163,164 @@ var (
 				},
 				{
 					Name:        "modular-encounter-count",
-					Description: "Specify the number of modular encounter sets to include",
+					Description: "Number of modular sets to draw, or to add to the recommended ones if not randomized",
 					Type:        discordgo.ApplicationCommandOptionInteger,
 					Required:    false,
 				},
+				{
+					Name:        "use-collection",
+					Description: "Only draw from the packs in your collection, once you have recorded one (defaults to True)",
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Required:    false,
+				},
+				{
+					Name:        "pool-collections",
+					Description: "Agents whose collections are pooled with yours (e.g., @Mockingbird @Nick Fury)",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    false,
+				},
+				{
+					Name:        "difficulty",
+					Description: "The difficulty to play, or Random (default: only what your preferences include)",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Random", Value: "Random"},
+						{Name: "Standard", Value: "Standard"},
+						{Name: "Standard II", Value: "Standard II"},
+						{Name: "Expert", Value: "Expert"},
+						{Name: "Expert II", Value: "Expert II"},
+						{Name: "Heroic 1", Value: "Heroic 1"},
+						{Name: "Heroic 2", Value: "Heroic 2"},
+						{Name: "Heroic 3", Value: "Heroic 3"},
+						{Name: "Heroic 4", Value: "Heroic 4"},
+					},
+				},
+				{
+					Name:        "include",
+					Description: "Heroes, villains, aspects, modules, difficulties or packs to include (e.g., Kang;Expert)",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    false,
+				},
+				{
+					Name:        "exclude",
+					Description: "Heroes, villains, aspects, modules, difficulties or packs to leave out (e.g., Rhino;pack:Core Set)",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        "preferences",
+			Description: "Tell S.H.I.E.L.D. what to always include in or leave out of your missions",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "include",
+					Description: "Always includes heroes, villains, aspects, modules, difficulties or packs in missions",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "terms",
+							Description: "Names, separated by semi-colons (e.g., Kang;Expert;aspect:Justice;pack:Core Set)",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+						{
+							Name:        "scope",
+							Description: "Whose preferences to change (default: yours)",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "Mine", Value: "me"},
+								{Name: "This server", Value: "server"},
+							},
+						},
+					},
+				},
+				{
+					Name:        "exclude",
+					Description: "Always leaves heroes, villains, aspects, modules, difficulties or packs out of missions",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "terms",
+							Description: "Names, separated by semi-colons (e.g., Kang;Expert;aspect:Justice;pack:Core Set)",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+						{
+							Name:        "scope",
+							Description: "Whose preferences to change (default: yours)",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "Mine", Value: "me"},
+								{Name: "This server", Value: "server"},
+							},
+						},
+					},
+				},
+				{
+					Name:        "clear",
+					Description: "Clears your mission preferences",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        "scope",
+							Description: "Whose preferences to change (default: yours)",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "Mine", Value: "me"},
+								{Name: "This server", Value: "server"},
+							},
+						},
+					},
+				},
+				{
+					Name:        "show",
+					Description: "Shows your mission preferences and those of this server",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+			},
+		},
+		{
+			Name:        "collection",
+			Description: "Tell S.H.I.E.L.D. which packs you own, so missions only use what you can play",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "add",
+					Description: "Adds packs to your collection",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "packs",
+							Description:  "Pack names or SKUs, separated by semi-colons (e.g., Core Set;Thor;MC16en)",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "remove",
+					Description: "Removes packs from your collection",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:         "packs",
+							Description:  "Pack names or SKUs, separated by semi-colons (e.g., Core Set;Thor;MC16en)",
+							Type:         discordgo.ApplicationCommandOptionString,
+							Required:     true,
+							Autocomplete: true,
+						},
+					},
+				},
+				{
+					Name:        "list",
+					Description: "Lists the packs in your collection",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
 			},
 		},
 	}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"marvelbot/pkg/card"
+	"strings"
+)
+
+// EmbedLimit is the maximum number of embeds Discord allows in a single message.
+const EmbedLimit = 10
+
+// EmbedSizeLimit is the maximum number of characters Discord allows across all of the embeds in a single message.
+const EmbedSizeLimit = 6000
+
+// CardResultLimit is the maximum number of cards described in reply to a single info or link request, so that broad
+// queries such as type:Ally don't flood the channel.
+const CardResultLimit = 10
+
+// limitCards returns at most CardResultLimit of the cards, along with a message telling the user how many were left
+// out, or an empty message if none were.
+func limitCards(userID string, cards []*card.Card) ([]*card.Card, string) {
+	if len(cards) <= CardResultLimit {
+		return cards, ""
+	}
+	return cards[:CardResultLimit], fmt.Sprintf(
+		"Agent <@%s>, the S.H.I.E.L.D. database found %d records. Only the first %d are shown, so please narrow your query to see the others.",
+		userID,
+		len(cards),
+		CardResultLimit,
+	)
+}
+
+// embedSize returns the number of characters of the embed that count towards EmbedSizeLimit.
+func embedSize(e *discordgo.MessageEmbed) int {
+	size := len([]rune(e.Title)) + len([]rune(e.Description))
+	for _, f := range e.Fields {
+		size += len([]rune(f.Name)) + len([]rune(f.Value))
+	}
+	if e.Footer != nil {
+		size += len([]rune(e.Footer.Text))
+	}
+	if e.Author != nil {
+		size += len([]rune(e.Author.Name))
+	}
+	return size
+}
+
+// packEmbeds groups the embeds into as few messages as Discord allows, keeping each message within EmbedLimit and
+// EmbedSizeLimit. An embed too large to be sent even on its own loses its last fields until it fits.
+func packEmbeds(embeds []*discordgo.MessageEmbed) (messages [][]*discordgo.MessageEmbed) {
+	var current []*discordgo.MessageEmbed
+	var size int
+	for _, e := range embeds {
+		if embedSize(e) > EmbedSizeLimit {
+			trimmed := *e
+			for len(trimmed.Fields) > 0 && embedSize(&trimmed) > EmbedSizeLimit {
+				trimmed.Fields = trimmed.Fields[:len(trimmed.Fields)-1]
+			}
+			e = &trimmed
+		}
+		if len(current) == EmbedLimit || (len(current) > 0 && size+embedSize(e) > EmbedSizeLimit) {
+			messages = append(messages, current)
+			current, size = nil, 0
+		}
+		current = append(current, e)
+		size += embedSize(e)
+	}
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// cardInfoEmbeds builds a faceEmbed for each face of the cards.
+func cardInfoEmbeds(cards []*card.Card, r *card.Renderer) (embeds []*discordgo.MessageEmbed) {
+	for _, c := range cards {
+		for _, f := range c.Faces {
+			embeds = append(embeds, faceEmbed(c, f, r))
+		}
+	}
+	return embeds
+}
+
+// faceEmbed renders the whole of a card face as an embed, so that the card can be read when the image is unavailable.
+// The embed is colored by the aspect of the face, and only the fields the face has a value for are included.
+func faceEmbed(c *card.Card, f *card.Face, r *card.Renderer) *discordgo.MessageEmbed {
+	title := f.Name
+	if f.Subtitle != nil && *f.Subtitle != "" {
+		title = fmt.Sprintf("%s (%s)", title, *f.Subtitle)
+	}
+	if f.Unique {
+		title = "◆ " + title
+	}
+
+	// Card type, along with the stage for villains and schemes
+	cardType := f.Type
+	if f.Stage != nil {
+		cardType = fmt.Sprintf("%s (Stage %s)", cardType, *f.Stage)
+	}
+
+	fields := []*discordgo.MessageEmbedField{}
+	addField := func(name string, value string, inline bool) {
+		if value == "" {
+			return
+		}
+		// Embed fields allow 1024 characters, so long text continues in further fields
+		for n, chunk := range card.Split(value, card.EmbedFieldLimit) {
+			if n == 1 {
+				name += " (continued)"
+			}
+			fields = append(fields, &discordgo.MessageEmbedField{
+				Name:   name,
+				Value:  chunk,
+				Inline: inline,
+			})
+		}
+	}
+	// The description allows 4096 characters, so any text beyond that continues in fields
+	var description string
+	if text := r.RenderPtr(f.Text); text != "" {
+		chunks := card.Split(text, card.EmbedDescriptionLimit)
+		description = chunks[0]
+		addField("Text (continued)", strings.Join(chunks[1:], "\n"), false)
+	}
+	addField("Type", cardType, true)
+	addField("Aspect", strings.Join(f.Aspect, ", "), true)
+	addField("Cost", formatValue(f.Cost), true)
+	addField("Resources", formatResources(f.Resources), true)
+	addField("THW", formatStat(f.ThwartValue, f.ThwartConsequential, r.RenderPtr(f.ThwartText)), true)
+	addField("ATK", formatStat(f.AttackValue, f.AttackConsequential, r.RenderPtr(f.AttackText)), true)
+	addField("DEF", formatStat(f.DefenseValue, nil, r.RenderPtr(f.DefenseText)), true)
+	addField("REC", formatValue(f.RecoverValue), true)
+	addField("SCH", formatStat(f.SchemeValue, nil, r.RenderPtr(f.SchemeText)), true)
+	addField("Hand Size", formatValue(f.HandSize), true)
+	addField("Hit Points", formatPerPlayer(f.HitPoints, f.HitPointsPerPlayer), true)
+	addField("Starting Threat", formatPerPlayer(f.StartingThreat, f.StartingThreatPerPlayer), true)
+	addField("Acceleration", formatPerPlayer(f.AccelerationThreat, f.AccelerationThreatPerPlayer), true)
+	addField("Target Threat", formatPerPlayer(f.TargetThreat, f.TargetThreatPerPlayer), true)
+	addField("Boost", formatValue(f.BoostIcons), true)
+	addField("Traits", strings.Join(f.Traits, ", "), false)
+	addField("Keywords", strings.Join(f.KeywordStrings(), ", "), false)
+	addField("Encounter Icons", strings.Join(f.EncounterIcons, ", "), false)
+	addField("Star", r.RenderPtr(f.StarText), false)
+	if f.FlavorText != nil {
+		addField("Flavor", fmt.Sprintf("_%s_", strings.TrimSpace(*f.FlavorText)), false)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       aspectColor(f),
+		Fields:      fields,
+	}
+	if f.MarvelCDBURL != nil {
+		embed.URL = *f.MarvelCDBURL
+	}
+	if f.ImageURL != nil {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{
+			URL: *f.ImageURL,
+		}
+	}
+	// The pack and set help to tell apart cards that share a name
+	footer := []string{}
+	if len(c.Packs) > 0 {
+		footer = append(footer, c.Packs[0].Name)
+	}
+	if len(c.Sets) > 0 {
+		footer = append(footer, c.Sets[0].Name)
+	}
+	if len(footer) > 0 {
+		embed.Footer = &discordgo.MessageEmbedFooter{
+			Text: strings.Join(footer, " · "),
+		}
+	}
+	return embed
+}
+
+// aspectColor returns the color of the face's aspect, or the Basic color for basic and encounter cards.
+func aspectColor(f *card.Face) int {
+	for _, name := range f.Aspect {
+		for _, aspect := range Aspects {
+			if strings.EqualFold(name, aspect.Name) {
+				return aspect.Color
+			}
+		}
+	}
+	return Basic
+}
+
+// formatValue returns a numeric value for display, or an empty string if there is no value.
+func formatValue(value *int) string {
+	if value == nil {
+		return ""
+	}
+	// Variable values, such as the acceleration of Mutagen Cloud, are stored as -1
+	if *value < 0 {
+		return "X"
+	}
+	return fmt.Sprintf("%d", *value)
+}
+
+// formatStat returns a THW, ATK, DEF or SCH value for display, along with any consequential damage and rendered text
+// that triggers when the stat is used.
+func formatStat(value *int, consequential *int, text string) string {
+	result := formatValue(value)
+	if result == "" {
+		return ""
+	}
+	if consequential != nil && *consequential > 0 {
+		result += fmt.Sprintf(" (+%d consequential)", *consequential)
+	}
+	if text != "" {
+		result += "\n" + text
+	}
+	return result
+}
+
+// formatPerPlayer returns a value that may be fixed, per player, or both, e.g. "2 + 1 per player".
+func formatPerPlayer(fixed *int, perPlayer *int) string {
+	result := []string{}
+	if fixed != nil && *fixed != 0 {
+		result = append(result, formatValue(fixed))
+	}
+	if perPlayer != nil && *perPlayer != 0 {
+		result = append(result, fmt.Sprintf("%s per player", formatValue(perPlayer)))
+	}
+	if len(result) == 0 && fixed != nil {
+		return formatValue(fixed)
+	}
+	return strings.Join(result, " + ")
+}
+
+// formatResources returns the resources generated by a card, e.g. "2 Energy, 1 Wild".
+func formatResources(r *card.Resources) string {
+	if r == nil {
+		return ""
+	}
+	result := []string{}
+	for _, resource := range []struct {
+		name  string
+		value *int
+	}{
+		{"Energy", r.Energy},
+		{"Mental", r.Mental},
+		{"Physical", r.Physical},
+		{"Wild", r.Wild},
+	} {
+		if resource.value != nil && *resource.value > 0 {
+			result = append(result, fmt.Sprintf("%d %s", *resource.value, resource.name))
+		}
+	}
+	return strings.Join(result, ", ")
+}
+
+// difficultyFields describes how to set up the mission at its difficulty: the villain stages to play against, the
+// encounter sets to add and any heroic level.
+func difficultyFields(m *Mission) []*discordgo.MessageEmbedField {
+	if m.Difficulty == nil {
+		return nil
+	}
+	d := m.Difficulty
+	difficulty := d.Name
+	if d.Heroic > 0 {
+		difficulty = fmt.Sprintf(
+			"%s (%s stages, deal %d additional encounter cards to each player in each villain phase)",
+			d.Name,
+			d.Stages,
+			d.Heroic,
+		)
+	}
+	fields := []*discordgo.MessageEmbedField{{Name: "Difficulty", Value: difficulty}}
+	if m.Villain != nil {
+		stages := strings.Join(m.Stages, ", ")
+		if stages == "" {
+			stages = fmt.Sprintf("Use the %s stages from the scenario's setup", d.Stages)
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{Name: "Villain Stages", Value: stages})
+	}
+	if len(d.Sets) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Difficulty Encounter Sets",
+			Value: strings.Join(d.Sets, ", "),
+		})
+	}
+	return fields
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"marvelbot/pkg/card"
+	"strings"
+	"testing"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestFaceEmbed(t *testing.T) {
+	c := &card.Card{
+		Names: []string{"Jessica Jones"},
+		Packs: []*card.Pack{{Name: "Core Set"}},
+		Sets:  []*card.Set{{Name: "Leadership"}},
+	}
+	f := &card.Face{
+		Name:                "Jessica Jones",
+		Type:                "Ally",
+		Unique:              true,
+		Aspect:              []string{"Justice"},
+		Cost:                intPtr(3),
+		ThwartValue:         intPtr(1),
+		ThwartConsequential: intPtr(1),
+		AttackValue:         intPtr(1),
+		AttackConsequential: intPtr(0),
+		HitPoints:           intPtr(3),
+		Traits:              []string{"Defender", "Hero for Hire"},
+		Text:                strPtr("**Response**: After Jessica Jones enters play, ..."),
+	}
+	embed := faceEmbed(c, f, card.NewRenderer(nil))
+	if embed.Color != Justice {
+		t.Errorf("expected the Justice color, got %x", embed.Color)
+	}
+	if embed.Title != "◆ Jessica Jones" {
+		t.Errorf("expected a unique marker in the title, got %q", embed.Title)
+	}
+	want := map[string]string{
+		"Type":       "Ally",
+		"Cost":       "3",
+		"THW":        "1 (+1 consequential)",
+		"ATK":        "1",
+		"Hit Points": "3",
+		"Traits":     "Defender, Hero for Hire",
+	}
+	got := map[string]string{}
+	for _, field := range embed.Fields {
+		got[field.Name] = field.Value
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s: expected %q, got %q", name, value, got[name])
+		}
+	}
+	if _, ok := got["DEF"]; ok {
+		t.Errorf("expected no DEF field for a face without a defense value")
+	}
+}
+
+func TestFormatPerPlayer(t *testing.T) {
+	var testCases = []struct {
+		fixed     *int
+		perPlayer *int
+		want      string
+	}{
+		{fixed: intPtr(2), want: "2"},
+		{perPlayer: intPtr(14), want: "14 per player"},
+		{fixed: intPtr(2), perPlayer: intPtr(1), want: "2 + 1 per player"},
+		{fixed: intPtr(0), want: "0"},
+		{fixed: intPtr(-1), want: "X"},
+		{want: ""},
+	}
+
+	for _, tt := range testCases {
+		if got := formatPerPlayer(tt.fixed, tt.perPlayer); got != tt.want {
+			t.Errorf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestDifficultyFields(t *testing.T) {
+	expert := &Difficulty{Name: "Expert", Stages: "Expert", Sets: []string{"Standard", "Expert"}}
+	heroic := &Difficulty{Name: "Heroic 2", Stages: "Expert", Sets: []string{"Standard", "Expert"}, Heroic: 2}
+	var testCases = []struct {
+		name    string
+		mission *Mission
+		want    []string
+	}{
+		{name: "No difficulty", mission: &Mission{}},
+		{
+			name:    "Stages from the card data",
+			mission: &Mission{Villain: &Villain{Name: "Rhino"}, Difficulty: expert, Stages: []string{"Rhino II", "Rhino III"}},
+			want:    []string{"Difficulty: Expert", "Villain Stages: Rhino II, Rhino III", "Difficulty Encounter Sets: Standard, Expert"},
+		},
+		{
+			name:    "Stages left to the scenario",
+			mission: &Mission{Villain: &Villain{Name: "Thanos"}, Difficulty: heroic},
+			want: []string{
+				"Difficulty: Heroic 2 (Expert stages, deal 2 additional encounter cards to each player in each villain phase)",
+				"Villain Stages: Use the Expert stages from the scenario's setup",
+				"Difficulty Encounter Sets: Standard, Expert",
+			},
+		},
+		{
+			name:    "No villain",
+			mission: &Mission{Difficulty: expert},
+			want:    []string{"Difficulty: Expert", "Difficulty Encounter Sets: Standard, Expert"},
+		},
+	}
+	for _, tt := range testCases {
+		got := []string{}
+		for _, field := range difficultyFields(tt.mission) {
+			got = append(got, field.Name+": "+field.Value)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestPackEmbeds(t *testing.T) {
+	embed := func(size int) *discordgo.MessageEmbed {
+		return &discordgo.MessageEmbed{Description: strings.Repeat("x", size)}
+	}
+	oversized := &discordgo.MessageEmbed{
+		Description: strings.Repeat("x", card.EmbedDescriptionLimit),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Text (continued)", Value: strings.Repeat("x", card.EmbedFieldLimit)},
+			{Name: "Text (continued)", Value: strings.Repeat("x", card.EmbedFieldLimit)},
+		},
+	}
+	tooMany := []*discordgo.MessageEmbed{}
+	for n := 0; n < EmbedLimit+2; n++ {
+		tooMany = append(tooMany, embed(10))
+	}
+	var testCases = []struct {
+		name   string
+		embeds []*discordgo.MessageEmbed
+		want   []int
+	}{
+		{name: "Nothing to send", embeds: nil, want: []int{}},
+		{name: "Small embeds", embeds: []*discordgo.MessageEmbed{embed(10), embed(10), embed(10)}, want: []int{3}},
+		{name: "Too many embeds", embeds: tooMany, want: []int{10, 2}},
+		{name: "Too many characters", embeds: []*discordgo.MessageEmbed{embed(4000), embed(1500), embed(1000)}, want: []int{2, 1}},
+		{name: "Oversized embed", embeds: []*discordgo.MessageEmbed{embed(1000), oversized}, want: []int{1, 1}},
+	}
+	for _, tt := range testCases {
+		got := []int{}
+		for _, message := range packEmbeds(tt.embeds) {
+			size := 0
+			for _, e := range message {
+				size += embedSize(e)
+			}
+			if size > EmbedSizeLimit {
+				t.Errorf("%s: expected at most %d characters per message, got %d", tt.name, EmbedSizeLimit, size)
+			}
+			got = append(got, len(message))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected messages of %v embeds, got %v", tt.name, tt.want, got)
+		}
+	}
+	if len(oversized.Fields) != 2 {
+		t.Errorf("expected the original embed not to be modified")
+	}
+}
+
+func TestLimitCards(t *testing.T) {
+	cards := []*card.Card{}
+	for n := 0; n < CardResultLimit+5; n++ {
+		cards = append(cards, &card.Card{Names: []string{"Lockjaw"}})
+	}
+	if got, content := limitCards("1", cards[:CardResultLimit]); len(got) != CardResultLimit || content != "" {
+		t.Errorf("expected every card and no message, got %d cards and %q", len(got), content)
+	}
+	got, content := limitCards("1", cards)
+	if len(got) != CardResultLimit || !strings.Contains(content, fmt.Sprintf("found %d records", len(cards))) {
+		t.Errorf("expected %d cards and a truncation message, got %d cards and %q", CardResultLimit, len(got), content)
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"github.com/texttheater/golang-levenshtein/levenshtein"
+	"marvelbot/pkg/card"
+	"sort"
+	"strings"
+)
+
+// FuzzyCutoff is the minimum score for a fuzzy match. 70% has been our cutoff point since card names first supported
+// Levenshtein matching.
+const FuzzyCutoff = 0.70
+
+// FuzzyMatch is a candidate string along with its fuzzy score against a query.
+type FuzzyMatch struct {
+	Value string
+	Score float64
+}
+
+// levenshteinRatio returns the Levenshtein ratio between two strings, from 0 (nothing in common) to 1 (identical).
+func levenshteinRatio(a string, b string) float64 {
+	return levenshtein.RatioForStrings([]rune(a), []rune(b), levenshtein.DefaultOptions)
+}
+
+// fuzzyScore scores a query against a candidate string. The score is the better of the Levenshtein ratio of the whole
+// strings, and a token-level score where each word of the query is matched against the closest word of the candidate.
+// The token-level score allows "vilain phase" to match "Villain Phase" and "phase villain" to match as well.
+func fuzzyScore(query string, candidate string) float64 {
+	query = card.Normalize(query)
+	candidate = card.Normalize(candidate)
+	score := levenshteinRatio(query, candidate)
+
+	queryTokens := strings.Fields(query)
+	candidateTokens := strings.Fields(candidate)
+	if len(queryTokens) == 0 || len(candidateTokens) == 0 {
+		return score
+	}
+	var total float64
+	for _, q := range queryTokens {
+		best := 0.0
+		for _, c := range candidateTokens {
+			if ratio := levenshteinRatio(q, c); ratio > best {
+				best = ratio
+			}
+		}
+		total += best
+	}
+	// Words in the candidate that were not part of the query count against it, so "acceleration" prefers the
+	// "Acceleration" rule over "Acceleration Token"
+	tokenScore := total / float64(len(queryTokens))
+	if len(candidateTokens) > len(queryTokens) {
+		tokenScore *= float64(len(queryTokens)) / float64(len(candidateTokens))
+	}
+	if tokenScore > score {
+		score = tokenScore
+	}
+	return score
+}
+
+// fuzzyRank scores every candidate against the query and returns those at or above the cutoff, best match first.
+func fuzzyRank(query string, candidates []string, cutoff float64) (matches []*FuzzyMatch) {
+	for _, candidate := range candidates {
+		if score := fuzzyScore(query, candidate); score >= cutoff {
+			matches = append(matches, &FuzzyMatch{Value: candidate, Score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
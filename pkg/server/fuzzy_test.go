@@ -0,0 +1,57 @@
+package server
+
+import (
+	"marvelbot/pkg/rule"
+	"testing"
+)
+
+func TestFindRule(t *testing.T) {
+	rules := []*rule.Rule{}
+	for _, name := range []string{"Acceleration", "Acceleration Icon", "Acceleration Token", "Amplify Icon",
+		"Crisis Icon", "Quickstrike", "Villain Phase", "Villain", "Player Phase", "“And”"} {
+		rules = append(rules, &rule.Rule{Name: name, Version: "1.3"})
+	}
+	// Rules revised in a later version should resolve to the newest version
+	rules = append(rules, &rule.Rule{Name: "Quickstrike", Version: "1.4"})
+	var testCases = []struct {
+		name       string
+		query      string
+		want       string
+		version    string
+		candidates int
+	}{
+		{name: "Exact match", query: "Villain", want: "Villain"},
+		{name: "Quoted rule name", query: "and", want: "“And”"},
+		{name: "Single contains match", query: "quick", want: "Quickstrike"},
+		{name: "Typo", query: "quickstrik", want: "Quickstrike", version: "1.4"},
+		{name: "Transposed letters", query: "acelleration", want: "Acceleration"},
+		{name: "Typo in one word", query: "vilain phase", want: "Villain Phase"},
+		{name: "Ambiguous contains match", query: "icon", candidates: 3},
+		{name: "No match", query: "wakanda forever", candidates: 0},
+	}
+
+	for _, tt := range testCases {
+		r, candidates := findRule(tt.query, rule.NewStore(rules))
+		if tt.want != "" && (r == nil || r.Name != tt.want) {
+			t.Errorf("%s: expected %q, got %v", tt.name, tt.want, r)
+		}
+		if tt.version != "" && r != nil && r.Version != tt.version {
+			t.Errorf("%s: expected version %s, got %s", tt.name, tt.version, r.Version)
+		}
+		if tt.want == "" && r != nil {
+			t.Errorf("%s: unexpected rule %q", tt.name, r.Name)
+		}
+		if len(candidates) != tt.candidates {
+			t.Errorf("%s: expected %d candidates, got %d", tt.name, tt.candidates, len(candidates))
+		}
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	if fuzzyScore("phase villain", "Villain Phase") < FuzzyCutoff {
+		t.Errorf("expected reordered words to match")
+	}
+	if fuzzyScore("acceleration", "Acceleration") <= fuzzyScore("acceleration", "Acceleration Token") {
+		t.Errorf("expected extra words in the candidate to lower the score")
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"marvelbot/pkg/card"
 	"math/rand"
-	"os"
 	"strings"
 	"time"
 )
@@ -16,20 +15,8 @@ type Aspect struct {
 	Color int    `json:"color" yaml:"color"`
 }
 
-// Hero contains the name of the hero and their S3 image URL
-type Hero struct {
-	Name  string `json:"name" yaml:"name"`
-	Image string `json:"image" yaml:"image"`
-}
-
-// Villain is the encounter that we will be using
-type Villain struct {
-	Name               string   `json:"name" yaml:"name"`
-	Image              string   `json:"image" yaml:"image"`
-	ModuleCount        int      `json:"module_count" yaml:"module_count"`
-	RecommendedModules []string `json:"recommended_modules" yaml:"recommended_modules"`
-	RequiredModules    []string `json:"required_modules" yaml:"required_modules"`
-}
+// SearchResultLimit is the maximum number of cards returned by the "search" slash command.
+const SearchResultLimit = 10
 
 const (
 	Aggression = 0x78141b
@@ -58,69 +45,6 @@ var (
 			Color: Protection,
 		},
 	}
-	Heroes = []*Hero{
-		{"Ant-Man", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc12en/1A.png"},
-		{"Black Panther", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/40A.png"},
-		{"Black Widow", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc07en/1A.png"},
-		{"Captain America", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc04en/1A.png"},
-		{"Captain Marvel", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/10A.png"},
-		{"Doctor Strange", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc08en/1A.png"},
-		{"Gamora", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc18en/1A.png"},
-		{"Groot", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc16en/1B.png"},
-		{"Hawkeye", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc10en/1A.png"},
-		{"Hulk", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc09en/1A.png"},
-		{"Iron Man", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/29A.png"},
-		{"Ms. Marvel", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc05en/1A.png"},
-		{"Quicksilver", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc14en/1A.png"},
-		{"Rocket Raccoon", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc16en/29B.png"},
-		{"Scarlet Witch", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc15en/1A.png"},
-		{"She-Hulk", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/19A.png"},
-		{"Spider-Man", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/1A.png"},
-		{"Spider-Woman", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc10en/31A.png"},
-		{"Star-Lord", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc17en/1A.png"},
-		{"Thor", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc06en/1A.png"},
-		{"Wasp", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc13en/1A.png"},
-	}
-	Villains = []*Villain{
-		{"Rhino", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/94.png", 1, []string{"Bomb Scare"}, []string{}},
-		{"Klaw", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/113.png", 1, []string{"Masters of Evil"}, []string{}},
-		{"Ultron", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc01en/134.png", 1, []string{"Under Attack"}, []string{}},
-		{"Green Goblin (Risky Business)", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc02en/1A.png", 1, []string{"Goblin Gimmicks"}, []string{}},
-		{"Green Goblin (Mutagen Formula)", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc02en/14.png", 1, []string{"Goblin Gimmicks"}, []string{}},
-		{"Wrecking Crew", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc03en/2.png", 0, []string{}, []string{}},
-		{"Crossbones", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc10en/58.png", 2, []string{"Hydra Assault", "Weapon Master"}, []string{"Experimental Weapons", "Legions of Hydra"}},
-		{"Absorbing Man", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc10en/76.png", 1, []string{"Hydra Patrol"}, []string{}},
-		{"Taskmaster", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc10en/93.png", 1, []string{"Weapon Master"}, []string{"Hydra Patrol"}},
-		{"Zola", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc10en/109.png", 1, []string{"Under Attack"}, []string{}},
-		{"Red Skull", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc10en/125.png", 2, []string{"Hydra Assault", "Hydra Patrol"}, []string{}},
-		{"Kang", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc11en/1.png", 1, []string{"Temporal"}, []string{}},
-		{"Drang", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc16en/58.png", 1, []string{"Band of Badoon"}, []string{"Ship Command"}},
-		{"The Collector (Infiltrate the Museum)", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc16en/70.png", 1, []string{"Menagerie Medley"}, []string{"Galactic Artifacts"}},
-		{"The Collector (Escape the Museum)", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc16en/80A.png", 1, []string{"Menagerie Medley"}, []string{"Galactic Artifacts"}},
-		{"Nebula", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc16en/88.png", 1, []string{"Space Pirates"}, []string{"Ship Command", "The Power Stone"}},
-		{"Ronan", "https://marvel-champions-cards.s3.us-west-2.amazonaws.com/mc16en/103.png", 1, []string{"Kree Militants"}, []string{"Ship Command", "The Power Stone"}},
-	}
-	Modules = []string{
-		"Bomb Scare",
-		"Masters of Evil",
-		"Under Attack",
-		"Legions of Hydra",
-		"The Doomsday Chair",
-		"Goblin Gimmicks",
-		"A Mess of Things",
-		"Running Interference",
-		"Power Drain",
-		"Weapon Master",
-		"Hydra Assault",
-		"Hydra Patrol",
-		"Temporal",
-		"Master of Time",
-		"Anachronauts",
-		"Band of Badoon",
-		"Menagerie Medley",
-		"Space Pirates",
-		"Kree Militants",
-	}
 )
 
 // CardHandler serves the "card" slash command and subcommands.
@@ -131,7 +55,7 @@ func (srv *Server) CardHandler(s *discordgo.Session, i *discordgo.InteractionCre
 	// the card database and putting together a combined image may take longer.
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-		Data: &discordgo.InteractionApplicationCommandResponseData{
+		Data: &discordgo.InteractionResponseData{
 			// Flags: uint64(64),
 		},
 	})
@@ -147,27 +71,30 @@ func (srv *Server) CardHandler(s *discordgo.Session, i *discordgo.InteractionCre
 	}
 
 	// Identify the subcommand and respond accordingly
-	switch i.Data.Options[0].Name {
+	switch i.ApplicationCommandData().Options[0].Name {
 	case "image":
 		// Log the request
-		srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: card image %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, i.Data.Options[0].Options[0].StringValue()))
-		commands := strings.SplitN(i.Data.Options[0].Options[0].StringValue(), ";", -1)
-		matchedCards := []*card.Card{}
-		unmatchedCards := []string{}
-		for _, command := range commands {
-			// Search for matching cards and append them to the slice
-			filter, query := splitCommand(command)
-			cards := findCards(filter, query, srv.Cards)
-			if len(cards) == 0 {
-				unmatchedCards = append(unmatchedCards, query)
-				break
+		srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: card image %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, i.ApplicationCommandData().Options[0].Options[0].StringValue()))
+		commands := strings.SplitN(i.ApplicationCommandData().Options[0].Options[0].StringValue(), ";", -1)
+		opts := layoutOptions{}
+		for _, option := range i.ApplicationCommandData().Options[0].Options[1:] {
+			switch option.Name {
+			case "captions":
+				opts.Captions = option.BoolValue()
+			case "side":
+				// The side applies to every card that was not given a side of its own, e.g. Spider-Man#B
+				for n, command := range commands {
+					if _, side := splitSide(command); side == "" {
+						commands[n] = command + "#" + option.StringValue()
+					}
+				}
 			}
-			matchedCards = append(matchedCards, cards...)
 		}
+		matchedCards, ambiguousQueries, unmatchedCards := srv.matchCardCommands(commands)
 		// If there are any queries that failed, we need to notify the user.
 		// For successful queries, we need to return an attachment (or multiple attachments)
 		// for use in a Discord Embed.
-		if len(unmatchedCards) > 0 && len(matchedCards) == 0 {
+		if len(unmatchedCards) > 0 && len(matchedCards) == 0 && len(ambiguousQueries) == 0 {
 			// TODO - Log what we failed to match
 			var content, tense, failedQueries string
 			if len(unmatchedCards) > 1 {
@@ -187,35 +114,19 @@ func (srv *Server) CardHandler(s *discordgo.Session, i *discordgo.InteractionCre
 				Content: content,
 			})
 			return
-		} else if len(unmatchedCards) > 0 && len(matchedCards) > 0 {
-			// TODO - implement me
-			// TODO - Log what we failed to match
-		} else if len(unmatchedCards) == 0 && len(matchedCards) > 0 {
-			// We want to return the horizontal and vertical images separately
-			horizontalCards := []*card.Card{}
-			verticalCards := []*card.Card{}
-			for _, c := range matchedCards {
-				if c.Horizontal == true {
-					horizontalCards = append(horizontalCards, c)
-				} else {
-					verticalCards = append(verticalCards, c)
-				}
-			}
-			horizontalImage, cardsWithErrors, err := buildImage(horizontalCards)
-			verticalImage, cardsWithErrors, err := buildImage(verticalCards)
+		}
+		// Ask the user to choose between the candidates for any ambiguous queries
+		menu := "image"
+		if opts.Captions {
+			menu = "image:captions"
+		}
+		srv.sendCardMenus(s, i, menu, ambiguousQueries, len(matchedCards) == 0)
+		// Any queries that failed are reported alongside the menus and images for the others
+		srv.sendUnmatchedCards(s, i, unmatchedCards)
+		if len(matchedCards) > 0 {
+			batches, cardsWithErrors := buildCardFiles(matchedCards, srv.Images, opts)
 			// We will return the images to the sender
-			if horizontalImage != "" || verticalImage != "" {
-				var horizontalFileName, verticalFileName string
-				if horizontalImage != "" {
-					horizontalFileName = fmt.Sprintf("images/%s", horizontalImage)
-				}
-				if verticalImage != "" {
-					verticalFileName = fmt.Sprintf("images/%s", verticalImage)
-				}
-				horizontalFile, horizontalErr := os.Open(horizontalFileName)
-				defer horizontalFile.Close()
-				verticalFile, verticalErr := os.Open(verticalFileName)
-				defer verticalFile.Close()
+			if len(batches) > 0 {
 				// FIXME - This has to be a FollowupMessageCreate to allow for attachments. If Discord later allows us to add an attachment to the original message, we'll use that methodology.
 				var content, tense string
 				if len(matchedCards)-len(cardsWithErrors) > 1 {
@@ -232,28 +143,24 @@ func (srv *Server) CardHandler(s *discordgo.Session, i *discordgo.InteractionCre
 				s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
 					Content: content,
 				})
-				// Determine which files to return
-				files := []*discordgo.File{}
-				if horizontalErr == nil {
-					x := &discordgo.File{
-						Name:        horizontalFileName,
-						Reader:      horizontalFile,
-						ContentType: "image/jpeg",
+				// Send a message with the attachments, noting any images that are still being retrieved. Images too
+				// large to upload together are sent in several messages.
+				for n, files := range batches {
+					params := &discordgo.WebhookParams{
+						Files: files,
 					}
-					files = append(files, x)
-				}
-				if verticalErr == nil {
-					x := &discordgo.File{
-						Name:        verticalFileName,
-						Reader:      verticalFile,
-						ContentType: "image/jpeg",
+					if n == 0 {
+						params.Content = describeUnavailableCards(i.Interaction.Member.User.ID, cardsWithErrors)
+					}
+					// Offer to flip double-sided cards once all of the images have been sent
+					if n == len(batches)-1 {
+						params.Components = flipComponents(matchedCards, opts)
+					}
+					_, err = s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, params)
+					if err != nil {
+						break
 					}
-					files = append(files, x)
 				}
-				// Send a message with the attachment
-				_, err = s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
-					Files: files,
-				})
 				if err == nil {
 					time.Sleep(time.Second * 10)
 					err = s.InteractionResponseDelete(s.State.User.ID, i.Interaction)
@@ -263,58 +170,112 @@ func (srv *Server) CardHandler(s *discordgo.Session, i *discordgo.InteractionCre
 				} else {
 					srv.Logger.Error(fmt.Sprintf("error sending attachment - %v", err))
 				}
-				// We need to delete the temporary file now
-				os.Remove(fmt.Sprintf("images/%s", horizontalFile))
-				os.Remove(fmt.Sprintf("images/%s", verticalFile))
+			} else {
+				s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+					Content: describeUnavailableCards(i.Interaction.Member.User.ID, cardsWithErrors),
+				})
 			}
 		}
 	case "link":
 		// Log the request
-		srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: card link %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, i.Data.Options[0].Options[0].StringValue()))
-		commands := strings.SplitN(i.Data.Options[0].Options[0].StringValue(), ";", -1)
-		matchedCards := []*card.Card{}
-		unmatchedCards := []string{}
-		for _, command := range commands {
-			// Search for matching cards and append them to the slice
-			filter, query := splitCommand(command)
-			cards := findCards(filter, query, srv.Cards)
-			if len(cards) == 0 {
-				unmatchedCards = append(unmatchedCards, query)
-				break
-			}
-			matchedCards = append(matchedCards, cards...)
-		}
-		embeds := []*discordgo.MessageEmbed{}
-		for _, c := range matchedCards {
-			for _, f := range c.Faces {
-				var link string
-				if f.MarvelCDBURL != nil {
-					link = fmt.Sprintf("[%s](%s)", f.Name, *f.MarvelCDBURL)
-				} else {
-					link = fmt.Sprintf("No MarvelCDB link was found for %s. Either the card does not exist yet in MarvelCDB, or the S.H.I.E.L.D. database is out of date.", f.Name)
-				}
-				embed := &discordgo.MessageEmbed{
-					Thumbnail: &discordgo.MessageEmbedThumbnail{
-						URL: *f.ImageURL,
-					},
-					Fields: []*discordgo.MessageEmbedField{
-						{
-							Name:  f.Name,
-							Value: link,
-						},
-					},
-				}
-				embeds = append(embeds, embed)
+		srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: card link %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, i.ApplicationCommandData().Options[0].Options[0].StringValue()))
+		commands := strings.SplitN(i.ApplicationCommandData().Options[0].Options[0].StringValue(), ";", -1)
+		matchedCards, ambiguousQueries, unmatchedCards := srv.matchCardCommands(commands)
+		if len(matchedCards) == 0 && len(ambiguousQueries) == 0 {
+			s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+				Content: describeUnmatchedCards(i.Interaction.Member.User.ID, unmatchedCards),
+			})
+			return
+		}
+		// Ask the user to choose between the candidates for any ambiguous queries
+		srv.sendCardMenus(s, i, "link", ambiguousQueries, len(matchedCards) == 0)
+		if len(matchedCards) > 0 {
+			matchedCards, content := limitCards(i.Interaction.Member.User.ID, matchedCards)
+			srv.sendCardEmbeds(s, i, content, cardLinkEmbeds(matchedCards))
+		}
+		srv.sendUnmatchedCards(s, i, unmatchedCards)
+	case "info":
+		// Log the request
+		srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: card info %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, i.ApplicationCommandData().Options[0].Options[0].StringValue()))
+		commands := strings.SplitN(i.ApplicationCommandData().Options[0].Options[0].StringValue(), ";", -1)
+		matchedCards, ambiguousQueries, unmatchedCards := srv.matchCardCommands(commands)
+		// Ask the user to choose between the candidates for any ambiguous queries
+		srv.sendCardMenus(s, i, "info", ambiguousQueries, len(matchedCards) == 0)
+		if len(matchedCards) == 0 {
+			if len(ambiguousQueries) == 0 {
+				s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+					Content: describeUnmatchedCards(i.Interaction.Member.User.ID, unmatchedCards),
+				})
+			} else {
+				srv.sendUnmatchedCards(s, i, unmatchedCards)
 			}
+			return
 		}
-		err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
-			Embeds: embeds,
+		// Any queries that failed are reported once the records have been sent
+		defer srv.sendUnmatchedCards(s, i, unmatchedCards)
+		matchedCards, content := limitCards(i.Interaction.Member.User.ID, matchedCards)
+		srv.sendCardEmbeds(s, i, content, cardInfoEmbeds(matchedCards, srv.Renderer))
+	default:
+		// Do the thing
+	}
+}
+
+// SearchHandler serves the "search" slash command, which searches the rules text of cards.
+func (srv *Server) SearchHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error replying to interaction - %v", err))
+		return
+	}
+	query := i.ApplicationCommandData().Options[0].StringValue()
+	srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: search %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, query))
+
+	// Return the best matches to the user
+	matches := srv.Cards.SearchText(query, SearchResultLimit)
+	if len(matches) == 0 {
+		_, err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+			Content: fmt.Sprintf(
+				"Agent <@%s>, the S.H.I.E.L.D. database has no records of cards matching:\n%s\n\nPlease notify Director <@%s> if you believe this to be an error.",
+				i.Interaction.Member.User.ID,
+				query,
+				Director,
+			),
 		})
 		if err != nil {
-			fmt.Println(err)
+			srv.Logger.Error(fmt.Sprintf("error editing search: %v", err))
 		}
-	default:
-		// Do the thing
+		return
+	}
+	fields := []*discordgo.MessageEmbedField{}
+	for _, match := range matches {
+		name := match.Card.Names[0]
+		if len(match.Card.Packs) > 0 {
+			name = fmt.Sprintf("%s (%s)", name, match.Card.Packs[0].Name)
+		}
+		value := srv.Renderer.Render(textSnippet(match.Card, query, 200))
+		if len(match.Card.Faces) > 0 && match.Card.Faces[0].MarvelCDBURL != nil {
+			value += fmt.Sprintf("\n[MarvelCDB](%s)", *match.Card.Faces[0].MarvelCDBURL)
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  name,
+			Value: value,
+		})
+	}
+	_, err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       "Search Results",
+				Description: fmt.Sprintf("S.H.I.E.L.D. archives matching \"%s\"", query),
+				Color:       Basic,
+				Fields:      fields,
+			},
+		},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error editing search: %v", err))
 	}
 }
 
@@ -322,7 +283,7 @@ func (srv *Server) CardHandler(s *discordgo.Session, i *discordgo.InteractionCre
 func (srv *Server) MissionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
-		Data: &discordgo.InteractionApplicationCommandResponseData{
+		Data: &discordgo.InteractionResponseData{
 			Flags: uint64(64),
 		},
 	})
@@ -331,108 +292,105 @@ func (srv *Server) MissionHandler(s *discordgo.Session, i *discordgo.Interaction
 	}
 	srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: mission", i.ID, i.Interaction.Member.User.Username, i.GuildID))
 	// Since there are no subcommands, we can jump straight into options
-	// These options are all required
-	playerCount := i.Data.Options[0].IntValue()
-	randomizeHeroes := i.Data.Options[1].BoolValue()
-	randomizeAspects := i.Data.Options[2].BoolValue()
-	randomizeVillain := i.Data.Options[3].BoolValue()
-	randomizeModules := i.Data.Options[4].BoolValue()
-	// These options are optional
+	var playerCount int64
+	var randomizeHeroes, randomizeAspects, randomizeVillain, randomizeModules bool
 	var avoidDuplicateAspects = true
-	if len(i.Data.Options) >= 6 {
-		avoidDuplicateAspects = i.Data.Options[5].BoolValue()
-	}
 	var modularCount int64 = -1
-	if len(i.Data.Options) >= 7 {
-		modularCount = i.Data.Options[6].IntValue()
-	}
-
-	// Player holds the Hero/Aspect selections for a player
-	type Player struct {
-		Hero    *Hero     `json:"hero,omitempty"`
-		Aspects []*Aspect `json:"aspects,omitempty"`
-	}
-
-	// Add the players
-	players := []*Player{}
-	for player := int64(0); player < playerCount; player++ {
-		player := &Player{}
-		players = append(players, player)
-	}
-	// Create the seed
-	rand.Seed(time.Now().UnixNano())
-	// Determine the Hero for each player
-	if randomizeHeroes == true {
-		heroes := make([]*Hero, len(Heroes))
-		copy(heroes, Heroes)
-		for k, _ := range players {
-			i := rand.Intn(len(heroes))
-			hero := heroes[i]
-			players[k].Hero = hero
-			heroes = removeHeroIndex(heroes, i)
-		}
-	}
-	// Determine the Aspect for each player
-	if randomizeAspects == true {
-		aspects := make([]*Aspect, len(Aspects))
-		copy(aspects, Aspects)
-		for p, v := range players {
-			i := rand.Intn(len(aspects))
-			aspect := aspects[i]
-			players[p].Aspects = append(players[p].Aspects, aspect)
-			// Spider-Woman has a second Aspect
-			if v.Hero != nil && v.Hero.Name == "Spider-Woman" {
-				secondaryAspects := make([]*Aspect, len(Aspects))
-				copy(secondaryAspects, Aspects)
-				for k, v := range secondaryAspects {
-					if aspect == v {
-						secondaryAspects = removeAspectIndex(secondaryAspects, k)
-					}
-				}
-				i := rand.Intn(len(secondaryAspects))
-				secondAspect := secondaryAspects[i]
-				players[p].Aspects = append(players[p].Aspects, secondAspect)
-			}
-			if avoidDuplicateAspects == true {
-				aspects = removeAspectIndex(aspects, i)
-			}
+	var useCollection = true
+	var poolWith, include, exclude, difficulty string
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "player-count":
+			playerCount = option.IntValue()
+		case "randomize-heroes":
+			randomizeHeroes = option.BoolValue()
+		case "randomize-aspects":
+			randomizeAspects = option.BoolValue()
+		case "randomize-encounter":
+			randomizeVillain = option.BoolValue()
+		case "randomize-modular-sets":
+			randomizeModules = option.BoolValue()
+		case "avoid-duplicate-aspects":
+			avoidDuplicateAspects = option.BoolValue()
+		case "modular-encounter-count":
+			modularCount = option.IntValue()
+		case "use-collection":
+			useCollection = option.BoolValue()
+		case "pool-collections":
+			poolWith = option.StringValue()
+		case "include":
+			include = option.StringValue()
+		case "exclude":
+			exclude = option.StringValue()
+		case "difficulty":
+			difficulty = option.StringValue()
 		}
 	}
-	// Determine the villain
-	var villain *Villain
-	if randomizeVillain == true {
-		villains := make([]*Villain, len(Villains))
-		copy(villains, Villains)
-		i := rand.Intn(len(villains))
-		villain = villains[i]
-	}
-	// Determine the modular encounter sets
-	encounterModules := []string{}
-	if randomizeModules == true {
-		modules := make([]string, len(Modules))
-		copy(modules, Modules)
-		// How many modules do we need?
-		if modularCount <= -1 {
-			if villain != nil {
-				modularCount = int64(len(villain.RecommendedModules))
-			} else {
-				modularCount = int64(1)
-			}
-		}
-		if modularCount > int64(len(modules)) {
-			modularCount = int64(len(modules))
+	editContent := func(content string) {
+		_, err := s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+			Content: content,
+		})
+		if err != nil {
+			srv.Logger.Error(fmt.Sprintf("error editing mission: %v", err))
 		}
-		// Add random modules to our slice
-		for count := int64(0); count < modularCount; count++ {
-			i := rand.Intn(len(modules))
-			module := modules[i]
-			encounterModules = append(encounterModules, module)
-			modules = removeStringIndex(modules, i)
+	}
+
+	// Only draw from the packs the agents own, once they have told S.H.I.E.L.D. what that is
+	catalog := srv.Missions
+	if useCollection {
+		agents := append([]string{i.Interaction.Member.User.ID}, mentionedUsers(poolWith)...)
+		catalog = catalog.Owned(srv.Collections.Pool(agents...))
+	}
+
+	// The server's preferences come first, then the agent's, then whatever they asked for in this mission
+	requested := &MissionPreferences{Include: splitTerms(include), Exclude: splitTerms(exclude)}
+	preferences := srv.Preferences.Guild(i.GuildID).Merge(srv.Preferences.User(i.Interaction.Member.User.ID)).Merge(requested)
+	filter, unknown := srv.Missions.ParseFilter(preferences, srv.Packs)
+	unknownRequested := []string{}
+	for _, term := range unknown {
+		if containsTerm(requested.Include, term) || containsTerm(requested.Exclude, term) {
+			unknownRequested = append(unknownRequested, term)
+		} else {
+			srv.Logger.Warn(fmt.Sprintf("%s: ignoring unknown mission preference %q", i.ID, term))
 		}
 	}
-	if randomizeModules == false && villain != nil {
-		encounterModules = villain.RecommendedModules
+	if len(unknownRequested) > 0 {
+		editContent(fmt.Sprintf(
+			"Agent <@%s>, S.H.I.E.L.D. has no intelligence on %s. Include or exclude heroes, villains, aspects, modular sets, difficulties or packs by name, e.g. Rhino, aspect:Justice, difficulty:Expert or pack:Core Set.",
+			i.Interaction.Member.User.ID,
+			strings.Join(unknownRequested, ", "),
+		))
+		return
+	}
+
+	// Without randomizing them, a modular encounter count adds random modules to the recommended ones
+	moduleMode := RecommendedModules
+	switch {
+	case randomizeModules:
+		moduleMode = RandomModules
+	case modularCount > 0:
+		moduleMode = RecommendedPlusRandomModules
+	}
+	mission, err := catalog.GenerateMission(MissionOptions{
+		Players:               int(playerCount),
+		RandomizeHeroes:       randomizeHeroes,
+		RandomizeAspects:      randomizeAspects,
+		RandomizeVillain:      randomizeVillain,
+		AvoidDuplicateAspects: avoidDuplicateAspects,
+		ModuleMode:            moduleMode,
+		ModuleCount:           int(modularCount),
+		Difficulty:            difficulty,
+	}, filter, rand.New(rand.NewSource(time.Now().UnixNano())))
+	if err != nil {
+		editContent(fmt.Sprintf(
+			"Agent <@%s>, S.H.I.E.L.D. is unable to build a mission: %v. Check your /collection and /preferences, or set use-collection to False.",
+			i.Interaction.Member.User.ID,
+			err,
+		))
+		return
 	}
+	villain, encounterModules, players := mission.Villain, mission.Modules, mission.Players
+
 	// Return the mission to the players
 	embeds := []*discordgo.MessageEmbed{}
 	// The villain module comes first
@@ -440,15 +398,20 @@ func (srv *Server) MissionHandler(s *discordgo.Session, i *discordgo.Interaction
 		fields := []*discordgo.MessageEmbedField{}
 		fields = append(fields, &discordgo.MessageEmbedField{
 			Name:  "Villain",
-			Value: villain.Name,
+			Value: srv.withPackName(villain.Name, villain.SKU),
 		})
+		fields = append(fields, difficultyFields(mission)...)
 		if len(encounterModules) > 0 {
 			moduleNames := strings.Join(encounterModules, ", ")
 			var fieldName string
-			if randomizeModules == true {
-				fieldName = "Encounter Modules"
-			} else {
+			recommended := true
+			for _, m := range encounterModules {
+				recommended = recommended && containsString(villain.RecommendedModules, m)
+			}
+			if recommended {
 				fieldName = "Recommended Encounter Modules"
+			} else {
+				fieldName = "Encounter Modules"
 			}
 			fields = append(fields, &discordgo.MessageEmbedField{
 				Name:  fieldName,
@@ -470,10 +433,15 @@ func (srv *Server) MissionHandler(s *discordgo.Session, i *discordgo.Interaction
 			},
 			Fields: fields,
 			Footer: &discordgo.MessageEmbedFooter{
-				Text: "Known issues:\n- Thumbnails don't always load\n- Encounter and Required modules sometimes overlap\n- Missing support for filtering out unwanted Villains/Encounter Modules",
+				Text: "Known issues:\n- Thumbnails don't always load",
 			},
 		}
 		embeds = append(embeds, embed)
+	} else if mission.Difficulty != nil {
+		embeds = append(embeds, &discordgo.MessageEmbed{
+			Title:  "The Mission",
+			Fields: difficultyFields(mission),
+		})
 	}
 	for k, v := range players {
 		fields := []*discordgo.MessageEmbedField{}
@@ -483,7 +451,7 @@ func (srv *Server) MissionHandler(s *discordgo.Session, i *discordgo.Interaction
 			thumbnail = v.Hero.Image
 			field := &discordgo.MessageEmbedField{
 				Name:  "Hero",
-				Value: v.Hero.Name,
+				Value: srv.withPackName(v.Hero.Name, v.Hero.SKU),
 			}
 			fields = append(fields, field)
 		}
@@ -492,16 +460,18 @@ func (srv *Server) MissionHandler(s *discordgo.Session, i *discordgo.Interaction
 		if len(v.Aspects) > 0 {
 			color = v.Aspects[0].Color
 		}
-		if len(v.Aspects) == 1 {
+		// Heroes such as Adam Warlock play with every aspect at once
+		if len(v.Aspects) > 0 {
+			names := []string{}
+			for _, aspect := range v.Aspects {
+				names = append(names, aspect.Name)
+			}
 			field := &discordgo.MessageEmbedField{
 				Name:  "Aspect",
-				Value: v.Aspects[0].Name,
+				Value: strings.Join(names, "/"),
 			}
-			fields = append(fields, field)
-		} else if len(v.Aspects) == 2 {
-			field := &discordgo.MessageEmbedField{
-				Name:  "Aspects",
-				Value: fmt.Sprintf("%s/%s", v.Aspects[0].Name, v.Aspects[1].Name),
+			if len(names) > 1 {
+				field.Name = "Aspects"
 			}
 			fields = append(fields, field)
 		}
@@ -513,10 +483,648 @@ func (srv *Server) MissionHandler(s *discordgo.Session, i *discordgo.Interaction
 		}
 		embeds = append(embeds, embed)
 	}
-	err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+	_, err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
 		Embeds: embeds,
 	})
 	if err != nil {
 		srv.Logger.Error(fmt.Sprintf("error editing mission: %v", err))
 	}
 }
+
+// CollectionHandler serves the "collection" slash command, which records the packs a user owns so that missions are
+// only drawn from them.
+func (srv *Server) CollectionHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	subcommand := i.ApplicationCommandData().Options[0]
+	var packNames string
+	for _, option := range subcommand.Options {
+		if option.Name == "packs" {
+			packNames = option.StringValue()
+		}
+	}
+	userID := i.Interaction.Member.User.ID
+	srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: collection %s %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, subcommand.Name, packNames))
+
+	var content string
+	switch subcommand.Name {
+	case "add", "remove":
+		packs, unknown := resolvePacks(packNames, srv.Packs)
+		skus := []string{}
+		for _, p := range packs {
+			skus = append(skus, p.SKU)
+		}
+		messages := []string{}
+		if len(unknown) > 0 {
+			messages = append(messages, fmt.Sprintf("Agent <@%s>, the S.H.I.E.L.D. database has no records of the following packs:\n%s", userID, strings.Join(unknown, "\n")))
+		}
+		if len(skus) > 0 {
+			var err error
+			var change string
+			if subcommand.Name == "add" {
+				err = srv.Collections.Add(userID, skus...)
+				change = "added the following packs to"
+			} else {
+				err = srv.Collections.Remove(userID, skus...)
+				change = "removed the following packs from"
+			}
+			if err != nil {
+				srv.Logger.Error(fmt.Sprintf("error saving collection: %v", err))
+				messages = append(messages, fmt.Sprintf("Agent <@%s>, S.H.I.E.L.D. was unable to update your collection records. Please notify Director <@%s>.", userID, Director))
+			} else {
+				messages = append(messages, fmt.Sprintf("Agent <@%s>, S.H.I.E.L.D. has %s your collection:\n%s", userID, change, describePacks(skus, srv.Packs)))
+			}
+		}
+		content = strings.Join(messages, "\n\n")
+	case "list":
+		if skus := srv.Collections.Get(userID); len(skus) > 0 {
+			content = fmt.Sprintf("Agent <@%s>, S.H.I.E.L.D. has the following packs on record for you:\n%s", userID, describePacks(skus, srv.Packs))
+		} else {
+			content = fmt.Sprintf("Agent <@%s>, S.H.I.E.L.D. has no packs on record for you. Use /collection add to record the packs you own.", userID)
+		}
+	}
+	if content == "" {
+		content = fmt.Sprintf("Agent <@%s>, please tell S.H.I.E.L.D. which packs you mean.", userID)
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: truncate(content, 2000),
+			Flags:   uint64(64),
+		},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error replying to interaction - %v", err))
+	}
+}
+
+// CollectionAutocompleteHandler suggests packs for the "packs" option of the "collection" slash command as the user
+// types. Only the last semi-colon separated pack is completed.
+func (srv *Server) CollectionAutocompleteHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var focused *discordgo.ApplicationCommandInteractionDataOption
+	for _, subcommand := range i.ApplicationCommandData().Options {
+		for _, option := range subcommand.Options {
+			if option.Focused {
+				focused = option
+			}
+		}
+	}
+	if focused == nil {
+		return
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	segments := strings.Split(focused.StringValue(), ";")
+	prefix := strings.Join(segments[:len(segments)-1], ";")
+	if prefix != "" {
+		prefix += ";"
+	}
+	for _, p := range suggestPacks(segments[len(segments)-1], srv.Packs, AutocompleteLimit) {
+		value := prefix + p.SKU
+		// Discord limits choice names and values to 100 characters
+		if len(value) > 100 {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: truncate(fmt.Sprintf("%s (%s)", p.Name, p.SKU), 100), Value: value})
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error responding to autocomplete - %v", err))
+	}
+}
+
+// PreferencesHandler serves the "preferences" slash command, which records what a user or a whole server always
+// includes in or excludes from their missions. Changing the server's preferences requires the Manage Server permission.
+func (srv *Server) PreferencesHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	subcommand := i.ApplicationCommandData().Options[0]
+	var terms, scope string
+	for _, option := range subcommand.Options {
+		switch option.Name {
+		case "terms":
+			terms = option.StringValue()
+		case "scope":
+			scope = option.StringValue()
+		}
+	}
+	userID := i.Interaction.Member.User.ID
+	srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: preferences %s %s %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, subcommand.Name, scope, terms))
+
+	preferences, set := srv.Preferences.User(userID), func(p *MissionPreferences) error {
+		return srv.Preferences.SetUser(userID, p)
+	}
+	whose := "your"
+	if scope == "server" {
+		preferences, set = srv.Preferences.Guild(i.GuildID), func(p *MissionPreferences) error {
+			return srv.Preferences.SetGuild(i.GuildID, p)
+		}
+		whose = "this server's"
+	}
+
+	var content string
+	switch {
+	case subcommand.Name == "show":
+		content = fmt.Sprintf(
+			"Agent <@%s>, S.H.I.E.L.D. has the following mission preferences on record.\n\n**Server**\n%s\n\n**Yours**\n%s",
+			userID,
+			describePreferences(srv.Preferences.Guild(i.GuildID)),
+			describePreferences(srv.Preferences.User(userID)),
+		)
+	case scope == "server" && i.Interaction.Member.Permissions&discordgo.PermissionManageServer == 0:
+		content = fmt.Sprintf("Agent <@%s>, only agents who can manage this server may change its mission preferences.", userID)
+	case subcommand.Name != "clear" && len(splitTerms(terms)) == 0:
+		content = fmt.Sprintf("Agent <@%s>, please tell S.H.I.E.L.D. what to %s.", userID, subcommand.Name)
+	case subcommand.Name == "clear":
+		if err := set(nil); err != nil {
+			srv.Logger.Error(fmt.Sprintf("error saving preferences: %v", err))
+			content = fmt.Sprintf("Agent <@%s>, S.H.I.E.L.D. was unable to clear %s mission preferences. Please notify Director <@%s>.", userID, whose, Director)
+			break
+		}
+		content = fmt.Sprintf("Agent <@%s>, S.H.I.E.L.D. has cleared %s mission preferences.", userID, whose)
+	default:
+		changed := &MissionPreferences{}
+		if subcommand.Name == "include" {
+			changed.Include = splitTerms(terms)
+		} else {
+			changed.Exclude = splitTerms(terms)
+		}
+		if _, unknown := srv.Missions.ParseFilter(changed, srv.Packs); len(unknown) > 0 {
+			content = fmt.Sprintf(
+				"Agent <@%s>, S.H.I.E.L.D. has no intelligence on %s. Include or exclude heroes, villains, aspects, modular sets, difficulties or packs by name, e.g. Rhino, aspect:Justice, difficulty:Expert or pack:Core Set.",
+				userID,
+				strings.Join(unknown, ", "),
+			)
+			break
+		}
+		preferences = preferences.Merge(changed)
+		if err := set(preferences); err != nil {
+			srv.Logger.Error(fmt.Sprintf("error saving preferences: %v", err))
+			content = fmt.Sprintf("Agent <@%s>, S.H.I.E.L.D. was unable to save %s mission preferences. Please notify Director <@%s>.", userID, whose, Director)
+			break
+		}
+		content = fmt.Sprintf("Agent <@%s>, S.H.I.E.L.D. has updated %s mission preferences:\n%s", userID, whose, describePreferences(preferences))
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: truncate(content, 2000),
+			Flags:   uint64(64),
+		},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error replying to interaction - %v", err))
+	}
+}
+
+// withPackName adds the name of the pack a hero or villain comes from, so players know which box to take it from, e.g.
+// Rhino (Core Set).
+func (srv *Server) withPackName(name string, sku string) string {
+	if p := srv.Packs.BySKU(sku); p != nil {
+		return fmt.Sprintf("%s (%s)", name, p.Name)
+	}
+	return name
+}
+
+// RuleHandler serves the "rule" slash command, which displays a rule from the Rules Reference.
+func (srv *Server) RuleHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name, version string
+	for _, option := range i.ApplicationCommandData().Options {
+		switch option.Name {
+		case "name":
+			name = option.StringValue()
+		case "version":
+			version = strings.TrimPrefix(option.StringValue(), "v")
+		}
+	}
+	srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s requested: rule %s %s", i.ID, i.Interaction.Member.User.Username, i.GuildID, name, version))
+
+	// Find the rule, and the requested version of it
+	r, candidates := findRule(name, srv.Rules)
+	var content string
+	switch {
+	case len(candidates) > 0:
+		content = fmt.Sprintf(
+			"Agent <@%s>, the S.H.I.E.L.D. database has several records for the following query:\n%s",
+			i.Interaction.Member.User.ID,
+			describeCandidates(name, candidates),
+		)
+	case r == nil:
+		content = fmt.Sprintf(
+			"Agent <@%s>, the S.H.I.E.L.D. database has no records of rules matching:\n%s\n\nPlease notify Director <@%s> if you believe this to be an error.",
+			i.Interaction.Member.User.ID,
+			name,
+			Director,
+		)
+	case version != "" && srv.Rules.Get(r.Name, version) == nil:
+		content = fmt.Sprintf(
+			"Agent <@%s>, the S.H.I.E.L.D. database has no records of %s in version %s of the rules.",
+			i.Interaction.Member.User.ID,
+			r.Name,
+			version,
+		)
+	case version != "":
+		r = srv.Rules.Get(r.Name, version)
+	}
+	if content != "" {
+		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+			},
+		})
+		if err != nil {
+			srv.Logger.Error(fmt.Sprintf("error replying to interaction - %v", err))
+		}
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{ruleEmbed(r, srv.Rules)},
+			Components: ruleComponents(r, srv.Rules),
+		},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error replying to interaction - %v", err))
+	}
+}
+
+// RuleAutocompleteHandler suggests rule names, and the versions available for the chosen rule, as the user types.
+func (srv *Server) RuleAutocompleteHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var name string
+	var focused *discordgo.ApplicationCommandInteractionDataOption
+	for _, option := range i.ApplicationCommandData().Options {
+		if option.Name == "name" {
+			name = option.StringValue()
+		}
+		if option.Focused {
+			focused = option
+		}
+	}
+	if focused == nil {
+		return
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	switch focused.Name {
+	case "name":
+		for _, n := range suggestRules(name, srv.Rules, AutocompleteLimit) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: n, Value: n})
+		}
+	case "version":
+		if r, _ := findRule(name, srv.Rules); r != nil {
+			for _, version := range srv.Rules.Versions(r.Name) {
+				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: "v" + version, Value: version})
+			}
+		}
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error responding to autocomplete - %v", err))
+	}
+}
+
+// RuleComponentHandler serves the "See also" buttons on rule embeds, replacing the embed with the related rule.
+func (srv *Server) RuleComponentHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// Custom IDs are in the form rule:<version>:<name>
+	parts := strings.SplitN(i.MessageComponentData().CustomID, ":", 3)
+	if len(parts) != 3 {
+		return
+	}
+	r := srv.Rules.Get(parts[2], parts[1])
+	if r == nil {
+		r = srv.Rules.Latest(parts[2])
+	}
+	if r == nil {
+		srv.Logger.Error(fmt.Sprintf("unable to find rule for component %s", i.MessageComponentData().CustomID))
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{ruleEmbed(r, srv.Rules)},
+			Components: ruleComponents(r, srv.Rules),
+		},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error replying to interaction - %v", err))
+	}
+}
+
+// CardAutocompleteHandler suggests cards for the "card-names" option of the "card" slash command as the user types.
+// Only the last semi-colon separated name is completed, and the chosen card is selected by its ID so that a choice like
+// Rhino (Core Set · Stage 2) returns that card alone.
+func (srv *Server) CardAutocompleteHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var focused *discordgo.ApplicationCommandInteractionDataOption
+	for _, subcommand := range i.ApplicationCommandData().Options {
+		for _, option := range subcommand.Options {
+			if option.Focused {
+				focused = option
+			}
+		}
+	}
+	if focused == nil {
+		return
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	segments := strings.Split(focused.StringValue(), ";")
+	prefix := strings.Join(segments[:len(segments)-1], ";")
+	if prefix != "" {
+		prefix += ";"
+	}
+	// Structured queries such as type:Ally cost<=2 are left alone, since there is no single card to suggest
+	// Any side requested for the card, e.g. Spider-Man#B, is kept
+	query, side := splitSide(segments[len(segments)-1])
+	if isNameQuery(query) {
+		for _, c := range suggestCards(query, srv.Cards, AutocompleteLimit) {
+			value := prefix + "id:" + c.ID
+			if side != "" {
+				value += "#" + side
+			}
+			// Discord limits choice names and values to 100 characters
+			if len(value) > 100 {
+				continue
+			}
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: truncate(describeCard(c), 100), Value: value})
+		}
+	}
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error responding to autocomplete - %v", err))
+	}
+}
+
+// CardMenuLimit is the maximum number of options in a Discord select menu.
+const CardMenuLimit = 25
+
+// sendCardMenus asks the user to choose between the candidates for each ambiguous query, using a select menu with one
+// option per card. If editOriginal is true, the first menu replaces the deferred response; otherwise every menu is sent
+// as a followup message. The subcommand is recorded in the menu's custom ID so the selection is rendered the same way.
+func (srv *Server) sendCardMenus(s *discordgo.Session, i *discordgo.InteractionCreate, subcommand string, queries []*ambiguousQuery, editOriginal bool) {
+	for n, q := range queries {
+		content := fmt.Sprintf(
+			"Agent <@%s>, the S.H.I.E.L.D. database has several records matching \"%s\". Select the records you need:",
+			i.Interaction.Member.User.ID,
+			q.Query,
+		)
+		components := cardMenuComponents(fmt.Sprintf("card:%s:%d", subcommand, n), q)
+		var err error
+		if editOriginal && n == 0 {
+			_, err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+				Content:    content,
+				Components: components,
+			})
+		} else {
+			_, err = s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
+				Content:    content,
+				Components: components,
+			})
+		}
+		if err != nil {
+			srv.Logger.Error(fmt.Sprintf("error sending card menu - %v", err))
+		}
+	}
+}
+
+// sendCardEmbeds replaces the deferred response with the content and the embeds describing the cards. Discord limits
+// the number and total size of the embeds in a message, so any that don't fit are sent as followups.
+func (srv *Server) sendCardEmbeds(s *discordgo.Session, i *discordgo.InteractionCreate, content string, embeds []*discordgo.MessageEmbed) {
+	for n, batch := range packEmbeds(embeds) {
+		var err error
+		if n == 0 {
+			_, err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, &discordgo.WebhookEdit{
+				Content: content,
+				Embeds:  batch,
+			})
+		} else {
+			_, err = s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
+				Embeds: batch,
+			})
+		}
+		if err != nil {
+			srv.Logger.Error(fmt.Sprintf("error sending card embeds - %v", err))
+			return
+		}
+	}
+}
+
+// sendUnmatchedCards tells the user which card queries matched nothing. It is sent as a followup so that it is kept
+// alongside the menus and records sent for the other queries.
+func (srv *Server) sendUnmatchedCards(s *discordgo.Session, i *discordgo.InteractionCreate, unmatched []string) {
+	if len(unmatched) == 0 {
+		return
+	}
+	_, err := s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
+		Content: describeUnmatchedCards(i.Interaction.Member.User.ID, unmatched),
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error sending unmatched cards - %v", err))
+	}
+}
+
+// CardComponentHandler serves the select menus sent by sendCardMenus, replacing the menu with the chosen cards.
+func (srv *Server) CardComponentHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// Custom IDs are in the form card:<subcommand>:<menu>, or card:image:captions:<menu> for captioned images
+	data := i.MessageComponentData()
+	parts := strings.SplitN(data.CustomID, ":", 3)
+	if len(parts) < 2 {
+		return
+	}
+	opts := layoutOptions{
+		Captions: len(parts) == 3 && strings.HasPrefix(parts[2], "captions:"),
+	}
+	cards := cardsByID(data.Values, srv.Cards)
+	// Menus sent for [[...]] messages may be in direct messages, where the user is not a guild member
+	user := i.Interaction.User
+	if i.Interaction.Member != nil {
+		user = i.Interaction.Member.User
+	}
+	srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s selected: card %s %s", i.ID, user.Username, i.GuildID, parts[1], strings.Join(data.Values, ";")))
+
+	// Building images may take longer than the 3 seconds we have to respond
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error replying to interaction - %v", err))
+		return
+	}
+
+	edit := &discordgo.WebhookEdit{
+		Content: fmt.Sprintf(
+			"Agent <@%s>, we have located the records you requested.",
+			user.ID,
+		),
+		// Remove the menu now that a selection has been made
+		Components: []discordgo.MessageComponent{},
+	}
+	switch parts[1] {
+	case "image":
+		batches, cardsWithErrors := buildCardFiles(cards, srv.Images, opts)
+		if len(cardsWithErrors) > 0 {
+			edit.Content = describeUnavailableCards(user.ID, cardsWithErrors)
+		}
+		if len(batches) == 0 {
+			break
+		}
+		edit.Files = batches[0]
+		if components := flipComponents(cards, opts); components != nil && len(batches) == 1 {
+			edit.Components = components
+		}
+		// Images too large to upload together are sent as followups, once the menu has been replaced
+		defer func() {
+			for _, files := range batches[1:] {
+				_, err := s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
+					Files: files,
+				})
+				if err != nil {
+					srv.Logger.Error(fmt.Sprintf("error sending attachment - %v", err))
+					return
+				}
+			}
+		}()
+	case "link", "info":
+		cards, content := limitCards(user.ID, cards)
+		if content != "" {
+			edit.Content = content
+		}
+		embeds := cardLinkEmbeds(cards)
+		if parts[1] == "info" {
+			embeds = cardInfoEmbeds(cards, srv.Renderer)
+		}
+		batches := packEmbeds(embeds)
+		if len(batches) == 0 {
+			break
+		}
+		edit.Embeds = batches[0]
+		// Embeds that don't fit in the message are sent as followups, once the menu has been replaced
+		defer func() {
+			for _, embeds := range batches[1:] {
+				_, err := s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
+					Embeds: embeds,
+				})
+				if err != nil {
+					srv.Logger.Error(fmt.Sprintf("error sending card embeds - %v", err))
+					return
+				}
+			}
+		}()
+	}
+	_, err = s.InteractionResponseEdit(s.State.User.ID, i.Interaction, edit)
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error editing card menu - %v", err))
+	}
+}
+
+// CardFlipHandler replaces a card image reply with the next side of each double-sided card. Discord does not allow the
+// attachments of a message to be replaced, so the flipped image is sent as a new message and the old one is deleted.
+func (srv *Server) CardFlipHandler(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// Custom IDs are in the form flip:<cards>, or flip:captions:<cards> for captioned images, where <cards> are card IDs
+	// separated by semicolons
+	ids := strings.TrimPrefix(i.MessageComponentData().CustomID, "flip:")
+	opts := layoutOptions{}
+	if strings.HasPrefix(ids, "captions:") {
+		opts.Captions = true
+		ids = strings.TrimPrefix(ids, "captions:")
+	}
+	cards := []*card.Card{}
+	for _, c := range cardsByID(strings.Split(ids, ";"), srv.Cards) {
+		if c.Side != "" {
+			original := srv.Cards.ByID(c.ID)
+			c = original.WithSide(original.NextSide(c.Side))
+		}
+		cards = append(cards, c)
+	}
+	// Images sent for [[...]] messages may be in direct messages, where the user is not a guild member
+	user := i.Interaction.User
+	if i.Interaction.Member != nil {
+		user = i.Interaction.Member.User
+	}
+	srv.Logger.Info(fmt.Sprintf("%s: User %s in Guild %s flipped: card %s", i.ID, user.Username, i.GuildID, ids))
+
+	// Building images may take longer than the 3 seconds we have to respond
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error replying to interaction - %v", err))
+		return
+	}
+
+	batches, cardsWithErrors := buildCardFiles(cards, srv.Images, opts)
+	if len(batches) == 0 {
+		_, err = s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, &discordgo.WebhookParams{
+			Content: describeUnavailableCards(user.ID, cardsWithErrors),
+		})
+		if err != nil {
+			srv.Logger.Error(fmt.Sprintf("error sending message - %v", err))
+		}
+		return
+	}
+	for n, files := range batches {
+		params := &discordgo.WebhookParams{
+			Files: files,
+		}
+		if n == 0 {
+			params.Content = describeUnavailableCards(user.ID, cardsWithErrors)
+		}
+		if n == len(batches)-1 {
+			params.Components = flipComponents(cards, opts)
+		}
+		_, err = s.FollowupMessageCreate(s.State.User.ID, i.Interaction, true, params)
+		if err != nil {
+			srv.Logger.Error(fmt.Sprintf("error sending attachment - %v", err))
+			return
+		}
+	}
+	// Remove the side we flipped from
+	err = s.ChannelMessageDelete(i.ChannelID, i.Message.ID)
+	if err != nil {
+		srv.Logger.Error(fmt.Sprintf("error deleting flipped message - %v", err))
+	}
+}
+
+// cardLinkEmbeds builds an embed for each face of the cards, linking to the face on MarvelCDB.
+func cardLinkEmbeds(cards []*card.Card) []*discordgo.MessageEmbed {
+	embeds := []*discordgo.MessageEmbed{}
+	for _, c := range cards {
+		for _, f := range c.Faces {
+			var link string
+			if f.MarvelCDBURL != nil {
+				link = fmt.Sprintf("[%s](%s)", f.Name, *f.MarvelCDBURL)
+			} else {
+				link = fmt.Sprintf("No MarvelCDB link was found for %s. Either the card does not exist yet in MarvelCDB, or the S.H.I.E.L.D. database is out of date.", f.Name)
+			}
+			embed := &discordgo.MessageEmbed{
+				Fields: []*discordgo.MessageEmbedField{
+					{
+						Name:  f.Name,
+						Value: link,
+					},
+				},
+			}
+			if f.ImageURL != nil {
+				embed.Thumbnail = &discordgo.MessageEmbedThumbnail{
+					URL: *f.ImageURL,
+				}
+			}
+			embeds = append(embeds, embed)
+		}
+	}
+	return embeds
+}
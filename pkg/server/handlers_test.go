@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bytes"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+	"image/color"
+	"io/ioutil"
+	"marvelbot/pkg/card"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fakeDiscord records the requests made to the Discord API, answering each of them with an empty object.
+type fakeDiscord struct {
+	requests []string
+	bodies   []string
+}
+
+func (f *fakeDiscord) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests = append(f.requests, req.Method+" "+req.URL.Path)
+	body := []byte{}
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+	}
+	f.bodies = append(f.bodies, string(body))
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString("{}")),
+		Request:    req,
+	}, nil
+}
+
+// newTestSession returns a session whose requests go to a fakeDiscord rather than to Discord.
+func newTestSession(t *testing.T) (*discordgo.Session, *fakeDiscord) {
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeDiscord{}
+	s.Client = &http.Client{Transport: fake}
+	s.State.User = &discordgo.User{ID: "1"}
+	return s, fake
+}
+
+// newFlipTestServer returns a server with a double-sided Spider-Man whose images are cached.
+func newFlipTestServer(t *testing.T) (*Server, *card.Card) {
+	store := card.NewMemoryStore()
+	spiderMan := &card.Card{
+		Names: []string{"Spider-Man", "Peter Parker"},
+		Packs: []*card.Pack{{Name: "Core Set", SKU: "MC01en"}},
+		Faces: []*card.Face{
+			{Name: "Spider-Man", Type: "Hero", ImageURL: strPtr(card.ImageBaseURL + "mc01en/1A.png")},
+			{Name: "Peter Parker", Type: "Alter-Ego", ImageURL: strPtr(card.ImageBaseURL + "mc01en/1B.png")},
+		},
+	}
+	for _, f := range spiderMan.Faces {
+		key, _ := card.ImageKey(f)
+		store.Put(key, newTestImage(t, color.RGBA{R: 255, A: 255}, 100, 140))
+	}
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	return &Server{
+		Cards:  card.NewCardRepository([]*card.Card{spiderMan}),
+		Images: card.NewImageCache(store, card.NewMemoryStore(), 1),
+		Logger: logger,
+	}, spiderMan
+}
+
+func TestCardFlipHandler_DirectMessage(t *testing.T) {
+	srv, spiderMan := newFlipTestServer(t)
+	s, fake := newTestSession(t)
+
+	// Interactions in direct messages have a user, but no guild member
+	srv.CardFlipHandler(s, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        "2",
+		Type:      discordgo.InteractionMessageComponent,
+		ChannelID: "3",
+		Token:     "token",
+		User:      &discordgo.User{ID: "4", Username: "Agent"},
+		Message:   &discordgo.Message{ID: "5"},
+		Data:      discordgo.MessageComponentInteractionData{CustomID: "flip:" + spiderMan.ID + "#A"},
+	}})
+	got := strings.Join(fake.requests, "\n")
+	if !strings.Contains(got, "/webhooks/1/token") || !strings.Contains(got, "DELETE /api/v9/channels/3/messages/5") {
+		t.Errorf("expected the flipped image to be sent and the old one deleted, got:\n%s", got)
+	}
+}
+
+func TestSendCardMessages_Flip(t *testing.T) {
+	srv, spiderMan := newFlipTestServer(t)
+	s, fake := newTestSession(t)
+
+	m := &discordgo.MessageCreate{Message: &discordgo.Message{ChannelID: "3"}}
+	sendCardMessages(srv, s, m, &discordgo.User{ID: "4"}, []*card.Card{spiderMan.WithSide("A")})
+	if len(fake.bodies) != 1 || !strings.Contains(fake.bodies[0], "flip:"+spiderMan.ID+"#A") {
+		t.Errorf("expected the image to be sent with a flip button, got %q", fake.bodies)
+	}
+}
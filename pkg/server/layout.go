@@ -0,0 +1,248 @@
+package server
+
+import (
+	"fmt"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"marvelbot/pkg/card"
+	"math"
+	"strings"
+)
+
+// The size of a vertical card image, used when none of the cards being laid out have an image to measure.
+const (
+	cardWidth  = 300
+	cardHeight = 419
+)
+
+const (
+	// captionHeight is the height of the band below a card showing its name and pack.
+	captionHeight = 20
+	// targetAspect is the width to height ratio layouts aim for. Discord previews images of any shape, but very long
+	// or very tall images are shrunk until the cards are hard to read.
+	targetAspect = 4.0 / 3.0
+)
+
+var (
+	captionBackground     = color.RGBA{R: 0x20, G: 0x22, B: 0x25, A: 0xff}
+	placeholderBackground = color.RGBA{R: 0x4f, G: 0x54, B: 0x5c, A: 0xff}
+	textColor             = color.White
+)
+
+// layoutOptions configures how card images are laid out.
+type layoutOptions struct {
+	// Captions shows the name and pack of each card below its image.
+	Captions bool
+}
+
+// tile is a single card face to be drawn into a layout. Faces without an image are drawn as a placeholder.
+type tile struct {
+	card  *card.Card
+	face  *card.Face
+	image image.Image
+}
+
+// layoutCards draws the faces of the cards into a single canvas, packing vertical and horizontal cards together.
+// Cards whose images are not cached yet are drawn as placeholders, returned as errors, and downloaded in the
+// background, rather than making the user wait on the download. An error is returned if none of the cards have images.
+func layoutCards(cards []*card.Card, cache *card.ImageCache, opts layoutOptions) (rgba *image.RGBA, cardsWithErrors []*card.Card, err error) {
+	tiles := []*tile{}
+	found := 0
+	for _, c := range cards {
+		faces := []*tile{}
+		if cache.Cached(c) {
+			for _, f := range c.Faces {
+				img, err := cache.Image(f)
+				if err != nil {
+					break
+				}
+				faces = append(faces, &tile{card: c, face: f, image: img})
+			}
+		} else {
+			cache.FetchAsync(c)
+		}
+		// Only use the images once every face has been read, so that double-sided cards are never shown half-finished
+		if len(faces) != len(c.Faces) || len(faces) == 0 {
+			cardsWithErrors = append(cardsWithErrors, c)
+			faces = faces[:0]
+			for _, f := range c.Faces {
+				faces = append(faces, &tile{card: c, face: f})
+			}
+		} else {
+			found++
+		}
+		tiles = append(tiles, faces...)
+	}
+	if found == 0 {
+		return nil, cardsWithErrors, fmt.Errorf("layoutCards: no images found")
+	}
+
+	// Vertical cards are laid out before horizontal ones, so that cards of the same shape share rows
+	ordered := []*tile{}
+	for _, horizontal := range []bool{false, true} {
+		for _, t := range tiles {
+			if t.card.Horizontal == horizontal {
+				ordered = append(ordered, t)
+			}
+		}
+	}
+
+	// Every card is drawn at the same size, rotated for horizontal cards
+	cell := cardSize(ordered)
+	sizes := make([]image.Point, len(ordered))
+	for i, t := range ordered {
+		sizes[i] = cell
+		if t.card.Horizontal {
+			sizes[i] = image.Pt(cell.Y, cell.X)
+		}
+		if opts.Captions {
+			sizes[i].Y += captionHeight
+		}
+	}
+	positions, bounds := packTiles(sizes)
+
+	canvas := image.NewRGBA(image.Rectangle{Max: bounds})
+	for i, t := range ordered {
+		r := image.Rectangle{Min: positions[i], Max: positions[i].Add(sizes[i])}
+		if opts.Captions {
+			caption := image.Rect(r.Min.X, r.Max.Y-captionHeight, r.Max.X, r.Max.Y)
+			r.Max.Y -= captionHeight
+			drawCaption(canvas, caption, describeTile(t))
+		}
+		if t.image == nil {
+			drawPlaceholder(canvas, r, t.face.Name)
+			continue
+		}
+		if t.image.Bounds().Size() == r.Size() {
+			draw.Draw(canvas, r, t.image, t.image.Bounds().Min, draw.Src)
+		} else {
+			xdraw.ApproxBiLinear.Scale(canvas, r, t.image, t.image.Bounds(), draw.Src, nil)
+		}
+	}
+	return canvas, cardsWithErrors, nil
+}
+
+// cardSize returns the size of a vertical card, measured from the first image of the tiles.
+func cardSize(tiles []*tile) image.Point {
+	for _, t := range tiles {
+		if t.image == nil {
+			continue
+		}
+		size := t.image.Bounds().Size()
+		if t.card.Horizontal {
+			return image.Pt(size.Y, size.X)
+		}
+		return size
+	}
+	return image.Pt(cardWidth, cardHeight)
+}
+
+// packTiles arranges tiles of the given sizes into rows, in order, returning the position of each tile and the size of
+// the canvas needed to hold them. Every row width that some run of tiles fills exactly is tried, and the layout that
+// wastes the least space while staying closest to targetAspect is chosen. Wasted space counts double, as an empty
+// corner is more noticeable than a canvas slightly off the ideal shape. Tiles shorter than their row are centered
+// within it.
+func packTiles(sizes []image.Point) (positions []image.Point, bounds image.Point) {
+	area := 0
+	for _, size := range sizes {
+		area += size.X * size.Y
+	}
+	best := math.Inf(1)
+	tried := map[int]bool{}
+	for start := range sizes {
+		width := 0
+		for _, size := range sizes[start:] {
+			width += size.X
+			if tried[width] {
+				continue
+			}
+			tried[width] = true
+			p, b := shelfPack(sizes, width)
+			waste := 1 - float64(area)/float64(b.X*b.Y)
+			score := 2*waste + math.Abs(math.Log(float64(b.X)/float64(b.Y)/targetAspect))
+			if score < best {
+				best, positions, bounds = score, p, b
+			}
+		}
+	}
+	return positions, bounds
+}
+
+// shelfPack places tiles left to right, starting a new row whenever the next tile would make the row wider than width.
+func shelfPack(sizes []image.Point, width int) (positions []image.Point, bounds image.Point) {
+	positions = make([]image.Point, len(sizes))
+	for start := 0; start < len(sizes); {
+		// Find the tiles that fit in this row, and the height of the tallest
+		end, rowWidth, rowHeight := start, 0, 0
+		for end < len(sizes) && (end == start || rowWidth+sizes[end].X <= width) {
+			rowWidth += sizes[end].X
+			if sizes[end].Y > rowHeight {
+				rowHeight = sizes[end].Y
+			}
+			end++
+		}
+		x := 0
+		for i := start; i < end; i++ {
+			positions[i] = image.Pt(x, bounds.Y+(rowHeight-sizes[i].Y)/2)
+			x += sizes[i].X
+		}
+		if rowWidth > bounds.X {
+			bounds.X = rowWidth
+		}
+		bounds.Y += rowHeight
+		start = end
+	}
+	return positions, bounds
+}
+
+// describeTile returns the caption for a tile, e.g. "Rhino - Core Set". The basic font only has ASCII characters, so
+// the separator is a plain hyphen.
+func describeTile(t *tile) string {
+	if len(t.card.Packs) == 0 {
+		return t.face.Name
+	}
+	return fmt.Sprintf("%s - %s", t.face.Name, t.card.Packs[0].Name)
+}
+
+// drawCaption draws a line of text on a dark band, shortened to fit the band.
+func drawCaption(canvas *image.RGBA, r image.Rectangle, text string) {
+	draw.Draw(canvas, r, &image.Uniform{C: captionBackground}, image.Point{}, draw.Src)
+	drawText(canvas, r, text)
+}
+
+// drawPlaceholder draws a tile for a card whose image is unavailable, showing its name.
+func drawPlaceholder(canvas *image.RGBA, r image.Rectangle, name string) {
+	draw.Draw(canvas, r, &image.Uniform{C: placeholderBackground}, image.Point{}, draw.Src)
+	middle := r.Min.Y + r.Dy()/2
+	drawText(canvas, image.Rect(r.Min.X, middle-captionHeight, r.Max.X, middle), name)
+	drawText(canvas, image.Rect(r.Min.X, middle, r.Max.X, middle+captionHeight), "Image unavailable")
+}
+
+// drawText draws a line of text centered within the rectangle, shortened to fit its width.
+func drawText(canvas *image.RGBA, r image.Rectangle, text string) {
+	face := basicfont.Face7x13
+	// Leave a margin of one character either side
+	maxChars := r.Dx()/face.Advance - 2
+	if maxChars < 4 {
+		return
+	}
+	if runes := []rune(text); len(runes) > maxChars {
+		// The basic font has no ellipsis
+		text = strings.TrimSpace(string(runes[:maxChars-3])) + "..."
+	}
+	d := &font.Drawer{
+		Dst:  canvas,
+		Src:  &image.Uniform{C: textColor},
+		Face: face,
+	}
+	width := d.MeasureString(text).Round()
+	x := r.Min.X + (r.Dx()-width)/2
+	y := r.Min.Y + (r.Dy()+face.Ascent-face.Descent)/2
+	d.Dot = fixed.P(x, y)
+	d.DrawString(text)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"image"
+	"image/color"
+	"marvelbot/pkg/card"
+	"testing"
+)
+
+func TestPackTiles(t *testing.T) {
+	vertical, horizontal := image.Pt(300, 419), image.Pt(419, 300)
+	var testCases = []struct {
+		name   string
+		sizes  []image.Point
+		bounds image.Point
+	}{
+		{name: "Single card", sizes: []image.Point{vertical}, bounds: image.Pt(300, 419)},
+		{name: "Two cards side by side", sizes: []image.Point{vertical, vertical}, bounds: image.Pt(600, 419)},
+		{name: "Three cards in a row", sizes: []image.Point{vertical, vertical, vertical}, bounds: image.Pt(900, 419)},
+		{name: "Four cards in a square", sizes: []image.Point{vertical, vertical, vertical, vertical}, bounds: image.Pt(600, 838)},
+		{name: "Six cards in two rows", sizes: []image.Point{vertical, vertical, vertical, vertical, vertical, vertical}, bounds: image.Pt(900, 838)},
+		{name: "Horizontal cards share a row", sizes: []image.Point{vertical, vertical, horizontal, horizontal}, bounds: image.Pt(838, 719)},
+	}
+
+	for _, tt := range testCases {
+		positions, bounds := packTiles(tt.sizes)
+		if bounds != tt.bounds {
+			t.Errorf("%s: expected bounds %v, got %v", tt.name, tt.bounds, bounds)
+		}
+		// No two tiles may overlap, and every tile must be within the bounds
+		for i := range tt.sizes {
+			r := image.Rectangle{Min: positions[i], Max: positions[i].Add(tt.sizes[i])}
+			if !r.In(image.Rectangle{Max: bounds}) {
+				t.Errorf("%s: tile %d at %v is outside of the bounds", tt.name, i, r)
+			}
+			for j := i + 1; j < len(tt.sizes); j++ {
+				other := image.Rectangle{Min: positions[j], Max: positions[j].Add(tt.sizes[j])}
+				if r.Overlaps(other) {
+					t.Errorf("%s: tiles %d and %d overlap", tt.name, i, j)
+				}
+			}
+		}
+	}
+}
+
+func TestLayoutCards(t *testing.T) {
+	red, blue, green := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}, color.RGBA{G: 255, A: 255}
+	store := card.NewMemoryStore()
+	newCard := func(name string, horizontal bool, colors ...color.Color) *card.Card {
+		c := &card.Card{Names: []string{name}, Horizontal: horizontal, Packs: []*card.Pack{{Name: "Core Set"}}}
+		for n, col := range colors {
+			url := card.ImageBaseURL + name + string(rune('A'+n)) + ".png"
+			f := &card.Face{Name: name, ImageURL: strPtr(url)}
+			if col != nil {
+				key, _ := card.ImageKey(f)
+				if horizontal {
+					store.Put(key, newTestImage(t, col, 140, 100))
+				} else {
+					store.Put(key, newTestImage(t, col, 100, 140))
+				}
+			}
+			c.Faces = append(c.Faces, f)
+		}
+		return c
+	}
+	lockjaw := newCard("Lockjaw", false, red)
+	spiderMan := newCard("Spider-Man", false, blue, red)
+	scheme := newCard("The Break-In!", true, green)
+	uncached := newCard("Rhino", false, nil)
+	cache := card.NewImageCache(store, card.NewMemoryStore(), 1)
+
+	var testCases = []struct {
+		name     string
+		cards    []*card.Card
+		opts     layoutOptions
+		width    int
+		height   int
+		errors   int
+		err      bool
+		expected map[image.Point]color.Color
+	}{
+		{name: "Single card", cards: []*card.Card{lockjaw}, width: 100, height: 140,
+			expected: map[image.Point]color.Color{{0, 0}: red},
+		},
+		{name: "Double-sided card", cards: []*card.Card{spiderMan}, width: 200, height: 140,
+			expected: map[image.Point]color.Color{{0, 0}: blue, {100, 0}: red},
+		},
+		{name: "Mixed orientations share a canvas", cards: []*card.Card{scheme, lockjaw}, width: 240, height: 140,
+			expected: map[image.Point]color.Color{{0, 0}: red, {100, 20}: green},
+		},
+		{name: "Uncached cards get a placeholder", cards: []*card.Card{lockjaw, uncached}, width: 200, height: 140, errors: 1,
+			expected: map[image.Point]color.Color{{0, 0}: red, {100, 0}: placeholderBackground},
+		},
+		{name: "Captions", cards: []*card.Card{lockjaw}, opts: layoutOptions{Captions: true}, width: 100, height: 140 + captionHeight,
+			expected: map[image.Point]color.Color{{0, 0}: red, {0, 140 + captionHeight - 1}: captionBackground},
+		},
+		{name: "No cached cards", cards: []*card.Card{uncached}, errors: 1, err: true},
+	}
+
+	for _, tt := range testCases {
+		rgba, cardsWithErrors, err := layoutCards(tt.cards, cache, tt.opts)
+		if err != nil && tt.err == false {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if err == nil && tt.err == true {
+			t.Errorf("%s: missing error", tt.name)
+		}
+		if len(cardsWithErrors) != tt.errors {
+			t.Errorf("%s: expected %d cards with errors, got %d", tt.name, tt.errors, len(cardsWithErrors))
+		}
+		if rgba == nil {
+			continue
+		}
+		if size := rgba.Bounds().Size(); size.X != tt.width || size.Y != tt.height {
+			t.Errorf("%s: expected a %dx%d image, got %dx%d", tt.name, tt.width, tt.height, size.X, size.Y)
+		}
+		for point, expected := range tt.expected {
+			if got := rgba.At(point.X, point.Y); got != expected {
+				t.Errorf("%s: expected %v at %v, got %v", tt.name, expected, point, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package server
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"marvelbot/pkg/card"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Hero is a hero the mission generator can assign to a player.
+type Hero struct {
+	Name  string `json:"name" yaml:"name"`
+	SKU   string `json:"sku" yaml:"sku"`
+	Image string `json:"image" yaml:"image"`
+	// AspectCount is the number of aspects the hero plays with, if not one, e.g. two for Spider-Woman
+	AspectCount int `json:"aspect_count,omitempty" yaml:"aspect_count,omitempty"`
+}
+
+// Villain is the encounter that we will be using
+type Villain struct {
+	Name string `json:"name" yaml:"name"`
+	SKU  string `json:"sku" yaml:"sku"`
+	// Set is the encounter set of the villain's cards in the card data, if not the villain's name
+	Set                string   `json:"set,omitempty" yaml:"set,omitempty"`
+	Image              string   `json:"image,omitempty" yaml:"image,omitempty"`
+	ModuleCount        int      `json:"module_count" yaml:"module_count"`
+	RecommendedModules []string `json:"recommended_modules" yaml:"recommended_modules"`
+	RequiredModules    []string `json:"required_modules" yaml:"required_modules"`
+	// Stages are the names of the villain cards to use with each kind of villain stages, e.g. Rhino I and Rhino II for
+	// Standard. They are found in the card data.
+	Stages map[string][]string `json:"stages,omitempty" yaml:"-"`
+}
+
+// Module is a modular encounter set that can be added to any scenario.
+type Module struct {
+	Name string `json:"name" yaml:"name"`
+	SKU  string `json:"sku" yaml:"sku"`
+}
+
+// Difficulty is a mode of play that missions can be played at, such as Expert or Heroic 2.
+type Difficulty struct {
+	Name string `json:"name" yaml:"name"`
+	// Stages is the kind of villain stages to play against, Standard or Expert
+	Stages string `json:"stages" yaml:"stages"`
+	// Sets are the encounter sets added to the encounter deck, e.g. Standard and Expert
+	Sets []string `json:"sets" yaml:"sets"`
+	// Heroic is the heroic level, the number of extra encounter cards dealt to each player in each villain phase
+	Heroic int `json:"heroic,omitempty" yaml:"heroic,omitempty"`
+}
+
+// MissionCatalog holds the heroes, villains, modules and difficulties that missions are drawn from.
+type MissionCatalog struct {
+	Heroes       []*Hero       `json:"heroes" yaml:"heroes"`
+	Villains     []*Villain    `json:"villains" yaml:"villains"`
+	Modules      []*Module     `json:"modules" yaml:"modules"`
+	Difficulties []*Difficulty `json:"difficulties" yaml:"difficulties"`
+}
+
+// stageNumberRegexp matches the roman numeral that ends the name of a villain stage, e.g. Rhino II, or the version of
+// a villain whose stages are on a single card, e.g. Wrecker A
+var stageNumberRegexp = regexp.MustCompile(`\s([IVX]+|[AB])$`)
+
+// ReadMissionCatalog reads the villains and modules of the mission catalog from a YAML file, such as
+// data/missions.yaml, and fills in the rest from the card data. Every hero card in a published pack is a hero, while
+// heroes in the file only need to list what the cards can't tell us, like a second aspect. Villain images and any
+// missing SKUs are taken from the cards of each encounter set, so adding a pack's card data is enough to make its
+// heroes, villains and modules complete.
+func ReadMissionCatalog(path string, cards *card.CardRepository, packs *card.PackRegistry) (*MissionCatalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", path, err)
+	}
+	catalog := &MissionCatalog{}
+	if err := yaml.Unmarshal(data, catalog); err != nil {
+		return nil, fmt.Errorf("error unmarshaling %s: %w", path, err)
+	}
+	return NewMissionCatalog(catalog, cards, packs), nil
+}
+
+// NewMissionCatalog completes a catalog from the card data, adding a Hero for each hero card in a pack known to the
+// registry, filling in the images, SKUs and stages of villains and modules from the cards of their sets, and leaving
+// out difficulties whose encounter sets have no cards.
+func NewMissionCatalog(catalog *MissionCatalog, cards *card.CardRepository, packs *card.PackRegistry) *MissionCatalog {
+	heroes := map[string]*Hero{}
+	for _, h := range catalog.Heroes {
+		heroes[card.Normalize(h.Name)] = h
+	}
+	for _, c := range cards.All() {
+		if len(c.Names) == 0 || len(c.Packs) == 0 || packs.BySKU(c.Packs[0].SKU) == nil {
+			continue
+		}
+		face := firstFace(c, "Hero")
+		if face == nil {
+			continue
+		}
+		h := heroes[card.Normalize(c.Names[0])]
+		if h == nil {
+			h = &Hero{Name: c.Names[0]}
+			heroes[card.Normalize(h.Name)] = h
+			catalog.Heroes = append(catalog.Heroes, h)
+		}
+		if h.SKU == "" {
+			h.SKU = c.Packs[0].SKU
+		}
+		if h.Image == "" && face.ImageURL != nil {
+			h.Image = *face.ImageURL
+		}
+	}
+	sort.SliceStable(catalog.Heroes, func(i, j int) bool {
+		return catalog.Heroes[i].Name < catalog.Heroes[j].Name
+	})
+
+	for _, v := range catalog.Villains {
+		set := v.Set
+		if set == "" {
+			set = v.Name
+		}
+		for _, c := range cards.BySet(set) {
+			if v.SKU == "" && len(c.Packs) > 0 {
+				v.SKU = c.Packs[0].SKU
+			}
+			if face := firstFace(c, "Villain"); v.Image == "" && face != nil && face.ImageURL != nil {
+				v.Image = *face.ImageURL
+			}
+		}
+		for _, d := range catalog.Difficulties {
+			if stages := villainStages(cards, set, d.Stages); len(stages) > 0 {
+				if v.Stages == nil {
+					v.Stages = map[string][]string{}
+				}
+				v.Stages[d.Stages] = stages
+			}
+		}
+	}
+	for _, m := range catalog.Modules {
+		if sets := cards.BySet(m.Name); m.SKU == "" && len(sets) > 0 && len(sets[0].Packs) > 0 {
+			m.SKU = sets[0].Packs[0].SKU
+		}
+	}
+
+	// Difficulties like Expert II are only offered once their sets are in the card data
+	difficulties := []*Difficulty{}
+	for _, d := range catalog.Difficulties {
+		present := true
+		for _, set := range d.Sets {
+			present = present && len(cards.BySet(set)) > 0
+		}
+		if present {
+			difficulties = append(difficulties, d)
+		}
+	}
+	catalog.Difficulties = difficulties
+	return catalog
+}
+
+// firstFace returns the first face of the card with the given type, e.g. Hero, or nil if there is none.
+func firstFace(c *card.Card, faceType string) *card.Face {
+	for _, f := range c.Faces {
+		if strings.EqualFold(f.Type, faceType) {
+			return f
+		}
+	}
+	return nil
+}
+
+// villainStages returns the names of the villain cards of the set that are used with the kind of stages, which are
+// named after them, e.g. Standard Rhino and Expert Rhino. Some villains have their expert stages in a set of their own,
+// e.g. Expert Kang, and those are named along with that set, e.g. Kang (Immortus) (Expert Kang).
+func villainStages(cards *card.CardRepository, set string, stages string) (names []string) {
+	stageSet := stages + " " + set
+	for _, s := range []string{set, stageSet} {
+		for _, c := range cards.BySet(s) {
+			if firstFace(c, "Villain") == nil || !villainStageOf(c, stages) {
+				continue
+			}
+			name := stageName(c)
+			if s == stageSet {
+				name = fmt.Sprintf("%s (%s)", name, stageSet)
+			}
+			if !containsString(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// villainStageOf returns whether the villain card is used with the kind of stages, i.e. whether it has a name like
+// Expert Rhino.
+func villainStageOf(c *card.Card, stages string) bool {
+	prefix := card.Normalize(stages) + " "
+	for _, name := range c.Names {
+		if strings.HasPrefix(card.Normalize(name), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stageName returns the name of a villain card that tells its stage, e.g. Rhino II, or its first name if none do.
+func stageName(c *card.Card) string {
+	for _, name := range c.Names {
+		if stageNumberRegexp.MatchString(name) {
+			return name
+		}
+	}
+	return c.Names[0]
+}
+
+// ModuleNames returns the names of the catalog's modules.
+func (mc *MissionCatalog) ModuleNames() []string {
+	names := make([]string, 0, len(mc.Modules))
+	for _, m := range mc.Modules {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// MissionSelection is a selection of entries from the catalog, used to include them in or exclude them from missions.
+type MissionSelection struct {
+	Heroes       []*Hero
+	Villains     []*Villain
+	Aspects      []*Aspect
+	Modules      []*Module
+	Difficulties []*Difficulty
+	Packs        []*card.PackInfo
+}
+
+// IsEmpty returns whether nothing is selected.
+func (ms *MissionSelection) IsEmpty() bool {
+	return len(ms.Heroes) == 0 && len(ms.Villains) == 0 && len(ms.Aspects) == 0 && len(ms.Modules) == 0 &&
+		len(ms.Difficulties) == 0 && len(ms.Packs) == 0
+}
+
+// hasPack returns whether the pack with the SKU is selected.
+func (ms *MissionSelection) hasPack(sku string) bool {
+	for _, p := range ms.Packs {
+		if p.SKU == sku {
+			return true
+		}
+	}
+	return false
+}
+
+// MissionFilter narrows down what missions are drawn from. Excluded entries are never drawn, while included heroes,
+// aspects and modules are always part of the mission, and the villain and difficulty are drawn from the included
+// villains and difficulties, if any.
+// When packs are included, they are the only packs that heroes, villains and modules are drawn from.
+type MissionFilter struct {
+	Include MissionSelection
+	Exclude MissionSelection
+}
+
+// missionTermCategories are the prefixes that limit a term to one kind of catalog entry, e.g. villain:Nebula
+var missionTermCategories = []string{"hero", "villain", "aspect", "module", "difficulty", "pack"}
+
+// ParseFilter resolves the terms of the preferences against the catalog, and returns the filter along with any terms
+// that don't match anything. A term is the name of a hero, villain, aspect, modular set or difficulty, and matches each
+// of them by that name, or only one kind of them with a prefix, e.g. villain:Nebula or difficulty:Expert. A pack is
+// matched by its name, SKU or MarvelCDB code with the pack: prefix, or without a prefix when nothing else has the name.
+func (mc *MissionCatalog) ParseFilter(p *MissionPreferences, packs *card.PackRegistry) (*MissionFilter, []string) {
+	filter := &MissionFilter{}
+	var unknown []string
+	if p == nil {
+		return filter, unknown
+	}
+	for _, term := range p.Include {
+		if !mc.selectTerm(&filter.Include, term, packs) {
+			unknown = append(unknown, term)
+		}
+	}
+	for _, term := range p.Exclude {
+		if !mc.selectTerm(&filter.Exclude, term, packs) {
+			unknown = append(unknown, term)
+		}
+	}
+	return filter, unknown
+}
+
+// selectTerm adds the catalog entries matching the term to the selection, and returns whether there were any.
+func (mc *MissionCatalog) selectTerm(selection *MissionSelection, term string, packs *card.PackRegistry) bool {
+	category, name := "", term
+	if parts := strings.SplitN(term, ":", 2); len(parts) == 2 {
+		for _, c := range missionTermCategories {
+			if card.Normalize(parts[0]) == c {
+				category, name = c, parts[1]
+			}
+		}
+	}
+	name = card.Normalize(name)
+	matched := false
+	if category == "" || category == "hero" {
+		for _, h := range mc.Heroes {
+			if card.Normalize(h.Name) == name {
+				selection.Heroes = append(selection.Heroes, h)
+				matched = true
+			}
+		}
+	}
+	if category == "" || category == "villain" {
+		for _, v := range mc.Villains {
+			// The Collector (Escape the Museum) is matched by The Collector, as well as by its full name
+			if n := card.Normalize(v.Name); n == name || strings.HasPrefix(n, name+" (") {
+				selection.Villains = append(selection.Villains, v)
+				matched = true
+			}
+		}
+	}
+	if category == "" || category == "aspect" {
+		for _, a := range Aspects {
+			if card.Normalize(a.Name) == name {
+				selection.Aspects = append(selection.Aspects, a)
+				matched = true
+			}
+		}
+	}
+	if category == "" || category == "module" {
+		for _, m := range mc.Modules {
+			if card.Normalize(m.Name) == name {
+				selection.Modules = append(selection.Modules, m)
+				matched = true
+			}
+		}
+	}
+	if category == "" || category == "difficulty" {
+		for _, d := range mc.Difficulties {
+			if card.Normalize(d.Name) == name {
+				selection.Difficulties = append(selection.Difficulties, d)
+				matched = true
+			}
+		}
+	}
+	if category == "pack" || category == "" && !matched {
+		if p := packs.Lookup(name); p != nil {
+			selection.Packs = append(selection.Packs, p)
+			matched = true
+		}
+	}
+	return matched
+}
+
+// Filter returns a copy of the catalog without the excluded heroes, villains, modules and difficulties, or those from
+// excluded packs. When the filter includes packs, only the heroes, villains and modules from those packs are kept.
+func (mc *MissionCatalog) Filter(f *MissionFilter) *MissionCatalog {
+	if f == nil {
+		return mc
+	}
+	allowed := func(sku string) bool {
+		return (len(f.Include.Packs) == 0 || f.Include.hasPack(sku)) && !f.Exclude.hasPack(sku)
+	}
+	filtered := &MissionCatalog{}
+	for _, d := range mc.Difficulties {
+		if !containsDifficulty(f.Exclude.Difficulties, d) {
+			filtered.Difficulties = append(filtered.Difficulties, d)
+		}
+	}
+	for _, h := range mc.Heroes {
+		if allowed(h.SKU) && !containsHero(f.Exclude.Heroes, h) {
+			filtered.Heroes = append(filtered.Heroes, h)
+		}
+	}
+	for _, v := range mc.Villains {
+		if allowed(v.SKU) && !containsVillain(f.Exclude.Villains, v) {
+			filtered.Villains = append(filtered.Villains, v)
+		}
+	}
+	for _, m := range mc.Modules {
+		if allowed(m.SKU) && !containsModule(f.Exclude.Modules, m) {
+			filtered.Modules = append(filtered.Modules, m)
+		}
+	}
+	return filtered
+}
+
+// ModuleMode is how the modular encounter sets of a mission are chosen.
+type ModuleMode int
+
+const (
+	// RecommendedModules uses the modular encounter sets the villain recommends
+	RecommendedModules ModuleMode = iota
+	// RandomModules draws the modular encounter sets at random
+	RandomModules
+	// RecommendedPlusRandomModules draws modular encounter sets at random on top of the ones the villain recommends
+	RecommendedPlusRandomModules
+)
+
+// RandomDifficulty is the MissionOptions.Difficulty that draws a difficulty at random.
+const RandomDifficulty = "Random"
+
+// MissionOptions are the choices the agents made when requesting a mission.
+type MissionOptions struct {
+	Players               int
+	RandomizeHeroes       bool
+	RandomizeAspects      bool
+	RandomizeVillain      bool
+	AvoidDuplicateAspects bool
+	ModuleMode            ModuleMode
+	// ModuleCount is the number of modular encounter sets to draw at random, or -1 for the default of the ModuleMode.
+	// See SelectModules.
+	ModuleCount int
+	// Difficulty is the name of the difficulty to play, RandomDifficulty to draw one, or empty to draw one only from
+	// the included difficulties
+	Difficulty string
+}
+
+// Player holds the Hero/Aspect selections for a player
+type Player struct {
+	Hero    *Hero     `json:"hero,omitempty"`
+	Aspects []*Aspect `json:"aspects,omitempty"`
+}
+
+// Mission is a mission drawn from the catalog.
+type Mission struct {
+	Villain    *Villain
+	Difficulty *Difficulty
+	// Stages are the names of the villain cards to play against at the difficulty, if known
+	Stages  []string
+	Modules []string
+	Players []*Player
+}
+
+// GenerateMission draws a mission from the catalog, leaving out whatever the filter excludes and always including
+// whatever it includes. An error describing what is missing is returned when too little is left to draw from.
+func (mc *MissionCatalog) GenerateMission(opts MissionOptions, f *MissionFilter, rng *rand.Rand) (*Mission, error) {
+	if f == nil {
+		f = &MissionFilter{}
+	}
+	catalog := mc.Filter(f)
+	mission := &Mission{}
+	for n := 0; n < opts.Players; n++ {
+		mission.Players = append(mission.Players, &Player{})
+	}
+
+	// Determine the Hero for each player, starting with the included heroes
+	if opts.RandomizeHeroes || len(f.Include.Heroes) > 0 {
+		if len(f.Include.Heroes) > opts.Players {
+			return nil, fmt.Errorf("%d heroes are included, but there are only %d players",
+				len(f.Include.Heroes), opts.Players)
+		}
+		heroes := append([]*Hero{}, f.Include.Heroes...)
+		if opts.RandomizeHeroes {
+			pool := []*Hero{}
+			for _, h := range catalog.Heroes {
+				if !containsHero(heroes, h) {
+					pool = append(pool, h)
+				}
+			}
+			if len(heroes)+len(pool) < opts.Players {
+				return nil, fmt.Errorf("%d heroes are needed, but only %d are left to draw from",
+					opts.Players, len(heroes)+len(pool))
+			}
+			for len(heroes) < opts.Players {
+				i := rng.Intn(len(pool))
+				heroes = append(heroes, pool[i])
+				pool = removeHeroIndex(pool, i)
+			}
+		}
+		rng.Shuffle(len(heroes), func(i, j int) {
+			heroes[i], heroes[j] = heroes[j], heroes[i]
+		})
+		for k, h := range heroes {
+			mission.Players[k].Hero = h
+		}
+	}
+
+	// Determine the Aspect for each player, starting with the included aspects
+	if opts.RandomizeAspects || len(f.Include.Aspects) > 0 {
+		aspects := []*Aspect{}
+		for _, a := range Aspects {
+			if !containsAspect(f.Exclude.Aspects, a) {
+				aspects = append(aspects, a)
+			}
+		}
+		included := append([]*Aspect{}, f.Include.Aspects...)
+		for _, player := range mission.Players {
+			var aspect *Aspect
+			switch {
+			case len(included) > 0:
+				aspect, included = included[0], included[1:]
+			case !opts.RandomizeAspects:
+				continue
+			case len(aspects) == 0:
+				return nil, fmt.Errorf("%d aspects are needed, but none are left to draw from", opts.Players)
+			default:
+				aspect = aspects[rng.Intn(len(aspects))]
+			}
+			player.Aspects = append(player.Aspects, aspect)
+			if opts.AvoidDuplicateAspects {
+				for k, a := range aspects {
+					if a == aspect {
+						aspects = removeAspectIndex(aspects, k)
+						break
+					}
+				}
+			}
+			// Some heroes, like Spider-Woman, have more than one Aspect
+			if player.Hero != nil && player.Hero.AspectCount > 1 {
+				secondaryAspects := []*Aspect{}
+				for _, a := range Aspects {
+					if a != aspect && !containsAspect(f.Exclude.Aspects, a) {
+						secondaryAspects = append(secondaryAspects, a)
+					}
+				}
+				for len(player.Aspects) < player.Hero.AspectCount && len(secondaryAspects) > 0 {
+					i := rng.Intn(len(secondaryAspects))
+					player.Aspects = append(player.Aspects, secondaryAspects[i])
+					secondaryAspects = removeAspectIndex(secondaryAspects, i)
+				}
+			}
+		}
+	}
+
+	// Determine the villain, from the included villains if there are any
+	if opts.RandomizeVillain || len(f.Include.Villains) > 0 {
+		villains := f.Include.Villains
+		if len(villains) == 0 {
+			villains = catalog.Villains
+		}
+		if len(villains) == 0 {
+			return nil, fmt.Errorf("a villain is needed, but none are left to draw from")
+		}
+		mission.Villain = villains[rng.Intn(len(villains))]
+	}
+
+	// Determine the difficulty. One the agents asked for comes first, then the included difficulties
+	if opts.Difficulty != "" || len(f.Include.Difficulties) > 0 {
+		difficulties := f.Include.Difficulties
+		if opts.Difficulty != "" && opts.Difficulty != RandomDifficulty {
+			difficulties = nil
+			for _, d := range mc.Difficulties {
+				if card.Normalize(d.Name) == card.Normalize(opts.Difficulty) {
+					difficulties = append(difficulties, d)
+				}
+			}
+			if len(difficulties) == 0 {
+				return nil, fmt.Errorf("the %s difficulty is not available", opts.Difficulty)
+			}
+		} else if len(difficulties) == 0 {
+			difficulties = catalog.Difficulties
+		}
+		if len(difficulties) == 0 {
+			return nil, fmt.Errorf("a difficulty is needed, but none are left to draw from")
+		}
+		mission.Difficulty = difficulties[rng.Intn(len(difficulties))]
+		if mission.Villain != nil {
+			mission.Stages = mission.Villain.Stages[mission.Difficulty.Stages]
+		}
+	}
+
+	// Determine the modular encounter sets
+	modules, err := SelectModules(
+		mission.Villain, catalog.Modules, f.Include.Modules, opts.ModuleMode, opts.ModuleCount, rng,
+	)
+	if err != nil {
+		return nil, err
+	}
+	mission.Modules = modules
+	return mission, nil
+}
+
+// SelectModules chooses the modular encounter sets of a mission against the villain, which may be nil, from the
+// available modules. The villain's required sets and its own encounter sets are never chosen, as they are already part
+// of the scenario, and the included modules are always chosen. Depending on the mode, the modules are:
+//
+//   - RecommendedModules: the modules the villain recommends. Any that aren't available are replaced at random, so that
+//     the villain still gets its ModuleCount.
+//   - RandomModules: count modules drawn at random, or the villain's ModuleCount if count is -1, or one without a
+//     villain. Included modules count towards them.
+//   - RecommendedPlusRandomModules: the recommended modules, plus count modules drawn at random, or one if count is -1.
+//
+// An error is returned when too few modules are left to draw from to make up the number needed.
+func SelectModules(villain *Villain, available []*Module, included []*Module, mode ModuleMode, count int,
+	rng *rand.Rand) ([]string, error) {
+	// The villain's required and built-in sets are part of the scenario already
+	builtIn := []string{}
+	if villain != nil {
+		builtIn = append(builtIn, villain.RequiredModules...)
+		builtIn = append(builtIn, villain.Name)
+		if villain.Set != "" {
+			builtIn = append(builtIn, villain.Set)
+		}
+	}
+	pool := []string{}
+	for _, m := range available {
+		if !containsTerm(builtIn, m.Name) {
+			pool = append(pool, m.Name)
+		}
+	}
+	selected := []string{}
+	choose := func(name string) {
+		if !containsTerm(builtIn, name) && !containsTerm(selected, name) {
+			selected = append(selected, name)
+		}
+		for k := range pool {
+			if card.Normalize(pool[k]) == card.Normalize(name) {
+				pool = removeStringIndex(pool, k)
+				break
+			}
+		}
+	}
+	for _, m := range included {
+		choose(m.Name)
+	}
+
+	// How many modules do we need?
+	var target int
+	switch mode {
+	case RandomModules:
+		target = count
+		if target <= -1 {
+			target = 1
+			if villain != nil {
+				target = villain.ModuleCount
+			}
+		}
+	case RecommendedModules, RecommendedPlusRandomModules:
+		if villain != nil {
+			for _, name := range villain.RecommendedModules {
+				if containsTerm(pool, name) {
+					choose(name)
+				}
+			}
+			target = villain.ModuleCount
+		}
+		if len(selected) > target {
+			target = len(selected)
+		}
+		if mode == RecommendedPlusRandomModules {
+			if count <= -1 {
+				count = 1
+			}
+			target += count
+		}
+	}
+
+	// Add random modules until there are enough
+	for len(selected) < target && len(pool) > 0 {
+		choose(pool[rng.Intn(len(pool))])
+	}
+	if len(selected) < target {
+		return nil, fmt.Errorf("%d modular sets are needed, but only %d are left to draw from", target, len(selected))
+	}
+	return selected, nil
+}
+
+// containsHero returns whether the hero is in the slice.
+func containsHero(heroes []*Hero, h *Hero) bool {
+	for _, other := range heroes {
+		if other == h {
+			return true
+		}
+	}
+	return false
+}
+
+// containsVillain returns whether the villain is in the slice.
+func containsVillain(villains []*Villain, v *Villain) bool {
+	for _, other := range villains {
+		if other == v {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAspect returns whether the aspect is in the slice.
+func containsAspect(aspects []*Aspect, a *Aspect) bool {
+	for _, other := range aspects {
+		if other == a {
+			return true
+		}
+	}
+	return false
+}
+
+// containsDifficulty returns whether the difficulty is in the slice.
+func containsDifficulty(difficulties []*Difficulty, d *Difficulty) bool {
+	for _, other := range difficulties {
+		if other == d {
+			return true
+		}
+	}
+	return false
+}
+
+// containsModule returns whether the module is in the slice.
+func containsModule(modules []*Module, m *Module) bool {
+	for _, other := range modules {
+		if other == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,560 @@
+package server
+
+import (
+	"fmt"
+	"marvelbot/pkg/card"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNewMissionCatalog(t *testing.T) {
+	packs := card.NewPackRegistry([]*card.PackInfo{
+		{Name: "Core Set", SKU: "MC01en", Release: 1},
+		{Name: "Captain America", SKU: "MC04en", Release: 4},
+	})
+	cards := []*card.Card{
+		{
+			Names: []string{"Spider-Man", "Peter Parker"},
+			Packs: []*card.Pack{{Name: "Core Set", SKU: "MC01en"}},
+			Faces: []*card.Face{
+				{Name: "Peter Parker", Type: "Alter-Ego", ImageURL: strPtr(card.ImageBaseURL + "mc01en/1B.png")},
+				{Name: "Spider-Man", Type: "Hero", ImageURL: strPtr(card.ImageBaseURL + "mc01en/1A.png")},
+			},
+		},
+		{
+			Names: []string{"Captain America", "Steve Rogers"},
+			Packs: []*card.Pack{{Name: "Captain America", SKU: "MC04en"}},
+			Faces: []*card.Face{{Name: "Captain America", Type: "Hero", ImageURL: strPtr(card.ImageBaseURL + "mc04en/1A.png")}},
+		},
+		{
+			Names: []string{"Hulk Smash"},
+			Packs: []*card.Pack{{Name: "Memes", SKU: "Memes"}},
+			Faces: []*card.Face{{Name: "Hulk Smash", Type: "Hero"}},
+		},
+		{
+			Names: []string{"Rhino", "Rhino I", "Standard Rhino"},
+			Packs: []*card.Pack{{Name: "Core Set", SKU: "MC01en"}},
+			Sets:  []*card.Set{{Name: "Rhino"}},
+			Faces: []*card.Face{{Name: "Rhino", Type: "Villain", ImageURL: strPtr(card.ImageBaseURL + "mc01en/94.png")}},
+		},
+		{
+			Names: []string{"Rhino", "Rhino II", "Standard Rhino", "Expert Rhino"},
+			Packs: []*card.Pack{{Name: "Core Set", SKU: "MC01en"}},
+			Sets:  []*card.Set{{Name: "Rhino"}},
+			Faces: []*card.Face{{Name: "Rhino", Type: "Villain"}},
+		},
+		{
+			Names: []string{"Kang (Immortus)", "Standard Kang"},
+			Packs: []*card.Pack{{Name: "The Once and Future Kang", SKU: "MC11en"}},
+			Sets:  []*card.Set{{Name: "Kang"}},
+			Faces: []*card.Face{{Name: "Kang (Immortus)", Type: "Villain"}},
+		},
+		{
+			Names: []string{"Kang (Immortus)", "Expert Kang"},
+			Packs: []*card.Pack{{Name: "The Once and Future Kang", SKU: "MC11en"}},
+			Sets:  []*card.Set{{Name: "Expert Kang"}},
+			Faces: []*card.Face{{Name: "Kang (Immortus)", Type: "Villain"}},
+		},
+		{
+			Names: []string{"Wrecker", "Wrecker A", "Standard Wrecking Crew"},
+			Packs: []*card.Pack{{Name: "The Wrecking Crew", SKU: "MC03en"}},
+			Sets:  []*card.Set{{Name: "Wrecking Crew"}},
+			Faces: []*card.Face{{Name: "Wrecker", Type: "Villain"}},
+		},
+		{
+			Names: []string{"Advance"},
+			Packs: []*card.Pack{{Name: "Core Set", SKU: "MC01en"}},
+			Sets:  []*card.Set{{Name: "Standard"}},
+			Faces: []*card.Face{{Name: "Advance", Type: "Treachery"}},
+		},
+		{
+			Names: []string{"Exhaustion"},
+			Packs: []*card.Pack{{Name: "Core Set", SKU: "MC01en"}},
+			Sets:  []*card.Set{{Name: "Expert"}},
+			Faces: []*card.Face{{Name: "Exhaustion", Type: "Treachery"}},
+		},
+		{
+			Names: []string{"Breakin' & Takin'"},
+			Packs: []*card.Pack{{Name: "Core Set", SKU: "MC01en"}},
+			Sets:  []*card.Set{{Name: "Bomb Scare"}},
+			Faces: []*card.Face{{Name: "Breakin' & Takin'", Type: "Treachery"}},
+		},
+	}
+	catalog := NewMissionCatalog(&MissionCatalog{
+		Heroes:   []*Hero{{Name: "Adam Warlock", SKU: "MC21en", AspectCount: 4}, {Name: "Spider-Man", AspectCount: 2}},
+		Villains: []*Villain{{Name: "Rhino"}, {Name: "Thanos", SKU: "MC21en"}, {Name: "Kang"}, {Name: "Wrecking Crew"}},
+		Modules:  []*Module{{Name: "Bomb Scare"}},
+		Difficulties: []*Difficulty{
+			{Name: "Standard", Stages: "Standard", Sets: []string{"Standard"}},
+			{Name: "Standard II", Stages: "Standard", Sets: []string{"Standard II"}},
+			{Name: "Expert", Stages: "Expert", Sets: []string{"Standard", "Expert"}},
+		},
+	}, card.NewCardRepository(cards), packs)
+
+	var testCases = []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "Hero from the card data", got: catalog.Heroes[1].Name + " " + catalog.Heroes[1].SKU, want: "Captain America MC04en"},
+		{name: "Hero image", got: catalog.Heroes[1].Image, want: card.ImageBaseURL + "mc04en/1A.png"},
+		{name: "Hero with a second aspect", got: catalog.Heroes[2].Name + " " + catalog.Heroes[2].SKU, want: "Spider-Man MC01en"},
+		{name: "Hero image from the hero side", got: catalog.Heroes[2].Image, want: card.ImageBaseURL + "mc01en/1A.png"},
+		{name: "Villain image", got: catalog.Villains[0].Image, want: card.ImageBaseURL + "mc01en/94.png"},
+		{name: "Villain SKU", got: catalog.Villains[0].SKU, want: "MC01en"},
+		{name: "Villain without card data", got: catalog.Villains[1].Image + catalog.Villains[1].SKU, want: "MC21en"},
+		{name: "Module SKU", got: catalog.Modules[0].SKU, want: "MC01en"},
+		{name: "Standard stages", got: strings.Join(catalog.Villains[0].Stages["Standard"], ", "), want: "Rhino I, Rhino II"},
+		{name: "Expert stages", got: strings.Join(catalog.Villains[0].Stages["Expert"], ", "), want: "Rhino II"},
+		{name: "Stages without stage names", got: strings.Join(catalog.Villains[2].Stages["Standard"], ", "), want: "Kang (Immortus)"},
+		{name: "Stages in a set of their own", got: strings.Join(catalog.Villains[2].Stages["Expert"], ", "), want: "Kang (Immortus) (Expert Kang)"},
+		{name: "Stages named by version", got: strings.Join(catalog.Villains[3].Stages["Standard"], ", "), want: "Wrecker A"},
+		{name: "Villain without stages", got: strings.Join(catalog.Villains[1].Stages["Standard"], ", "), want: ""},
+	}
+	for _, tt := range testCases {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.name, tt.want, tt.got)
+		}
+	}
+
+	// Difficulties are only offered once their sets are in the card data
+	if len(catalog.Difficulties) != 2 || catalog.Difficulties[1].Name != "Expert" {
+		t.Errorf("expected Standard and Expert, got %d difficulties", len(catalog.Difficulties))
+	}
+
+	// Heroes outside of the published packs are left out, and heroes listed without card data are kept
+	if len(catalog.Heroes) != 3 || catalog.Heroes[0].Name != "Adam Warlock" || catalog.Heroes[2].AspectCount != 2 {
+		t.Errorf("expected Adam Warlock, Captain America and Spider-Man with two aspects, got %d heroes", len(catalog.Heroes))
+	}
+}
+
+// testMissionCatalog returns a small catalog spread across the Core Set and Galaxy's Most Wanted.
+func testMissionCatalog() (*MissionCatalog, *card.PackRegistry) {
+	packs := card.NewPackRegistry([]*card.PackInfo{
+		{Name: "Core Set", MarvelCDBCode: "core", SKU: "MC01en", Release: 1},
+		{Name: "Galaxy's Most Wanted", MarvelCDBCode: "gmw", SKU: "MC09en", Release: 9},
+	})
+	catalog := &MissionCatalog{
+		Heroes: []*Hero{
+			{Name: "Black Panther", SKU: "MC01en"},
+			{Name: "Spider-Man", SKU: "MC01en"},
+			{Name: "Groot", SKU: "MC09en"},
+			{Name: "Rocket Raccoon", SKU: "MC09en"},
+		},
+		Villains: []*Villain{
+			{
+				Name:               "Rhino",
+				SKU:                "MC01en",
+				RecommendedModules: []string{"Bomb Scare"},
+				Stages:             map[string][]string{"Standard": {"Rhino I", "Rhino II"}, "Expert": {"Rhino II", "Rhino III"}},
+			},
+			{Name: "Klaw", SKU: "MC01en", RecommendedModules: []string{"Masters of Evil"}},
+			{Name: "Drang", SKU: "MC09en", RecommendedModules: []string{"Band of Badoon"}},
+			{Name: "The Collector (Escape the Museum)", SKU: "MC09en", RecommendedModules: []string{"Menagerie Medley"}},
+		},
+		Modules: []*Module{
+			{Name: "Bomb Scare", SKU: "MC01en"},
+			{Name: "Masters of Evil", SKU: "MC01en"},
+			{Name: "Band of Badoon", SKU: "MC09en"},
+			{Name: "Menagerie Medley", SKU: "MC09en"},
+		},
+		Difficulties: []*Difficulty{
+			{Name: "Standard", Stages: "Standard", Sets: []string{"Standard"}},
+			{Name: "Expert", Stages: "Expert", Sets: []string{"Standard", "Expert"}},
+			{Name: "Heroic 1", Stages: "Expert", Sets: []string{"Standard", "Expert"}, Heroic: 1},
+		},
+	}
+	return catalog, packs
+}
+
+func TestMissionCatalog_ParseFilter(t *testing.T) {
+	catalog, packs := testMissionCatalog()
+	filter, unknown := catalog.ParseFilter(&MissionPreferences{
+		Include: []string{"groot", "aspect:Justice", "The Collector", "Bomb Scare", "Expert"},
+		Exclude: []string{"pack:Core Set", "villain:Rhino", "Galaxy's Most Wanted", "Hood"},
+	}, packs)
+
+	var testCases = []struct {
+		name string
+		got  int
+		want int
+	}{
+		{name: "Included heroes", got: len(filter.Include.Heroes), want: 1},
+		{name: "Included aspects", got: len(filter.Include.Aspects), want: 1},
+		{name: "Included villains by short name", got: len(filter.Include.Villains), want: 1},
+		{name: "Included modules", got: len(filter.Include.Modules), want: 1},
+		{name: "Included difficulties", got: len(filter.Include.Difficulties), want: 1},
+		{name: "Included packs", got: len(filter.Include.Packs), want: 0},
+		{name: "Excluded villains", got: len(filter.Exclude.Villains), want: 1},
+		{name: "Excluded packs, with or without a prefix", got: len(filter.Exclude.Packs), want: 2},
+		{name: "Excluded heroes", got: len(filter.Exclude.Heroes), want: 0},
+	}
+	for _, tt := range testCases {
+		if tt.got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, tt.got)
+		}
+	}
+	if len(unknown) != 1 || unknown[0] != "Hood" {
+		t.Errorf("expected Hood to be unknown, got %v", unknown)
+	}
+}
+
+func TestMissionCatalog_Filter(t *testing.T) {
+	catalog, packs := testMissionCatalog()
+	var testCases = []struct {
+		name         string
+		preferences  *MissionPreferences
+		wantHeroes   string
+		wantVillains string
+		wantModules  string
+	}{
+		{
+			name:         "No filter",
+			preferences:  nil,
+			wantHeroes:   "Black Panther;Spider-Man;Groot;Rocket Raccoon",
+			wantVillains: "Rhino;Klaw;Drang;The Collector (Escape the Museum)",
+			wantModules:  "Bomb Scare;Masters of Evil;Band of Badoon;Menagerie Medley",
+		},
+		{
+			name:         "Excluded entries",
+			preferences:  &MissionPreferences{Exclude: []string{"Groot", "Rhino", "Band of Badoon"}},
+			wantHeroes:   "Black Panther;Spider-Man;Rocket Raccoon",
+			wantVillains: "Klaw;Drang;The Collector (Escape the Museum)",
+			wantModules:  "Bomb Scare;Masters of Evil;Menagerie Medley",
+		},
+		{
+			name:         "Excluded pack",
+			preferences:  &MissionPreferences{Exclude: []string{"pack:core"}},
+			wantHeroes:   "Groot;Rocket Raccoon",
+			wantVillains: "Drang;The Collector (Escape the Museum)",
+			wantModules:  "Band of Badoon;Menagerie Medley",
+		},
+		{
+			name:         "Included pack",
+			preferences:  &MissionPreferences{Include: []string{"pack:MC01en"}, Exclude: []string{"Klaw"}},
+			wantHeroes:   "Black Panther;Spider-Man",
+			wantVillains: "Rhino",
+			wantModules:  "Bomb Scare;Masters of Evil",
+		},
+	}
+	for _, tt := range testCases {
+		filter, _ := catalog.ParseFilter(tt.preferences, packs)
+		filtered := catalog.Filter(filter)
+		heroes, villains := []string{}, []string{}
+		for _, h := range filtered.Heroes {
+			heroes = append(heroes, h.Name)
+		}
+		for _, v := range filtered.Villains {
+			villains = append(villains, v.Name)
+		}
+		if got := strings.Join(heroes, ";"); got != tt.wantHeroes {
+			t.Errorf("%s: expected heroes %q, got %q", tt.name, tt.wantHeroes, got)
+		}
+		if got := strings.Join(villains, ";"); got != tt.wantVillains {
+			t.Errorf("%s: expected villains %q, got %q", tt.name, tt.wantVillains, got)
+		}
+		if got := strings.Join(filtered.ModuleNames(), ";"); got != tt.wantModules {
+			t.Errorf("%s: expected modules %q, got %q", tt.name, tt.wantModules, got)
+		}
+	}
+}
+
+func TestMissionCatalog_GenerateMission(t *testing.T) {
+	catalog, packs := testMissionCatalog()
+	randomizeAll := MissionOptions{
+		Players:               2,
+		RandomizeHeroes:       true,
+		RandomizeAspects:      true,
+		RandomizeVillain:      true,
+		ModuleMode:            RandomModules,
+		AvoidDuplicateAspects: true,
+		ModuleCount:           -1,
+	}
+	var testCases = []struct {
+		name        string
+		options     MissionOptions
+		preferences *MissionPreferences
+		wantErr     bool
+		check       func(m *Mission) string
+	}{
+		{
+			name:        "Included entries are always drawn",
+			options:     randomizeAll,
+			preferences: &MissionPreferences{Include: []string{"Groot", "aspect:Protection", "Drang", "Bomb Scare"}},
+			check: func(m *Mission) string {
+				heroes := m.Players[0].Hero.Name + m.Players[1].Hero.Name
+				aspects := m.Players[0].Aspects[0].Name + m.Players[1].Aspects[0].Name
+				switch {
+				case !strings.Contains(heroes, "Groot"):
+					return "expected Groot, got " + heroes
+				case !strings.Contains(aspects, "Protection"):
+					return "expected Protection, got " + aspects
+				case m.Villain.Name != "Drang":
+					return "expected Drang, got " + m.Villain.Name
+				case !containsString(m.Modules, "Bomb Scare"):
+					return "expected Bomb Scare, got " + strings.Join(m.Modules, ", ")
+				}
+				return ""
+			},
+		},
+		{
+			name:        "Excluded entries are never drawn",
+			options:     randomizeAll,
+			preferences: &MissionPreferences{Exclude: []string{"pack:Core Set", "Drang", "Justice", "Aggression"}},
+			check: func(m *Mission) string {
+				for _, p := range m.Players {
+					if p.Hero.SKU != "MC09en" {
+						return "expected a hero from Galaxy's Most Wanted, got " + p.Hero.Name
+					}
+					if a := p.Aspects[0].Name; a != "Leadership" && a != "Protection" {
+						return "expected Leadership or Protection, got " + a
+					}
+				}
+				if m.Villain.Name != "The Collector (Escape the Museum)" {
+					return "expected The Collector, got " + m.Villain.Name
+				}
+				if strings.Join(m.Modules, ", ") == "Bomb Scare" || strings.Join(m.Modules, ", ") == "Masters of Evil" {
+					return "expected a module from Galaxy's Most Wanted, got " + strings.Join(m.Modules, ", ")
+				}
+				return ""
+			},
+		},
+		{
+			name:        "Difficulty and villain stages",
+			options:     MissionOptions{Players: 1, Difficulty: "expert"},
+			preferences: &MissionPreferences{Include: []string{"Rhino"}},
+			check: func(m *Mission) string {
+				if m.Difficulty.Name != "Expert" || strings.Join(m.Stages, ", ") != "Rhino II, Rhino III" {
+					return "expected Expert with Rhino II and Rhino III, got " + strings.Join(m.Stages, ", ")
+				}
+				return ""
+			},
+		},
+		{
+			name:        "Included difficulty",
+			options:     MissionOptions{Players: 1},
+			preferences: &MissionPreferences{Include: []string{"difficulty:Standard"}},
+			check: func(m *Mission) string {
+				if m.Difficulty == nil || m.Difficulty.Name != "Standard" || m.Stages != nil {
+					return "expected Standard without a villain"
+				}
+				return ""
+			},
+		},
+		{
+			name:        "Random difficulty without excluded difficulties",
+			options:     MissionOptions{Players: 1, Difficulty: RandomDifficulty},
+			preferences: &MissionPreferences{Exclude: []string{"Standard", "Heroic 1"}},
+			check: func(m *Mission) string {
+				if m.Difficulty.Name != "Expert" {
+					return "expected Expert, got " + m.Difficulty.Name
+				}
+				return ""
+			},
+		},
+		{
+			name:        "No difficulty unless asked for",
+			options:     MissionOptions{Players: 1},
+			preferences: nil,
+			check: func(m *Mission) string {
+				if m.Difficulty != nil {
+					return "expected no difficulty, got " + m.Difficulty.Name
+				}
+				return ""
+			},
+		},
+		{
+			name:        "Unavailable difficulty",
+			options:     MissionOptions{Players: 1, Difficulty: "Expert II"},
+			preferences: nil,
+			wantErr:     true,
+		},
+		{
+			name:        "Too few heroes left",
+			options:     randomizeAll,
+			preferences: &MissionPreferences{Exclude: []string{"pack:Core Set", "Groot"}},
+			wantErr:     true,
+		},
+		{
+			name:        "Duplicate aspects when only one is left",
+			options:     MissionOptions{Players: 2, RandomizeAspects: true},
+			preferences: &MissionPreferences{Exclude: []string{"Leadership", "Justice", "Aggression"}},
+			check: func(m *Mission) string {
+				for _, p := range m.Players {
+					if p.Hero != nil || len(p.Aspects) != 1 || p.Aspects[0].Name != "Protection" {
+						return "expected only Protection"
+					}
+				}
+				return ""
+			},
+		},
+		{
+			name:        "Too few aspects to avoid duplicates",
+			options:     MissionOptions{Players: 2, RandomizeAspects: true, AvoidDuplicateAspects: true},
+			preferences: &MissionPreferences{Exclude: []string{"Leadership", "Justice", "Aggression"}},
+			wantErr:     true,
+		},
+		{
+			name:        "Too many included heroes",
+			options:     MissionOptions{Players: 1},
+			preferences: &MissionPreferences{Include: []string{"Groot", "Rocket Raccoon"}},
+			wantErr:     true,
+		},
+		{
+			name:        "No villain left",
+			options:     MissionOptions{Players: 1, RandomizeVillain: true},
+			preferences: &MissionPreferences{Exclude: []string{"Rhino", "Klaw", "Drang", "The Collector"}},
+			wantErr:     true,
+		},
+		{
+			name:        "Too few modules left",
+			options:     MissionOptions{Players: 1, RandomizeVillain: true, ModuleMode: RandomModules, ModuleCount: 2},
+			preferences: &MissionPreferences{Exclude: []string{"pack:Galaxy's Most Wanted", "Bomb Scare"}},
+			wantErr:     true,
+		},
+		{
+			name:        "Recommended modules without randomizing them",
+			options:     MissionOptions{Players: 1},
+			preferences: &MissionPreferences{Include: []string{"Klaw"}},
+			check: func(m *Mission) string {
+				if m.Villain.Name != "Klaw" || strings.Join(m.Modules, ", ") != "Masters of Evil" {
+					return "expected Klaw with Masters of Evil, got " + strings.Join(m.Modules, ", ")
+				}
+				return ""
+			},
+		},
+	}
+	for _, tt := range testCases {
+		filter, unknown := catalog.ParseFilter(tt.preferences, packs)
+		if len(unknown) > 0 {
+			t.Fatalf("%s: unexpected unknown terms %v", tt.name, unknown)
+		}
+		mission, err := catalog.GenerateMission(tt.options, filter, rand.New(rand.NewSource(1)))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if problem := tt.check(mission); problem != "" {
+			t.Errorf("%s: %s", tt.name, problem)
+		}
+	}
+}
+
+func TestSelectModules(t *testing.T) {
+	available := []*Module{
+		{Name: "Bomb Scare"},
+		{Name: "Masters of Evil"},
+		{Name: "Under Attack"},
+		{Name: "Legions of Hydra"},
+		{Name: "The Doomsday Chair"},
+		{Name: "Hydra Assault"},
+		{Name: "Weapon Master"},
+		{Name: "Hydra Patrol"},
+	}
+	// All but the required and built-in sets of Crossbones, e.g. Legions of Hydra
+	crossbonesPool := []string{"Bomb Scare", "Masters of Evil", "Under Attack", "The Doomsday Chair", "Hydra Assault", "Weapon Master", "Hydra Patrol"}
+	rhino := &Villain{Name: "Rhino", ModuleCount: 1, RecommendedModules: []string{"Bomb Scare"}}
+	crossbones := &Villain{
+		Name:               "Crossbones",
+		ModuleCount:        2,
+		RecommendedModules: []string{"Hydra Assault", "Weapon Master"},
+		RequiredModules:    []string{"Experimental Weapons", "Legions of Hydra"},
+	}
+	taskmaster := &Villain{Name: "Taskmaster", ModuleCount: 1, RecommendedModules: []string{"Weapon Master"}, RequiredModules: []string{"Hydra Patrol"}}
+	wreckingCrew := &Villain{Name: "Wrecking Crew", RecommendedModules: []string{}}
+	builtIn := &Villain{Name: "Masters of Evil", ModuleCount: 1}
+
+	var testCases = []struct {
+		name      string
+		villain   *Villain
+		available []*Module
+		included  []*Module
+		mode      ModuleMode
+		count     int
+		want      []string // The modules that must be selected
+		wantPool  []string // The modules the rest are drawn from
+		wantCount int
+		wantErr   string
+	}{
+		{name: "Recommended", villain: crossbones, mode: RecommendedModules, count: -1, want: []string{"Hydra Assault", "Weapon Master"}, wantCount: 2},
+		{name: "Recommended ignores the count", villain: rhino, mode: RecommendedModules, count: 3, want: []string{"Bomb Scare"}, wantCount: 1},
+		{name: "Recommended without a villain", mode: RecommendedModules, count: -1, wantCount: 0},
+		{
+			name:      "Unavailable recommended modules are replaced",
+			villain:   crossbones,
+			available: []*Module{{Name: "Weapon Master"}, {Name: "Legions of Hydra"}, {Name: "Bomb Scare"}},
+			mode:      RecommendedModules,
+			count:     -1,
+			want:      []string{"Weapon Master", "Bomb Scare"},
+			wantCount: 2,
+		},
+		{name: "Included modules are added", villain: rhino, included: []*Module{{Name: "Under Attack"}}, mode: RecommendedModules, count: -1, want: []string{"Under Attack", "Bomb Scare"}, wantCount: 2},
+		{name: "Random honours the villain's module count", villain: crossbones, mode: RandomModules, count: -1, wantPool: crossbonesPool, wantCount: 2},
+		{name: "Random with a villain without modules", villain: wreckingCrew, mode: RandomModules, count: -1, wantCount: 0},
+		{name: "Random without a villain", mode: RandomModules, count: -1, wantCount: 1},
+		{name: "Random with a count", villain: rhino, mode: RandomModules, count: 3, wantCount: 3},
+		{name: "Random never draws required modules", villain: crossbones, mode: RandomModules, count: 7, wantPool: crossbonesPool, wantCount: 7},
+		{name: "Random never draws built-in sets", villain: builtIn, mode: RandomModules, count: 7, wantPool: []string{"Bomb Scare", "Under Attack", "Legions of Hydra", "The Doomsday Chair", "Hydra Assault", "Weapon Master", "Hydra Patrol"}, wantCount: 7},
+		{name: "Random runs out of modules", villain: taskmaster, mode: RandomModules, count: 20, wantErr: "20 modular sets are needed, but only 7 are left to draw from"},
+		{
+			name:      "Too few modules for the villain",
+			villain:   crossbones,
+			available: []*Module{{Name: "Weapon Master"}, {Name: "Legions of Hydra"}},
+			mode:      RecommendedModules,
+			count:     -1,
+			wantErr:   "2 modular sets are needed, but only 1 are left to draw from",
+		},
+		{name: "Random counts included modules", villain: rhino, included: []*Module{{Name: "Under Attack"}}, mode: RandomModules, count: 2, want: []string{"Under Attack"}, wantCount: 2},
+		{name: "Included required modules are left out", villain: taskmaster, included: []*Module{{Name: "Hydra Patrol"}}, mode: RecommendedModules, count: -1, want: []string{"Weapon Master"}, wantCount: 1},
+		{name: "Recommended plus random", villain: crossbones, mode: RecommendedPlusRandomModules, count: 2, want: []string{"Hydra Assault", "Weapon Master"}, wantPool: crossbonesPool, wantCount: 4},
+		{name: "Recommended plus one by default", villain: rhino, mode: RecommendedPlusRandomModules, count: -1, want: []string{"Bomb Scare"}, wantCount: 2},
+		{name: "Recommended plus random without a villain", mode: RecommendedPlusRandomModules, count: 2, wantCount: 2},
+	}
+	for _, tt := range testCases {
+		modules := tt.available
+		if modules == nil {
+			modules = available
+		}
+		// Every draw must satisfy the test case, whatever the seed
+		for seed := int64(0); seed < 20; seed++ {
+			got, err := SelectModules(tt.villain, modules, tt.included, tt.mode, tt.count, rand.New(rand.NewSource(seed)))
+			if tt.wantErr != "" || err != nil {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("%s: expected error %q, got %v", tt.name, tt.wantErr, err)
+					break
+				}
+				continue
+			}
+			if len(got) != tt.wantCount {
+				t.Errorf("%s: expected %d modules, got %v", tt.name, tt.wantCount, got)
+				break
+			}
+			problem := ""
+			for k, m := range got {
+				if k < len(tt.want) && m != tt.want[k] {
+					problem = fmt.Sprintf("expected %v first, got %v", tt.want, got)
+				}
+				if containsString(got[:k], m) {
+					problem = fmt.Sprintf("expected no duplicates, got %v", got)
+				}
+				if tt.villain != nil && (containsString(tt.villain.RequiredModules, m) || m == tt.villain.Name) {
+					problem = fmt.Sprintf("expected no required or built-in modules, got %v", got)
+				}
+				if k >= len(tt.want) && tt.wantPool != nil && !containsString(tt.wantPool, m) {
+					problem = fmt.Sprintf("expected modules from %v, got %v", tt.wantPool, got)
+				}
+			}
+			if problem != "" {
+				t.Errorf("%s: %s", tt.name, problem)
+				break
+			}
+		}
+	}
+}
@@ -3,24 +3,14 @@ package server
 import (
 	"fmt"
 	"github.com/bwmarrin/discordgo"
-	gim "github.com/ozankasikci/go-image-merge"
-	"github.com/segmentio/ksuid"
 	log "github.com/sirupsen/logrus"
-	"github.com/texttheater/golang-levenshtein/levenshtein"
-	"image/png"
 	"marvelbot/pkg/card"
 	"marvelbot/pkg/rule"
-	"math"
-	"os"
 	"regexp"
+	"sort"
 	"strings"
 )
 
-// These constants map to the color codes used by different Aspects.
-const (
-	IMAGE_BASEDIR = "images"
-)
-
 // This function will be called every time a new message is created on any channel that the authenticated bot has
 // access to (due to the DiscordGo AddHandler).
 func (srv *Server) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
@@ -59,7 +49,19 @@ func (srv *Server) HandleHelp(s *discordgo.Session, m *discordgo.MessageCreate,
 		Value: "Fetches <card name> from MarvelCDB.com and displays the image (if available) or links to the card, " +
 			"e.g. [[Lockjaw]]. Multiple cards can be requested in a single message, e.g. [[Peanut Butter]] [[Jelly]]. " +
 			"Some filters are also supported in the format of [[<filter>:<search term>]], such as " +
-			"[[set:A Mess of Things]].\n\nCurrently supported filters: set",
+			"[[set:A Mess of Things]]. Filters can be combined with AND, OR, NOT and parentheses, and numeric " +
+			"fields support comparisons, e.g. [[aspect:Aggression type:Ally cost<=3]]. A single side of a " +
+			"double-sided card can be requested with #A or #B, e.g. [[Spider-Man#B]].\n\n" +
+			"Currently supported filters: name, type, set, pack, trait, aspect, keyword, text, unique, cost, thwart, " +
+			"attack, defense, recover, scheme, health, hand, boost, stage",
+		Inline: false,
+	}
+
+	fetchRule := &discordgo.MessageEmbedField{
+		Name: "[[rule:<rule name>]]",
+		Value: "Displays <rule name> from the newest Rules Reference, e.g. [[rule:Quickstrike]]. A specific version " +
+			"can be requested with [[rule@<version>:<rule name>]], e.g. [[rule@1.3:Ability]], and " +
+			"[[changes:<rule name>]] shows what changed in the newest version.",
 		Inline: false,
 	}
 
@@ -69,7 +71,7 @@ func (srv *Server) HandleHelp(s *discordgo.Session, m *discordgo.MessageCreate,
 		Inline: false,
 	}
 
-	fields = append(fields, fetchCard, displayHelp)
+	fields = append(fields, fetchCard, fetchRule, displayHelp)
 
 	ms := &discordgo.MessageSend{
 		Embed: &discordgo.MessageEmbed{
@@ -107,28 +109,27 @@ func (srv *Server) HandleCommands(s *discordgo.Session, m *discordgo.MessageCrea
 	matchedCards := []*card.Card{}
 	matchedInfo := []*card.Card{}
 	matchedRules := []*rule.Rule{}
+	matchedChanges := []*ruleChanges{}
 	// unmatchedCommands holds anything the bot was unable to find
 	unmatchedCommands := []string{}
+	// ambiguousRules holds rule queries that matched several rules, along with the candidates
+	ambiguousRules := []string{}
+	// ambiguousCards and ambiguousInfo hold card names that matched several cards, such as Rhino, which the user will be
+	// asked to choose between
+	ambiguousCards := []*ambiguousQuery{}
+	ambiguousInfo := []*ambiguousQuery{}
 
 	// We are going to iterate over the command results and identify what to do with them
 	for _, command := range results {
 		// The command brackets are no longer needed
 		command = trimCommand(command)
 		// Query is the content the user is searching for, e.g., Heimdall or Lockjaw
-		// Examples: [[Heimdall]], [[Lockjaw]]
-		var query string
-		// Filter is narrowing the user's query results down, e.g., Ally or Rule
-		// Examples: [[Ally:Spider-Man]], [[Rule:Villain Phase]]
-		var filter string
-		// By default, anything without a filter is considered to be a search for a card
-		// TODO - Support multiple filters? e.g., type=Ally, set=Galaxy's Most Wanted
-		if strings.Contains(command, ":") {
-			commandParts := strings.Split(command, ":")
-			filter = strings.ToLower(commandParts[0])
-			query = strings.ToLower(commandParts[1])
-		} else {
-			query = strings.ToLower(command)
-		}
+		// Examples: [[Heimdall]], [[Lockjaw]], [[aspect:Aggression type:Ally cost<=3]]
+		// Filter is a directive handled by the bot rather than the card query parser, e.g., Rule or Info
+		// Examples: [[Rule:Villain Phase]], [[Info:Lockjaw]]
+		// Version selects a specific version of a rule, e.g. [[Rule@1.3:Ability]] or [[Changes@1.3:Ability]]
+		// By default, anything without a directive is considered to be a search for a card
+		filter, version, query := splitCommand(command)
 
 		// If the query was too short, reject it
 		if len(query) < 3 {
@@ -145,46 +146,69 @@ func (srv *Server) HandleCommands(s *discordgo.Session, m *discordgo.MessageCrea
 		// Based on the filter, we'll handle the command differently
 		switch filter {
 		case "hb", "homebrew":
-			cards := findCards(filter, query, srv.Homebrew)
+			cards, err := searchCards(query, srv.Homebrew)
 			// We didn't find a card, so we'll add the command to the list of failed commands
-			if len(cards) == 0 {
-				unmatchedCommands = append(unmatchedCommands, query)
+			if err != nil || len(cards) == 0 {
+				unmatchedCommands = append(unmatchedCommands, describeUnmatched(query, err))
 				break
 			}
 			// We found a card (or cards), so we'll add them to the list of Cards to return to the user
 			matchedCards = append(matchedCards, cards...)
 		case "info":
-			cards := findCards(filter, query, srv.Cards)
+			cards, err := searchCards(query, srv.Cards)
 			// We didn't find a card, so we'll add the command to the list of failed commands
-			if len(cards) == 0 {
-				unmatchedCommands = append(unmatchedCommands, query)
+			if err != nil || len(cards) == 0 {
+				unmatchedCommands = append(unmatchedCommands, describeUnmatched(query, err))
+				break
+			}
+			// The name matched several cards, so we'll ask the user which ones they meant
+			if name, side := splitSide(query); len(cards) > 1 && isNameQuery(name) {
+				ambiguousInfo = append(ambiguousInfo, &ambiguousQuery{Query: name, Side: side, Cards: cards})
 				break
 			}
 			// We found a card (or cards), so we'll add them to the list of Cards to return to the user
 			matchedInfo = append(matchedInfo, cards...)
-		case "rule", "rules":
-			r := findRule(query, srv.Rules)
+		case "rule", "rules", "changes":
+			r, candidates := findRule(query, srv.Rules)
+			// The query matched several rules equally well, so we'll ask the user which one they meant
+			if len(candidates) > 0 {
+				ambiguousRules = append(ambiguousRules, describeCandidates(query, candidates))
+				break
+			}
 			// We didn't find a rule, so we'll add the command to the list of failed commands
 			if r == nil {
 				unmatchedCommands = append(unmatchedCommands, query)
 				break
 			}
+			if filter == "changes" {
+				changes, err := findRuleChanges(r.Name, version, srv.Rules)
+				if err != nil {
+					unmatchedCommands = append(unmatchedCommands, describeUnmatched(query, err))
+					break
+				}
+				matchedChanges = append(matchedChanges, changes)
+				break
+			}
+			// A specific version was requested, so we'll look it up by the name we found
+			if version != "" {
+				if r = srv.Rules.Get(r.Name, version); r == nil {
+					unmatchedCommands = append(unmatchedCommands, describeUnmatched(query,
+						fmt.Errorf("no version %s", version)))
+					break
+				}
+			}
 			// We found a rule, so we'll add it to the list of Rules to return to the user
 			matchedRules = append(matchedRules, r)
-		case "set":
-			cards := findCards(filter, query, srv.Cards)
+		default:
+			cards, err := searchCards(query, srv.Cards)
 			// We didn't find a card, so we'll add the command to the list of failed commands
-			if len(cards) == 0 {
-				unmatchedCommands = append(unmatchedCommands, query)
+			if err != nil || len(cards) == 0 {
+				unmatchedCommands = append(unmatchedCommands, describeUnmatched(query, err))
 				break
 			}
-			// We found a card (or cards), so we'll add them to the list of Cards to return to the user
-			matchedCards = append(matchedCards, cards...)
-		default:
-			cards := findCards(filter, query, srv.Cards)
-			// We didn't find a card, so we'll add the command to the list of failed commands
-			if len(cards) == 0 {
-				unmatchedCommands = append(unmatchedCommands, query)
+			// The name matched several cards, so we'll ask the user which ones they meant
+			if name, side := splitSide(query); len(cards) > 1 && isNameQuery(name) {
+				ambiguousCards = append(ambiguousCards, &ambiguousQuery{Query: name, Side: side, Cards: cards})
 				break
 			}
 			// We found a card (or cards), so we'll add them to the list of Cards to return to the user
@@ -203,11 +227,31 @@ func (srv *Server) HandleCommands(s *discordgo.Session, m *discordgo.MessageCrea
 		}
 	}
 
+	// Suggest rules for any ambiguous queries
+	if len(ambiguousRules) > 0 {
+		msg := fmt.Sprintf(
+			"Director %s, the S.H.I.E.L.D. database has several records for the following queries:\n%s",
+			u.Mention(), strings.Join(ambiguousRules, "\n"))
+		_, err := s.ChannelMessageSend(m.ChannelID, msg)
+		if err != nil {
+			logError.Errorf("error sending message: %v", err)
+		}
+	}
+
+	// Ask the user to choose between the candidates for any ambiguous card names
+	sendCardMenuMessages(srv, s, m, u, "info", ambiguousInfo)
+	sendCardMenuMessages(srv, s, m, u, "image", ambiguousCards)
+
 	// Return all matched rules
 	if len(matchedRules) > 0 {
 		sendRulesMessages(srv, s, m, u, matchedRules)
 	}
 
+	// Return all matched "changes" requests
+	if len(matchedChanges) > 0 {
+		sendRuleChangesMessages(srv, s, m, u, matchedChanges)
+	}
+
 	// Return all matched "info" requests
 	if len(matchedInfo) > 0 {
 		sendCardInfoMessage(srv, s, m, u, matchedInfo)
@@ -273,8 +317,9 @@ func sendCardInfoMessage(srv *Server, s *discordgo.Session, m *discordgo.Message
 	}
 }
 
-// sendCardMessage will send an embedded Cards object to the channel
-func sendCardMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCreate, u *discordgo.User, cards []*card.Card) {
+// sendCardMenuMessages will send a select menu to the channel for each ambiguous card name. The chosen cards are
+// rendered by CardComponentHandler according to the subcommand, as for the card slash command.
+func sendCardMenuMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCreate, u *discordgo.User, subcommand string, queries []*ambiguousQuery) {
 	// Configure logger for failed Discord message sends
 	logError := srv.Logger.WithFields(log.Fields{
 		"server": m.GuildID,
@@ -282,43 +327,36 @@ func sendCardMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCre
 		"level":  "error",
 	})
 
-	// We want to build different grids for horizontal and vertical images so we need to separate them
-	// https://github.com/ozankasikci/go-image-merge#examples
-	verticalGrids := []*gim.Grid{}
-	horizontalGrids := []*gim.Grid{}
-
-	// If we have any cards missing images, we'll use this to return a link to the card instead
-	cardsWithErrors := []*card.Card{}
-
-	// Generate the grids
-	for _, c := range cards {
-		err := c.DownloadImages()
-		if err != nil {
-			logError.Errorf("error downloading %v card image: %v", c.Names, err)
-			cardsWithErrors = append(cardsWithErrors, c)
-			continue
+	for n, q := range queries {
+		ms := &discordgo.MessageSend{
+			Content: fmt.Sprintf(
+				"Director %s, the S.H.I.E.L.D. database has several records matching \"%s\". Select the records you need:",
+				u.Mention(), q.Query),
+			Components: cardMenuComponents(fmt.Sprintf("card:%s:%d", subcommand, n), q),
 		}
-		for _, cardFace := range c.Faces {
-			// Copied from card.DownloadImages
-			imageSlice := strings.Split(*cardFace.ImageURL, "/")
-			imageName := imageSlice[len(imageSlice)-1]
-			imageDir := fmt.Sprintf("%s/%s", IMAGE_BASEDIR, c.Packs[0].SKU)
-			imagePath := fmt.Sprintf("%s/%s", imageDir, imageName)
-
-			// Add cards to their respective Grid based on orientation (vertical or horizontal)
-			grid := &gim.Grid{
-				ImageFilePath: imagePath,
-			}
-			if c.Horizontal == true {
-				horizontalGrids = append(horizontalGrids, grid)
-			} else {
-				verticalGrids = append(verticalGrids, grid)
-			}
+		_, err := s.ChannelMessageSendComplex(m.ChannelID, ms)
+		if err != nil {
+			logError.Errorf("error sending message: %v", err)
 		}
 	}
+}
+
+// sendCardMessage will send an embedded Cards object to the channel
+func sendCardMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCreate, u *discordgo.User, cards []*card.Card) {
+	// Configure logger for failed Discord message sends
+	logError := srv.Logger.WithFields(log.Fields{
+		"server": m.GuildID,
+		"user":   u.Username,
+		"level":  "error",
+	})
+
+	// Lay out the images of the cards. Cards missing images are linked to instead, and any images not cached yet are
+	// downloaded in the background.
+	opts := layoutOptions{}
+	images, cardsWithErrors := buildImages(cards, srv.Images, opts, UploadLimit)
 
-	// If we have a 0-length Grid, all images have failed
-	if len(horizontalGrids) == 0 && len(verticalGrids) == 0 {
+	// If we have no images, all images have failed
+	if len(images) == 0 {
 		var names = []string{}
 		for _, card := range cardsWithErrors {
 			for _, face := range card.Faces {
@@ -330,106 +368,11 @@ func sendCardMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCre
 			u.Mention(), strings.Join(names, "\n"))
 		_, err := s.ChannelMessageSend(m.ChannelID, msg)
 		if err != nil {
-			logError.Errorf("error sending message: %w", err)
+			logError.Errorf("error sending message: %v", err)
 		}
 		return
 	}
 
-	// Merge the images into a grid
-	// TODO - Make this a function that returns a filename?
-	// We'll start with vertical images
-	var verticalFileName string
-	var verticalFile *os.File
-	if len(verticalGrids) > 0 {
-		// # of images per row
-		var horizontalCount, verticalCount int
-		if len(verticalGrids) > 3 {
-			horizontalCount = 3
-			verticalCount = int(math.Ceil(float64(len(verticalGrids)) / 3.0))
-		} else {
-			horizontalCount = len(verticalGrids)
-			verticalCount = 1
-		}
-
-		rgba, err := gim.New(verticalGrids, horizontalCount, verticalCount).Merge()
-		if err != nil {
-			logError.Errorf("error merging vertical images: %v", err)
-		}
-
-		// Create a guid to use for our new image
-		guid := ksuid.New()
-		verticalFileName = fmt.Sprintf("temp_%s.png", guid.String())
-
-		// Save the output to PNG
-		verticalFile, err = os.Create(fmt.Sprintf("%s/%s", IMAGE_BASEDIR, verticalFileName))
-		if err != nil {
-			logError.Errorf("error saving file: %v", err)
-		}
-		err = png.Encode(verticalFile, rgba)
-		if err != nil {
-			logError.Errorf("error encoding PNG: %v", err)
-		}
-		defer verticalFile.Close()
-
-		// Rewind to beginning of file after writing to it
-		_, err = verticalFile.Seek(0, 0)
-		if err != nil {
-			msg := fmt.Sprintf("error rewinding file: %v", err)
-			_, err = s.ChannelMessageSend(m.ChannelID, msg)
-			if err != nil {
-				logError.Errorf("error sending message: %v", err)
-			}
-			return
-		}
-	}
-
-	// Now, the horizontal images
-	// These will be 2 per line
-	var horizontalFileName string
-	var horizontalFile *os.File
-	if len(horizontalGrids) > 0 {
-		// # of images per row
-		var horizontalCount, verticalCount int
-		if len(horizontalGrids) > 2 {
-			horizontalCount = 2
-			verticalCount = int(math.Ceil(float64(len(horizontalGrids)) / 2.0))
-		} else {
-			horizontalCount = len(horizontalGrids)
-			verticalCount = 1
-		}
-
-		rgba, err := gim.New(horizontalGrids, horizontalCount, verticalCount).Merge()
-		if err != nil {
-			logError.Errorf("error merging horizontal images: %v", err)
-		}
-
-		// Create a guid to use for our new image
-		guid := ksuid.New()
-		horizontalFileName = fmt.Sprintf("temp_%s.png", guid.String())
-
-		// Save the output to PNG
-		horizontalFile, err = os.Create(fmt.Sprintf("%s/%s", IMAGE_BASEDIR, horizontalFileName))
-		if err != nil {
-			logError.Errorf("error saving file: %v", err)
-		}
-		err = png.Encode(horizontalFile, rgba)
-		if err != nil {
-			logError.Errorf("error encoding PNG: %v", err)
-		}
-		defer horizontalFile.Close()
-
-		// Rewind to beginning of file after writing to it
-		_, err = horizontalFile.Seek(0, 0)
-		if err != nil {
-			msg := fmt.Sprintf("error rewinding file: %v", err)
-			_, err = s.ChannelMessageSend(m.ChannelID, msg)
-			if err != nil {
-				logError.Errorf("error sending message: %v", err)
-			}
-			return
-		}
-	}
-
 	// Did we have any errors? If so, we'll return them as fields
 	var description string
 	var fields []*discordgo.MessageEmbedField
@@ -468,25 +411,23 @@ func sendCardMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCre
 		fields = append(fields, field)
 	}
 
-	// TODO - Make this a function as well
-	// Return the image to Discord
-	if horizontalFileName != "" {
+	// Return each image to Discord in a message of its own
+	for n, img := range images {
+		file := img.file(fmt.Sprintf("cards_%d", n+1))
 		ms := &discordgo.MessageSend{
 			Embed: &discordgo.MessageEmbed{
 				Color:       0x78141b,
 				Description: description,
 				Fields:      fields,
 				Image: &discordgo.MessageEmbedImage{
-					URL: "attachment://" + horizontalFileName,
-				},
-			},
-			Files: []*discordgo.File{
-				{
-					Name:        horizontalFileName,
-					Reader:      horizontalFile,
-					ContentType: "image/jpeg",
+					URL: "attachment://" + file.Name,
 				},
 			},
+			Files: []*discordgo.File{file},
+		}
+		// Offer to flip double-sided cards when all of them are shown in this message, e.g. [[Spider-Man#A]]
+		if len(images) == 1 {
+			ms.Components = flipComponents(cards, opts)
 		}
 
 		_, err := s.ChannelMessageSendComplex(m.ChannelID, ms)
@@ -494,49 +435,32 @@ func sendCardMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCre
 			logError.Errorf("error sending message: %v", err)
 		}
 	}
+}
 
-	if verticalFileName != "" {
+// sendRulesMessages will send an embedded Rule to the channel for each object in the rules slice
+func sendRulesMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCreate, u *discordgo.User, rules []*rule.Rule) {
+	// Configure logger for failed Discord message sends
+	logError := srv.Logger.WithFields(log.Fields{
+		"server": m.GuildID,
+		"user":   u.Username,
+		"level":  "error",
+	})
+
+	for _, r := range rules {
 		ms := &discordgo.MessageSend{
-			Embed: &discordgo.MessageEmbed{
-				Color:       0x78141b,
-				Description: description,
-				Fields:      fields,
-				Image: &discordgo.MessageEmbedImage{
-					URL: "attachment://" + verticalFileName,
-				},
-			},
-			Files: []*discordgo.File{
-				{
-					Name:        verticalFileName,
-					Reader:      verticalFile,
-					ContentType: "image/jpeg",
-				},
-			},
+			Embed:      ruleEmbed(r, srv.Rules),
+			Components: ruleComponents(r, srv.Rules),
 		}
-
 		_, err := s.ChannelMessageSendComplex(m.ChannelID, ms)
 		if err != nil {
 			logError.Errorf("error sending message: %v", err)
 		}
 	}
-
-	// Delete the new image file so we don't (eventually) run out of disk space
-	if horizontalFileName != "" {
-		err := os.Remove("images/" + horizontalFileName)
-		if err != nil {
-			logError.Errorf("error deleting file: %v", err)
-		}
-	}
-	if verticalFileName != "" {
-		err := os.Remove("images/" + verticalFileName)
-		if err != nil {
-			logError.Errorf("error deleting file: %v", err)
-		}
-	}
+	return
 }
 
-// sendRulesMessages will send an embedded Rule to the channel for each object in the rules slice
-func sendRulesMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCreate, u *discordgo.User, rules []*rule.Rule) {
+// sendRuleChangesMessages will send an embedded diff to the channel for each object in the changes slice
+func sendRuleChangesMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCreate, u *discordgo.User, changes []*ruleChanges) {
 	// Configure logger for failed Discord message sends
 	logError := srv.Logger.WithFields(log.Fields{
 		"server": m.GuildID,
@@ -544,32 +468,11 @@ func sendRulesMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCr
 		"level":  "error",
 	})
 
-	for _, r := range rules {
-		// Create our embed fields
-		fields := []*discordgo.MessageEmbedField{}
-		// If rules exceed 2048 characters, they won't fit in the Description field
-		// So we'll parse those into extra fields
-		if len(r.Text2) > 0 {
-			rules_cont := &discordgo.MessageEmbedField{
-				Name:  "Rules (continued)",
-				Value: r.Text2,
-			}
-			fields = append(fields, rules_cont)
-		}
-		// If there is a "See also" section, append that as well
-		if len(r.Related) > 0 {
-			related_field := &discordgo.MessageEmbedField{
-				Name:  "See also",
-				Value: strings.Join(r.Related, "\n"),
-			}
-			fields = append(fields, related_field)
-		}
-
+	for _, c := range changes {
 		ms := &discordgo.MessageSend{
 			Embed: &discordgo.MessageEmbed{
-				Title:       r.Name,
-				Description: r.Text, // Description allows 2048 characters
-				Fields:      fields, // Embed fields allow 1024 characters
+				Title:       fmt.Sprintf("%s (v%s → v%s)", c.To.Name, c.From.Version, c.To.Version),
+				Description: formatRuleDiff(rule.Diff(c.From, c.To), 2048), // Description allows 2048 characters
 			},
 		}
 		_, err := s.ChannelMessageSendComplex(m.ChannelID, ms)
@@ -581,263 +484,225 @@ func sendRulesMessages(srv *Server, s *discordgo.Session, m *discordgo.MessageCr
 }
 
 // findCards is a function that takes a filter and a query string and returns the closest matching Cards.
-func findCards(filter string, query string, cards []*card.Card) (matches []*card.Card) {
+func findCards(filter string, query string, repo *card.CardRepository) (matches []*card.Card) {
+	query = card.Normalize(query)
 	switch filter {
 	// Pack has different logic than the other filters, since we are not looking at the Type field
 	case "pack":
-		lowercaseMatches := []*card.Card{}
-		containsMatches := []*card.Card{}
-		levenshteinMatches := []*card.Card{}
-		for _, c := range cards {
-			for _, pack := range c.Packs {
-				packName := strings.ToLower(pack.Name)
-				// Pack name is an exact match
-				// e.g., "captain america" == "captain america"
-				if query == packName {
-					lowercaseMatches = append(lowercaseMatches, c)
-					break
-				}
-				// Set name is a contains match
-				if strings.Contains(packName, query) {
-					containsMatches = append(containsMatches, c)
-					break
-				}
-			}
+		// Pack name or SKU is an exact match, including the other names the pack registry knows
+		// e.g., "captain america" == "captain america", "mc04en" == "mc04en", "dr. strange" == "doctor strange"
+		if exact := repo.ByPack(query); len(exact) > 0 {
+			return exact
 		}
-		// Prefer exact card name matching
-		if len(lowercaseMatches) > 0 {
-			matches = append(matches, lowercaseMatches...)
-			return
-		}
-		// Next, prefer "contains" card name matching
-		if len(containsMatches) > 0 {
-			matches = append(matches, containsMatches...)
-			return
+		// Next, prefer "contains" pack name matching
+		if contains := findContainsCards(query, repo.PackNames(), repo.ByPackName, repo); len(contains) > 0 {
+			return contains
 		}
 		// If the other algorithms haven't matched, we'll use Levenshtein distance
-		// This is in a separate for loop since we have to create a new object for each card
-		levenshteinMatches = append(levenshteinMatches, findLevenshteinCards(filter, query, cards)...)
-		matches = append(matches, levenshteinMatches...)
-		return matches
+		return findLevenshteinCards(query, repo.PackNames(), repo.ByPackName, repo)
 	// Set has different logic than the other filters, since we are not looking at the Type field
 	case "set":
-		lowercaseMatches := []*card.Card{}
-		containsMatches := []*card.Card{}
-		levenshteinMatches := []*card.Card{}
-		for _, c := range cards {
-			for _, set := range c.Sets {
-				setName := strings.ToLower(set.Name)
-				// Set name is an exact match
-				// e.g., "expert" == "expert"
-				if query == setName {
-					lowercaseMatches = append(lowercaseMatches, c)
-					break
-				}
-				// Set name is a contains match
-				if strings.Contains(setName, query) {
-					containsMatches = append(containsMatches, c)
-					break
-				}
-			}
+		// Set name is an exact match
+		// e.g., "expert" == "expert"
+		if exact := repo.BySet(query); len(exact) > 0 {
+			return exact
 		}
-		// Prefer exact card name matching
-		if len(lowercaseMatches) > 0 {
-			matches = append(matches, lowercaseMatches...)
-			return
-		}
-		// Next, prefer "contains" card name matching
-		if len(containsMatches) > 0 {
-			matches = append(matches, containsMatches...)
-			return
+		// Next, prefer "contains" set name matching
+		if contains := findContainsCards(query, repo.SetNames(), repo.BySet, repo); len(contains) > 0 {
+			return contains
 		}
 		// If the other algorithms haven't matched, we'll use Levenshtein distance
-		// This is in a separate for loop since we have to create a new object for each card
-		levenshteinMatches = append(levenshteinMatches, findLevenshteinCards(filter, query, cards)...)
-		matches = append(matches, levenshteinMatches...)
-		return matches
+		return findLevenshteinCards(query, repo.SetNames(), repo.BySet, repo)
 	// These filters compare against face.Type
-	case "attachment", "ally", "alter-ego", "hero", "minion", "upgrade", "obligation", "support", "villain":
-		lowercaseMatches := []*card.Card{}
-		containsMatches := []*card.Card{}
-		levenshteinMatches := []*card.Card{}
-		for _, c := range cards {
-			for _, cardName := range c.Names {
-				// Lowercased name is an exact match
-				// e.g., "peter parker" == "peter parker"
-				cardName = strings.ToLower(cardName)
-				if query == cardName {
-					// Now we need to compare against the filter
-					for _, cardFace := range c.Faces {
-						if filter == strings.ToLower(cardFace.Type) {
-							lowercaseMatches = append(lowercaseMatches, c)
-							// Fix for Ant-Man and Wasp with "hero" filter
-							// TODO - does adding a break here negatively impact any other cards?
-							break
-						}
-					}
-				}
-				// Name contains the query string
-				// eg, "hawkeye's quiver" == "quiver"
-				if strings.Contains(cardName, query) {
-					// Now we need to compare against the filter
-					for _, cardFace := range c.Faces {
-						if filter == strings.ToLower(cardFace.Type) {
-							containsMatches = append(containsMatches, c)
-						}
-					}
-				}
-			}
-		}
-		// Prefer exact card name matching
-		if len(lowercaseMatches) > 0 {
-			matches = append(matches, lowercaseMatches...)
-			return
-		}
-		// Next, prefer "contains" card name matching
-		if len(containsMatches) > 0 {
-			matches = append(matches, containsMatches...)
-			return
-		}
-		// If the other algorithms haven't matched, we'll use Levenshtein distance
-		// This is in a separate for loop since we have to create a new object for each card
-		levenshteinMatches = append(levenshteinMatches, findLevenshteinCards(filter, query, cards)...)
-		matches = append(matches, levenshteinMatches...)
-		return matches
-		// No filter, or the filter was not recognized
+	case "ally", "alter-ego", "attachment", "environment", "event", "hero", "main-scheme", "minion", "obligation",
+		"resource", "side-scheme", "support", "treachery", "upgrade", "villain":
+		// Types are written with a hyphen as a filter, but both "Alter-Ego" and "Side Scheme" appear in the card data
+		typed := repo.Merge(repo.ByType(filter), repo.ByType(strings.ReplaceAll(filter, "-", " ")))
+		// Lowercased name is an exact match
+		// e.g., "peter parker" == "peter parker"
+		if exact := card.Intersect(repo.ByName(query), typed); len(exact) > 0 {
+			return exact
+		}
+		// Name contains the query string
+		// eg, "hawkeye's quiver" == "quiver"
+		if contains := card.Intersect(findContainsCards(query, repo.Names(), repo.ByName, repo), typed); len(contains) > 0 {
+			return contains
+		}
+		// If the other algorithms haven't matched, we'll use Levenshtein distance, but only among the names of cards of
+		// the type, so that a closer name of another type can't hide them
+		typedByName := func(name string) []*card.Card {
+			return card.Intersect(repo.ByName(name), typed)
+		}
+		return findLevenshteinCards(query, cardNames(typed), typedByName, repo)
+	// No filter, or the filter was not recognized
 	default:
-		lowercaseMatches := []*card.Card{}
-		containsMatches := []*card.Card{}
-		levenshteinMatches := []*card.Card{}
-		for _, c := range cards {
-			for _, cardName := range c.Names {
-				// Lowercased name is an exact match
-				// e.g., "peter parker" == "peter parker"
-				cardName = strings.ToLower(cardName)
-				if query == cardName {
-					lowercaseMatches = append(lowercaseMatches, c)
-				}
-				// Name contains the query string
-				// eg, "hawkeye's quiver" == "quiver"
-				if strings.Contains(cardName, query) {
-					containsMatches = append(containsMatches, c)
-				}
-			}
-		}
 		// Prefer exact card name matching
-		if len(lowercaseMatches) > 0 {
-			matches = append(matches, lowercaseMatches...)
-			return
+		if exact := repo.ByName(query); len(exact) > 0 {
+			return exact
 		}
 		// Next, prefer "contains" card name matching
-		if len(containsMatches) > 0 {
-			matches = append(matches, containsMatches...)
-			return
+		if contains := findContainsCards(query, repo.Names(), repo.ByName, repo); len(contains) > 0 {
+			return contains
 		}
 		// If the other algorithms haven't matched, we'll use Levenshtein distance
-		// This is in a separate for loop since we have to create a new object for each card
-		levenshteinMatches = append(levenshteinMatches, findLevenshteinCards(filter, query, cards)...)
-		matches = append(matches, levenshteinMatches...)
-		return matches
+		return findLevenshteinCards(query, repo.Names(), repo.ByName, repo)
 	}
-	return matches
 }
 
-// findLevenshteinCards is a function that returns the closest matching Cards by Levenshtein distance.
-func findLevenshteinCards(filter string, query string, cards []*card.Card) (bestMatches []*card.Card) {
-	// New type to hold the card, distance, and ratio
-	type LevenshteinCard struct {
-		card     *card.Card
-		distance int
-		ratio    float64
+// searchCards parses a card query and returns the matching Cards. Plain searches such as [[Lockjaw]] or
+// [[set:Expert]] keep the ranked matching of findCards, while structured queries such as
+// [[aspect:Aggression type:Ally cost<=3]] return every card that satisfies the query.
+func searchCards(query string, repo *card.CardRepository) ([]*card.Card, error) {
+	// A single side of each card may be requested, e.g. Spider-Man#B
+	query, side := splitSide(query)
+	q, err := card.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if field, value, ok := q.Simple(); ok {
+		switch field {
+		case "name":
+			return withSide(findCards("", value, repo), side), nil
+		case "set", "pack", "ally", "alter-ego", "attachment", "environment", "event", "hero", "main-scheme", "minion",
+			"obligation", "resource", "side-scheme", "support", "treachery", "upgrade", "villain":
+			return withSide(findCards(field, value, repo), side), nil
+		}
+	}
+	return withSide(repo.Search(q), side), nil
+}
+
+// withSide narrows each of the cards to the requested side, if any. Cards without that side are returned whole.
+func withSide(cards []*card.Card, side string) []*card.Card {
+	if side == "" {
+		return cards
+	}
+	sided := make([]*card.Card, len(cards))
+	for i, c := range cards {
+		sided[i] = c.WithSide(side)
+	}
+	return sided
+}
+
+// describeCandidates returns an ambiguous rule query for display to the user, along with the rules it may refer to.
+func describeCandidates(query string, candidates []*rule.Rule) string {
+	names := []string{}
+	for _, r := range candidates {
+		names = append(names, r.Name)
+	}
+	return fmt.Sprintf("%s - did you mean: %s?", query, strings.Join(names, ", "))
+}
+
+// describeUnmatched returns a failed query for display to the user, along with the reason it could not be parsed.
+func describeUnmatched(query string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%s (%v)", query, err)
 	}
+	return query
+}
 
-	// Iterate through each card and calculate the Levenshtein distance and ratio
-	options := levenshtein.DefaultOptions
-	matches := []*LevenshteinCard{}
+// cardNames returns the sorted, normalized Card.Names of the cards, like the keys of CardRepository.Names.
+func cardNames(cards []*card.Card) []string {
+	seen := map[string]bool{}
+	names := []string{}
 	for _, c := range cards {
-		// Pack match logic
-		if filter == "pack" {
-			for _, pack := range c.Packs {
-				packName := strings.ToLower(pack.Name)
-				distance := levenshtein.DistanceForStrings([]rune(query), []rune(packName), options)
-				ratio := levenshtein.RatioForStrings([]rune(query), []rune(packName), options)
-				// 70% match is our cutoff point, we'll add these to a slice
-				if ratio > 0.70 {
-					matches = append(matches, &LevenshteinCard{c, distance, ratio})
-				}
+		for _, name := range c.Names {
+			if key := card.Normalize(name); !seen[key] {
+				seen[key] = true
+				names = append(names, key)
 			}
-			continue
-		}
-		// Set match logic
-		if filter == "set" {
-			for _, set := range c.Sets {
-				setName := strings.ToLower(set.Name)
-				distance := levenshtein.DistanceForStrings([]rune(query), []rune(setName), options)
-				ratio := levenshtein.RatioForStrings([]rune(query), []rune(setName), options)
-				// 70% match is our cutoff point, we'll add these to a slice
-				if ratio > 0.70 {
-					matches = append(matches, &LevenshteinCard{c, distance, ratio})
-				}
-			}
-			continue
 		}
-		// Card name match logic
-		for _, cardName := range c.Names {
-			cardName = strings.ToLower(cardName)
-			distance := levenshtein.DistanceForStrings([]rune(query), []rune(cardName), options)
-			ratio := levenshtein.RatioForStrings([]rune(query), []rune(cardName), options)
-
-			// 70% match is our cutoff point, we'll add these to a slice
-			if ratio > 0.70 {
-				// Compare against the filter - currently this only works for Face.Type
-				// It is likely we'll need a different function for Sets, etc
-				if filter != "" {
-					for _, cardFace := range c.Faces {
-						if filter == strings.ToLower(cardFace.Type) {
-							matches = append(matches, &LevenshteinCard{c, distance, ratio})
-						}
-					}
-				} else {
-					matches = append(matches, &LevenshteinCard{c, distance, ratio})
-				}
-			}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findContainsCards is a function that returns all Cards indexed under a key containing the query string. The keys
+// and lookup function should come from the same CardRepository index, e.g. Names() and ByName.
+func findContainsCards(query string, keys []string, lookup func(string) []*card.Card, repo *card.CardRepository) []*card.Card {
+	results := [][]*card.Card{}
+	for _, key := range keys {
+		if strings.Contains(key, query) {
+			results = append(results, lookup(key))
 		}
 	}
+	return repo.Merge(results...)
+}
 
-	// We'll find the highest ratio match
+// findLevenshteinCards is a function that returns the closest matching Cards by Levenshtein distance. The keys and
+// lookup function should come from the same CardRepository index, e.g. Names() and ByName.
+func findLevenshteinCards(query string, keys []string, lookup func(string) []*card.Card, repo *card.CardRepository) []*card.Card {
+	// Iterate through each key and calculate the Levenshtein ratio
+	bestKeys := []string{}
 	max := 0.0
-	for _, c := range matches {
-		if c.ratio > max {
-			max = c.ratio
+	for _, key := range keys {
+		ratio := levenshteinRatio(query, key)
+		// 70% match is our cutoff point
+		if ratio <= FuzzyCutoff || ratio < max {
+			continue
 		}
+		// We only keep the keys with the highest ratio
+		if ratio > max {
+			max = ratio
+			bestKeys = bestKeys[:0]
+		}
+		bestKeys = append(bestKeys, key)
 	}
 	// Return all cards with that ratio
-	for _, c := range matches {
-		if c.ratio == max {
-			bestMatches = append(bestMatches, c.card)
-		}
+	results := [][]*card.Card{}
+	for _, key := range bestKeys {
+		results = append(results, lookup(key))
 	}
-	return
+	return repo.Merge(results...)
 }
 
-// findRule is a function that takes a query string and returns the closest matching Rule.
-// TODO - Should rules be a map instead of a slice? Unlike cards, which can share a
-// TODO - name without being unique (see: Wakanda Forever!), a rule name is useful as a key.
-func findRule(query string, rules []*rule.Rule) *rule.Rule {
-	query = strings.TrimSpace(query)
-	for _, r := range rules {
-		if query == strings.ToLower(r.Name) {
-			return r
+// RuleSuggestionLimit is the number of candidate rules suggested when a rule query is ambiguous.
+const RuleSuggestionLimit = 5
+
+// findRule is a function that takes a query string and returns the newest version of the closest matching Rule. Exact
+// matches are preferred, followed by "contains" matches and finally fuzzy matches, which allow for typos like
+// "quickstrik". If the query is ambiguous, no Rule is returned and the best candidates are returned instead so the
+// user can be asked to choose.
+func findRule(query string, rules *rule.Store) (match *rule.Rule, candidates []*rule.Rule) {
+	query = rule.NormalizeName(query)
+	// Rule name is an exact match
+	if r := rules.Latest(query); r != nil {
+		return r, nil
+	}
+	// Rule name contains the query string
+	contains := []string{}
+	for _, name := range rules.Names() {
+		if strings.Contains(rule.NormalizeName(name), query) {
+			contains = append(contains, name)
 		}
 	}
-	// TODO - This only returns the first match
-	for _, r := range rules {
-		if strings.Contains(strings.ToLower(r.Name), query) {
-			return r
+	if len(contains) == 1 {
+		return rules.Latest(contains[0]), nil
+	}
+	if len(contains) > 1 {
+		for _, m := range fuzzyRank(query, contains, 0) {
+			candidates = append(candidates, rules.Latest(m.Value))
+		}
+		if len(candidates) > RuleSuggestionLimit {
+			candidates = candidates[:RuleSuggestionLimit]
+		}
+		return nil, candidates
+	}
+	// If the other algorithms haven't matched, we'll use fuzzy matching
+	matches := fuzzyRank(query, rules.Names(), FuzzyCutoff)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	// A single match, or one that is clearly better than the next best, is returned directly
+	if len(matches) == 1 || matches[0].Score-matches[1].Score >= 0.05 {
+		return rules.Latest(matches[0].Value), nil
+	}
+	for _, m := range matches {
+		candidates = append(candidates, rules.Latest(m.Value))
+		if len(candidates) == RuleSuggestionLimit {
+			break
 		}
 	}
-	// TODO - implement Levenshtein or similar fuzzy matching or search algorithm before returning a failure
-	return nil
+	return nil, candidates
 }
 
 // trimCommand takes a bot command and removes the control brackets
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"marvelbot/pkg/card"
+	"os"
+	"strings"
+	"sync"
+)
+
+// DefaultPreferencesPath is where mission preferences are saved when no other file is configured.
+const DefaultPreferencesPath = "preferences.yaml"
+
+// MissionPreferences are the terms a guild or an agent always includes in or excludes from their missions, such as
+// Kang, aspect:Aggression or pack:Galaxy's Most Wanted. See MissionCatalog.ParseFilter for how terms are matched.
+type MissionPreferences struct {
+	Include []string `json:"include,omitempty" yaml:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+}
+
+// Merge returns the preferences with those of other layered on top, so that a term included by other is no longer
+// excluded, and a term excluded by other is no longer included. Either preferences may be nil.
+func (p *MissionPreferences) Merge(other *MissionPreferences) *MissionPreferences {
+	merged := &MissionPreferences{}
+	if p != nil {
+		merged.Include = append(merged.Include, p.Include...)
+		merged.Exclude = append(merged.Exclude, p.Exclude...)
+	}
+	if other != nil {
+		merged.Include = addTerms(removeTerms(merged.Include, other.Exclude), other.Include)
+		merged.Exclude = addTerms(removeTerms(merged.Exclude, other.Include), other.Exclude)
+	}
+	return merged
+}
+
+// IsEmpty returns whether the preferences neither include nor exclude anything.
+func (p *MissionPreferences) IsEmpty() bool {
+	return p == nil || len(p.Include) == 0 && len(p.Exclude) == 0
+}
+
+// splitTerms splits a semi-colon separated list of terms, e.g. "Kang; pack:gmw", dropping any empty terms.
+func splitTerms(s string) (terms []string) {
+	for _, term := range strings.Split(s, ";") {
+		if term = strings.TrimSpace(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// addTerms adds the terms to the list, unless they are already in it.
+func addTerms(list []string, terms []string) []string {
+	for _, term := range terms {
+		if !containsTerm(list, term) {
+			list = append(list, term)
+		}
+	}
+	return list
+}
+
+// removeTerms removes the terms from the list.
+func removeTerms(list []string, terms []string) (kept []string) {
+	for _, term := range list {
+		if !containsTerm(terms, term) {
+			kept = append(kept, term)
+		}
+	}
+	return kept
+}
+
+// containsTerm returns whether the list contains the term, ignoring case and spacing.
+func containsTerm(list []string, term string) bool {
+	for _, t := range list {
+		if card.Normalize(t) == card.Normalize(term) {
+			return true
+		}
+	}
+	return false
+}
+
+// PreferenceStore records the mission preferences of each guild and each user, and saves them to a YAML file whenever
+// they change. It is safe for concurrent use.
+type PreferenceStore struct {
+	path        string
+	mu          sync.RWMutex
+	preferences preferenceFile
+}
+
+// preferenceFile is the layout of the file a PreferenceStore is saved to.
+type preferenceFile struct {
+	Guilds map[string]*MissionPreferences `yaml:"guilds"`
+	Users  map[string]*MissionPreferences `yaml:"users"`
+}
+
+// OpenPreferenceStore reads the preferences saved at path. A missing file is not an error, and opens an empty store
+// that is saved to path once a preference is set.
+func OpenPreferenceStore(path string) (*PreferenceStore, error) {
+	store := &PreferenceStore{path: path}
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to read preferences %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &store.preferences); err != nil {
+		return nil, fmt.Errorf("error unmarshaling preferences %s: %w", path, err)
+	}
+	if store.preferences.Guilds == nil {
+		store.preferences.Guilds = map[string]*MissionPreferences{}
+	}
+	if store.preferences.Users == nil {
+		store.preferences.Users = map[string]*MissionPreferences{}
+	}
+	return store, nil
+}
+
+// Guild returns a copy of the preferences of the guild, which are empty if it has none.
+func (ps *PreferenceStore) Guild(guildID string) *MissionPreferences {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return ps.preferences.Guilds[guildID].Merge(nil)
+}
+
+// User returns a copy of the preferences of the user, which are empty if they have none.
+func (ps *PreferenceStore) User(userID string) *MissionPreferences {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return ps.preferences.Users[userID].Merge(nil)
+}
+
+// SetGuild replaces the preferences of the guild, and saves the preferences. Empty preferences are removed.
+func (ps *PreferenceStore) SetGuild(guildID string, p *MissionPreferences) error {
+	return ps.set(ps.preferences.Guilds, guildID, p)
+}
+
+// SetUser replaces the preferences of the user, and saves the preferences. Empty preferences are removed.
+func (ps *PreferenceStore) SetUser(userID string, p *MissionPreferences) error {
+	return ps.set(ps.preferences.Users, userID, p)
+}
+
+// set replaces the preferences with the given ID, and saves the preferences.
+func (ps *PreferenceStore) set(preferences map[string]*MissionPreferences, id string, p *MissionPreferences) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if p.IsEmpty() {
+		delete(preferences, id)
+	} else {
+		preferences[id] = p
+	}
+	return writeYAMLFile(ps.path, ps.preferences)
+}
+
+// describePreferences lists the terms the preferences include and exclude, or returns a placeholder if there are none.
+func describePreferences(p *MissionPreferences) string {
+	if p.IsEmpty() {
+		return "None"
+	}
+	lines := []string{}
+	if len(p.Include) > 0 {
+		lines = append(lines, "Include: "+strings.Join(p.Include, "; "))
+	}
+	if len(p.Exclude) > 0 {
+		lines = append(lines, "Exclude: "+strings.Join(p.Exclude, "; "))
+	}
+	return strings.Join(lines, "\n")
+}
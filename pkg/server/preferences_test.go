@@ -0,0 +1,108 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMissionPreferences_Merge(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		layers      []*MissionPreferences
+		wantInclude string
+		wantExclude string
+	}{
+		{
+			name:   "Nothing",
+			layers: []*MissionPreferences{nil, nil},
+		},
+		{
+			name:        "Layers add up",
+			layers:      []*MissionPreferences{{Exclude: []string{"Rhino"}}, {Include: []string{"aspect:Justice"}, Exclude: []string{"Kang"}}},
+			wantInclude: "aspect:Justice",
+			wantExclude: "Rhino;Kang",
+		},
+		{
+			name:        "Later layers override",
+			layers:      []*MissionPreferences{{Exclude: []string{"Rhino", "Kang"}}, {Include: []string{"rhino"}}},
+			wantInclude: "rhino",
+			wantExclude: "Kang",
+		},
+		{
+			name:        "No duplicates",
+			layers:      []*MissionPreferences{{Include: []string{"Thor"}}, {Include: []string{"THOR"}}},
+			wantInclude: "Thor",
+		},
+	}
+	for _, tt := range testCases {
+		merged := tt.layers[0]
+		for _, layer := range tt.layers[1:] {
+			merged = merged.Merge(layer)
+		}
+		if got := strings.Join(merged.Include, ";"); got != tt.wantInclude {
+			t.Errorf("%s: expected to include %q, got %q", tt.name, tt.wantInclude, got)
+		}
+		if got := strings.Join(merged.Exclude, ";"); got != tt.wantExclude {
+			t.Errorf("%s: expected to exclude %q, got %q", tt.name, tt.wantExclude, got)
+		}
+	}
+}
+
+func TestPreferenceStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "preferences")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "preferences.yaml")
+
+	store, err := OpenPreferenceStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error opening a missing file: %v", err)
+	}
+	if !store.Guild("1").IsEmpty() || !store.User("2").IsEmpty() {
+		t.Errorf("expected no preferences before any are set")
+	}
+	if err := store.SetGuild("1", &MissionPreferences{Exclude: []string{"pack:Core Set"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.SetUser("2", &MissionPreferences{Include: []string{"Kang"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.SetUser("3", &MissionPreferences{Include: []string{"Rhino"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.SetUser("3", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Preferences are saved, and read back when the store is opened again
+	store, err = OpenPreferenceStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(store.Guild("1").Exclude, ";"); got != "pack:Core Set" {
+		t.Errorf("expected the server to exclude the Core Set, got %q", got)
+	}
+	if got := strings.Join(store.User("2").Include, ";"); got != "Kang" {
+		t.Errorf("expected the user to include Kang, got %q", got)
+	}
+	if !store.User("3").IsEmpty() {
+		t.Errorf("expected cleared preferences to be removed")
+	}
+
+	// Changing a copy leaves the store alone
+	store.User("2").Include[0] = "Rhino"
+	if got := store.User("2").Include[0]; got != "Kang" {
+		t.Errorf("expected the store to be unchanged, got %q", got)
+	}
+}
+
+func TestSplitTerms(t *testing.T) {
+	if got := splitTerms(" Kang;; pack:gmw ;"); strings.Join(got, ",") != "Kang,pack:gmw" {
+		t.Errorf("expected Kang and pack:gmw, got %v", got)
+	}
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"fmt"
+	"github.com/bwmarrin/discordgo"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
 	"marvelbot/pkg/rule"
+	"strings"
 )
 
 // ReadRules parses rules in YAML format and returns them to the caller.
@@ -29,3 +31,175 @@ func ReadRules(path string) (rules []*rule.Rule, err error) {
 	}
 	return
 }
+
+// ruleChanges holds two versions of a Rule to be compared.
+type ruleChanges struct {
+	From *rule.Rule
+	To   *rule.Rule
+}
+
+// findRuleChanges returns the versions of the named rule to compare. The newest version is compared against the given
+// version, or against the version before it if no version is given.
+func findRuleChanges(name string, version string, rules *rule.Store) (*ruleChanges, error) {
+	versions := rules.Versions(name)
+	if len(versions) < 2 {
+		return nil, fmt.Errorf("only one version")
+	}
+	changes := &ruleChanges{
+		From: rules.Get(name, versions[len(versions)-2]),
+		To:   rules.Latest(name),
+	}
+	if version != "" {
+		if changes.From = rules.Get(name, version); changes.From == nil {
+			return nil, fmt.Errorf("no version %s", version)
+		}
+	}
+	return changes, nil
+}
+
+// describeRuleVersions returns the version of a rule, along with any other versions available, for display to the user.
+func describeRuleVersions(r *rule.Rule, rules *rule.Store) string {
+	others := []string{}
+	for _, version := range rules.Versions(r.Name) {
+		if version != r.Version {
+			others = append(others, "v"+version)
+		}
+	}
+	if len(others) == 0 {
+		return fmt.Sprintf("Rules Reference v%s", r.Version)
+	}
+	return fmt.Sprintf("Rules Reference v%s (also in %s)", r.Version, strings.Join(others, ", "))
+}
+
+// formatRuleDiff formats the changes between two versions of a rule as a diff code block of at most length
+// characters. Unchanged sentences are left out, since rules are often long and only a sentence or two changes.
+func formatRuleDiff(changes []rule.Change, length int) string {
+	lines := []string{}
+	for _, c := range changes {
+		switch c.Type {
+		case rule.Added:
+			lines = append(lines, "+ "+c.Text)
+		case rule.Removed:
+			lines = append(lines, "- "+c.Text)
+		}
+	}
+	if len(lines) == 0 {
+		return "The text of this rule has not changed."
+	}
+	diff := strings.Join(lines, "\n")
+	// Leave room for the code block and a truncation marker
+	if runes := []rune(diff); len(runes) > length-16 {
+		diff = string(runes[:length-16]) + "\n…"
+	}
+	return "```diff\n" + diff + "\n```"
+}
+
+// AutocompleteLimit is the maximum number of choices Discord accepts in response to an autocomplete request.
+const AutocompleteLimit = 25
+
+// suggestRules returns up to limit rule names for the query, for use in autocomplete. Names starting with the query
+// come first, followed by names containing it, and then by fuzzy matches for anything misspelled.
+func suggestRules(query string, rules *rule.Store, limit int) (names []string) {
+	query = rule.NormalizeName(query)
+	seen := map[string]bool{}
+	add := func(name string) {
+		if !seen[name] && len(names) < limit {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, name := range rules.Names() {
+		if strings.HasPrefix(rule.NormalizeName(name), query) {
+			add(name)
+		}
+	}
+	for _, name := range rules.Names() {
+		if strings.Contains(rule.NormalizeName(name), query) {
+			add(name)
+		}
+	}
+	if query != "" {
+		for _, m := range fuzzyRank(query, rules.Names(), FuzzyCutoff) {
+			add(m.Value)
+		}
+	}
+	return names
+}
+
+// ruleEmbed builds the embed used to display a rule.
+func ruleEmbed(r *rule.Rule, rules *rule.Store) *discordgo.MessageEmbed {
+	// Create our embed fields
+	fields := []*discordgo.MessageEmbedField{}
+	// If rules exceed 2048 characters, they won't fit in the Description field
+	// So we'll parse those into extra fields
+	if len(r.Text2) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Rules (continued)",
+			Value: r.Text2,
+		})
+	}
+	// If there is a "See also" section, append that as well
+	if len(r.Related) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "See also",
+			Value: strings.Join(r.Related, "\n"),
+		})
+	}
+	return &discordgo.MessageEmbed{
+		Title:       r.Name,
+		Description: r.Text, // Description allows 2048 characters
+		Fields:      fields, // Embed fields allow 1024 characters
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: describeRuleVersions(r, rules),
+		},
+	}
+}
+
+// ruleComponents builds a button for each related rule that can be found, so users can follow "See also" entries.
+// Related rules are shown from the same version as the rule where possible. Entries that don't name a rule, like links
+// to the appendices, are skipped, as are entries that name a rule already linked.
+func ruleComponents(r *rule.Rule, rules *rule.Store) (components []discordgo.MessageComponent) {
+	buttons := []discordgo.MessageComponent{}
+	seen := map[string]bool{}
+	for _, name := range r.Related {
+		related := findRelatedRule(name, r.Version, rules)
+		if related == nil {
+			continue
+		}
+		// Discord rejects a message where two components share a custom ID
+		customID := fmt.Sprintf("rule:%s:%s", related.Version, related.Name)
+		if seen[customID] {
+			continue
+		}
+		seen[customID] = true
+		buttons = append(buttons, discordgo.Button{
+			Label:    related.Name,
+			Style:    discordgo.SecondaryButton,
+			CustomID: customID,
+		})
+	}
+	// Discord allows up to 5 rows of 5 buttons
+	for len(buttons) > 0 && len(components) < 5 {
+		n := len(buttons)
+		if n > 5 {
+			n = 5
+		}
+		components = append(components, discordgo.ActionsRow{Components: buttons[:n]})
+		buttons = buttons[n:]
+	}
+	return components
+}
+
+// findRelatedRule looks up a rule named in a "See also" entry, preferring the given version. Only the exact name, or
+// its singular or plural, is matched: a fuzzy match would send "Alteration Effect" to Lasting Effects.
+func findRelatedRule(name string, version string, rules *rule.Store) *rule.Rule {
+	for _, candidate := range []string{name, name + "s", strings.TrimSuffix(name, "s")} {
+		if related := rules.Get(candidate, version); related != nil {
+			return related
+		}
+		if related := rules.Latest(candidate); related != nil {
+			return related
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"marvelbot/pkg/rule"
+	"strings"
+	"testing"
+)
+
+func TestRuleComponents(t *testing.T) {
+	rules := rule.NewStore([]*rule.Rule{
+		{Name: "Ability", Version: "1.4", Related: []string{
+			"Action",
+			"Alteration Effect",
+			"Delayed Effect",
+			"Lasting Effects",
+			"Lasting Effect",
+			"Encounter Deck",
+			"[Appendix II: Setup](https://example.com/rules.pdf#page=20)",
+		}},
+		{Name: "Action", Version: "1.3"},
+		{Name: "Action", Version: "1.4"},
+		{Name: "Delayed Effects", Version: "1.3"},
+		{Name: "Lasting Effects", Version: "1.3"},
+		{Name: "Empty Encounter Deck", Version: "1.3"},
+	})
+	ids := []string{}
+	for _, row := range ruleComponents(rules.Get("Ability", "1.4"), rules) {
+		for _, component := range row.(discordgo.ActionsRow).Components {
+			ids = append(ids, component.(discordgo.Button).CustomID)
+		}
+	}
+	// Entries that don't name a rule are skipped rather than matched fuzzily, e.g. Alteration Effect is not Lasting
+	// Effects and Encounter Deck is not Empty Encounter Deck, and each rule is only linked once
+	want := "rule:1.4:Action,rule:1.3:Delayed Effects,rule:1.3:Lasting Effects"
+	if got := strings.Join(ids, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
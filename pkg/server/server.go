@@ -1,12 +1,15 @@
 package server
 
 import (
+	"fmt"
 	"github.com/bwmarrin/discordgo"
 	"github.com/sirupsen/logrus"
 	"marvelbot/pkg/card"
+	"marvelbot/pkg/config"
 	"marvelbot/pkg/rule"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -16,14 +19,24 @@ type Server struct {
 	Client   *http.Client
 	Commands []*discordgo.ApplicationCommand
 	Handlers map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate)
-	Cards    []*card.Card
-	Homebrew []*card.Card
-	Rules    []*rule.Rule
-	Logger   *logrus.Logger
+	// Autocompleters are keyed by command name, like Handlers
+	Autocompleters map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate)
+	// ComponentHandlers are keyed by the prefix of a component's custom ID, e.g. "rule" for "rule:1.4:Villain Phase"
+	ComponentHandlers map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate)
+	Cards             *card.CardRepository
+	Homebrew          *card.CardRepository
+	Packs             *card.PackRegistry
+	Missions          *MissionCatalog
+	Collections       *CollectionStore
+	Preferences       *PreferenceStore
+	Rules             *rule.Store
+	Renderer          *card.Renderer
+	Images            *card.ImageCache
+	Logger            *logrus.Logger
 }
 
 // NewServer creates a new Server with all our expected dependencies - cards, logging, and a working Discord session.
-func NewServer(token string) (s *Server) {
+func NewServer(token string, cfg *config.Config) (s *Server) {
 	// Create a new Logger
 	log := logrus.New()
 
@@ -61,6 +74,32 @@ func NewServer(token string) (s *Server) {
 		log.Fatal("error reading homebrew card data: ", err)
 	}
 
+	// Read in the packs that cards have been published in
+	packs, err := card.LoadPackRegistry("data/packs.yaml")
+	if err != nil {
+		log.Fatal("error reading pack data: ", err)
+	}
+
+	// Open the packs each user has recorded in their collection
+	collectionsPath := DefaultCollectionsPath
+	if cfg.Collections != "" {
+		collectionsPath = cfg.Collections
+	}
+	collections, err := OpenCollectionStore(collectionsPath)
+	if err != nil {
+		log.Fatal("error reading collections: ", err)
+	}
+
+	// Open the mission preferences of each server and user
+	preferencesPath := DefaultPreferencesPath
+	if cfg.Preferences != "" {
+		preferencesPath = cfg.Preferences
+	}
+	preferences, err := OpenPreferenceStore(preferencesPath)
+	if err != nil {
+		log.Fatal("error reading preferences: ", err)
+	}
+
 	// Read data in from our folder containing rule YAML data
 	rules, err := ReadRules("data/rules")
 	if err != nil {
@@ -69,21 +108,94 @@ func NewServer(token string) (s *Server) {
 
 	// Build and return our server
 	s = &Server{
-		Session:  dg,
-		Client:   client,
-		Commands: commands,
-		Cards:    cards,
-		Homebrew: homebrew,
-		Rules:    rules,
-		Logger:   log,
+		Session:     dg,
+		Client:      client,
+		Commands:    commands,
+		Cards:       card.NewCardRepository(cards),
+		Homebrew:    card.NewCardRepository(homebrew),
+		Packs:       packs,
+		Collections: collections,
+		Preferences: preferences,
+		Rules:       rule.NewStore(rules),
+		Renderer:    card.NewRenderer(cfg.Emoji),
+		Images:      NewImageCache(cfg, client),
+		Logger:      log,
+	}
+
+	s.Cards.SetPackRegistry(packs)
+
+	// Read in the heroes, villains and modules that missions are drawn from
+	s.Missions, err = ReadMissionCatalog("data/missions.yaml", s.Cards, packs)
+	if err != nil {
+		log.Fatal("error reading mission data: ", err)
 	}
 
 	// Append our handlers (which need access to the Cards object inside the Server)
 	handlers := map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
-		"card":    s.CardHandler,
-		"mission": s.MissionHandler,
+		"card":        s.CardHandler,
+		"collection":  s.CollectionHandler,
+		"preferences": s.PreferencesHandler,
+		"mission":     s.MissionHandler,
+		"rule":        s.RuleHandler,
+		"search":      s.SearchHandler,
 	}
 	s.Handlers = handlers
+	s.Autocompleters = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+		"card":       s.CardAutocompleteHandler,
+		"collection": s.CollectionAutocompleteHandler,
+		"rule":       s.RuleAutocompleteHandler,
+	}
+	s.ComponentHandlers = map[string]func(s *discordgo.Session, i *discordgo.InteractionCreate){
+		"card": s.CardComponentHandler,
+		"flip": s.CardFlipHandler,
+		"rule": s.RuleComponentHandler,
+	}
+
+	// Download any card images we don't have yet, so users are not kept waiting on them
+	go s.prefetchImages(append(cards, homebrew...))
 
 	return s
 }
+
+// NewImageCache creates the card image cache from the config, using the images directory and the image bucket unless
+// other stores are configured.
+func NewImageCache(cfg *config.Config, client *http.Client) *card.ImageCache {
+	cache, source := card.IMAGE_BASEDIR, card.ImageBaseURL
+	if cfg.Images.Cache != "" {
+		cache = cfg.Images.Cache
+	}
+	if cfg.Images.Source != "" {
+		source = cfg.Images.Source
+	}
+	return card.NewImageCache(card.OpenImageStore(cache, client), card.OpenImageStore(source, client), card.DefaultConcurrency)
+}
+
+// prefetchImages downloads the images of every card in the background, and logs the result.
+func (srv *Server) prefetchImages(cards []*card.Card) {
+	result := srv.Images.Warm(cards)
+	for _, err := range result.Errors {
+		srv.Logger.Warn(fmt.Sprintf("error prefetching image: %v", err))
+	}
+	srv.Logger.Info(fmt.Sprintf("Prefetched card images: %d downloaded, %d already cached, %d failed",
+		result.Fetched, result.Cached, len(result.Errors)))
+}
+
+// HandleInteraction routes an interaction to the handler for its type: slash commands and autocomplete requests by
+// command name, and message components by the prefix of their custom ID.
+func (srv *Server) HandleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		if h, ok := srv.Handlers[i.ApplicationCommandData().Name]; ok {
+			h(s, i)
+		}
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		if h, ok := srv.Autocompleters[i.ApplicationCommandData().Name]; ok {
+			h(s, i)
+		}
+	case discordgo.InteractionMessageComponent:
+		prefix := strings.SplitN(i.MessageComponentData().CustomID, ":", 2)[0]
+		if h, ok := srv.ComponentHandlers[prefix]; ok {
+			h(s, i)
+		}
+	}
+}
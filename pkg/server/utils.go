@@ -1,14 +1,19 @@
 package server
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	gim "github.com/ozankasikci/go-image-merge"
-	"github.com/segmentio/ksuid"
+	"github.com/bwmarrin/discordgo"
+	"gopkg.in/yaml.v3"
+	"image"
+	"image/jpeg"
 	"image/png"
+	"io/ioutil"
 	"marvelbot/pkg/card"
-	"math"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
@@ -29,73 +34,216 @@ func walkConfigs(path string, extension string) (files []string, err error) {
 	return files, nil
 }
 
-// buildImage takes a slice of cards and builds a single image from them. It is assumed that some pre-processing is
-// done on the cards first to separate horizontal from vertical cards.
-func buildImage(cards []*card.Card) (fileName string, cardsWithErrors []*card.Card, err error) {
-	// Slice to hold all of our grids
-	grids := []*gim.Grid{}
-	// Now, we'll add all of the cards to our grid
-	for _, c := range cards {
-		err := c.DownloadImages()
-		if err != nil {
-			cardsWithErrors = append(cardsWithErrors, c)
-			continue
+// writeYAMLFile writes v to a temporary file as YAML, and then moves it over the file at path, so that the file is never
+// left half written.
+func writeYAMLFile(path string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshaling %s: %w", path, err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to save %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to save %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to save %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to save %s: %w", path, err)
+	}
+	return nil
+}
+
+// UploadLimit is the largest total size of the attachments of a single Discord message, in bytes.
+const UploadLimit = 8 * 1024 * 1024
+
+// jpegQualities are tried in order when a composed image is too large to upload as a PNG.
+var jpegQualities = []int{90, 75, 60, 45}
+
+// errImageTooLarge is returned by encodeImage when an image cannot be encoded within the size limit.
+var errImageTooLarge = errors.New("image too large to upload")
+
+// cardImage is a composed image of cards, encoded for upload to Discord.
+type cardImage struct {
+	Data        []byte
+	Extension   string
+	ContentType string
+}
+
+// file returns the image as an attachment with the given name, to which the image's extension is added.
+func (ci *cardImage) file(name string) *discordgo.File {
+	return &discordgo.File{
+		Name:        name + "." + ci.Extension,
+		ContentType: ci.ContentType,
+		Reader:      bytes.NewReader(ci.Data),
+	}
+}
+
+// encodeImage encodes an image as a PNG, or as a JPEG of decreasing quality if the PNG is larger than limit bytes.
+func encodeImage(img image.Image, limit int) (*cardImage, error) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, fmt.Errorf("encodeImage: unable to encode png: %w", err)
+	}
+	if b.Len() <= limit {
+		return &cardImage{Data: b.Bytes(), Extension: "png", ContentType: "image/png"}, nil
+	}
+	for _, quality := range jpegQualities {
+		b = bytes.Buffer{}
+		if err := jpeg.Encode(&b, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, fmt.Errorf("encodeImage: unable to encode jpeg: %w", err)
 		}
-		for _, cardFace := range c.Faces {
-			imageSlice := strings.Split(*cardFace.ImageURL, "/")
-			imageName := imageSlice[len(imageSlice)-1]
-			imageDir := fmt.Sprintf("%s/%s", IMAGE_BASEDIR, c.Packs[0].SKU)
-			imagePath := fmt.Sprintf("%s/%s", imageDir, imageName)
-			// Add cards to their respective Grid based on orientation (vertical or horizontal)
-			grid := &gim.Grid{
-				ImageFilePath: imagePath,
+		if b.Len() <= limit {
+			return &cardImage{Data: b.Bytes(), Extension: "jpg", ContentType: "image/jpeg"}, nil
+		}
+	}
+	return nil, errImageTooLarge
+}
+
+// buildImages lays out the images of the cards with layoutCards and encodes the result, with no image larger than
+// limit bytes. When the layout is too large even as a JPEG, the cards are split into pages of their own.
+func buildImages(cards []*card.Card, cache *card.ImageCache, opts layoutOptions, limit int) (images []*cardImage, cardsWithErrors []*card.Card) {
+	rgba, cardsWithErrors, err := layoutCards(cards, cache, opts)
+	if err != nil {
+		return nil, cardsWithErrors
+	}
+	img, err := encodeImage(rgba, limit)
+	if err == nil {
+		return []*cardImage{img}, cardsWithErrors
+	}
+	if len(cards) <= 1 || err != errImageTooLarge {
+		for _, c := range cards {
+			if !containsCard(cardsWithErrors, c) {
+				cardsWithErrors = append(cardsWithErrors, c)
 			}
-			grids = append(grids, grid)
 		}
+		return nil, cardsWithErrors
 	}
-	// If the grids slice is empty, we are unable to return any images
-	if len(grids) == 0 {
-		return "", cardsWithErrors, fmt.Errorf("buildImage: no images found")
+	// Split the cards in half and try again. Each page reports its own errors.
+	cardsWithErrors = nil
+	half := len(cards) / 2
+	for _, page := range [][]*card.Card{cards[:half], cards[half:]} {
+		pageImages, pageErrors := buildImages(page, cache, opts, limit)
+		images = append(images, pageImages...)
+		cardsWithErrors = append(cardsWithErrors, pageErrors...)
 	}
-	// Merge the images together
-	var horizontal, vertical int
-	if len(grids) >= 2 {
-		horizontal = 2
-		vertical = int(math.Ceil(float64(len(grids)) / 2.0))
-	} else {
-		horizontal = 1
-		vertical = 1
+	return images, cardsWithErrors
+}
+
+// containsCard returns whether the card is in the slice.
+func containsCard(cards []*card.Card, c *card.Card) bool {
+	for _, other := range cards {
+		if other == c {
+			return true
+		}
 	}
-	rgba, err := gim.New(grids, horizontal, vertical).Merge()
-	if err != nil {
-		return "", cardsWithErrors, fmt.Errorf("buildImage: unable to merge grid: %w", err)
+	return false
+}
+
+// containsString returns whether the string is in the slice.
+func containsString(s []string, value string) bool {
+	for _, v := range s {
+		if v == value {
+			return true
+		}
 	}
-	// Create a guid to use for the image name
-	guid := ksuid.New()
-	fileName = fmt.Sprintf("temp_%s.png", guid.String())
-	// Save the output to PNG
-	file, err := os.Create(fmt.Sprintf("%s/%s", IMAGE_BASEDIR, fileName))
-	if err != nil {
-		return "", cardsWithErrors, fmt.Errorf("buildImage: unable to write file: %w", err)
+	return false
+}
+
+// buildCardFiles lays out the images of the cards as attachments, grouped into batches that each fit within a single
+// message's upload limit. Each batch should be sent as a message of its own.
+func buildCardFiles(cards []*card.Card, cache *card.ImageCache, opts layoutOptions) (batches [][]*discordgo.File, cardsWithErrors []*card.Card) {
+	images, cardsWithErrors := buildImages(cards, cache, opts, UploadLimit)
+	// Pack the images into as few messages as possible
+	var batch []*discordgo.File
+	size := 0
+	for n, img := range images {
+		if len(batch) > 0 && size+len(img.Data) > UploadLimit {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, img.file(fmt.Sprintf("cards_%d", n+1)))
+		size += len(img.Data)
 	}
-	err = png.Encode(file, rgba)
-	if err != nil {
-		return "", cardsWithErrors, fmt.Errorf("buildImage: unable to encode png: %w", err)
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches, cardsWithErrors
+}
+
+// directives are command prefixes handled by the bot itself, rather than by the card query parser
+var directives = map[string]bool{
+	"hb":       true,
+	"homebrew": true,
+	"info":     true,
+	"rule":     true,
+	"rules":    true,
+	"changes":  true,
+}
+
+// splitCommand takes a command string (e.g., Rule:Villain Phase) and returns the directive and query. A directive may
+// request a specific version, e.g. Rule@1.3:Ability, which is returned separately. Anything that is not a directive,
+// such as Ally:Lockjaw or type:Ally cost<=2, is returned whole as a card query.
+func splitCommand(s string) (filter string, version string, query string) {
+	if parts := strings.SplitN(s, ":", 2); len(parts) == 2 {
+		prefix := strings.ToLower(strings.TrimSpace(parts[0]))
+		if i := strings.Index(prefix, "@"); i >= 0 {
+			prefix, version = strings.TrimSpace(prefix[:i]), strings.TrimSpace(prefix[i+1:])
+		}
+		if directives[prefix] {
+			return prefix, strings.TrimPrefix(version, "v"), strings.TrimSpace(parts[1])
+		}
 	}
-	defer file.Close()
-	return fileName, cardsWithErrors, nil
+	return "", "", strings.TrimSpace(s)
 }
 
-// splitCommand takes a command string (e.g., Ally:Lockjaw) and returns the filter and query
-func splitCommand(s string) (filter string, query string) {
-	if strings.Contains(s, ":") {
-		parts := strings.Split(s, ":")
-		filter = strings.ToLower(parts[0])
-		query = strings.ToLower(parts[1])
-	} else {
-		query = strings.ToLower(s)
+// sideRegexp matches a request for a single side of a card at the end of a query, e.g. the #B of Spider-Man#B
+var sideRegexp = regexp.MustCompile(`\s*#\s*([A-Za-z])\s*$`)
+
+// splitSide takes a card query (e.g., Spider-Man#B) and returns the query and the requested side, if any.
+func splitSide(s string) (query string, side string) {
+	if m := sideRegexp.FindStringSubmatchIndex(s); m != nil {
+		return strings.TrimSpace(s[:m[0]]), strings.ToUpper(s[m[2]:m[3]])
+	}
+	return strings.TrimSpace(s), ""
+}
+
+// textSnippet returns the line of a card's rules text that best matches the query, shortened to at most length
+// characters.
+func textSnippet(c *card.Card, query string, length int) string {
+	tokens := card.Tokenize(query)
+	var best string
+	bestScore := -1
+	for _, face := range c.Faces {
+		for _, line := range strings.Split(face.RulesText(), "\n") {
+			score := 0
+			lineTokens := card.Tokenize(line)
+			for _, token := range tokens {
+				for _, lineToken := range lineTokens {
+					if token == lineToken {
+						score++
+						break
+					}
+				}
+			}
+			if score > bestScore {
+				best, bestScore = line, score
+			}
+		}
+	}
+	best = strings.TrimSpace(best)
+	if best == "" {
+		return "No card text available."
+	}
+	if runes := []rune(best); len(runes) > length {
+		best = string(runes[:length-1]) + "…"
 	}
-	return filter, query
+	return best
 }
 
 // It seems it may be best to leave these functions as type-specific operations.
@@ -118,3 +266,11 @@ func removeHeroIndex(s []*Hero, i int) []*Hero {
 	s[i] = s[len(s)-1]
 	return s[:len(s)-1]
 }
+
+// truncate shortens a string to at most length characters, for use in Discord fields with a character limit.
+func truncate(s string, length int) string {
+	if runes := []rune(s); len(runes) > length {
+		return string(runes[:length-1]) + "…"
+	}
+	return s
+}
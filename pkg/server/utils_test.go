@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"marvelbot/pkg/card"
+	"math/rand"
+	"testing"
+)
+
+// newTestImage returns a PNG of a single color, for use with an in-memory image store.
+func newTestImage(t *testing.T, c color.Color, width int, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestBuildImages(t *testing.T) {
+	// Noise compresses poorly, so the size of the encoded images is predictable
+	store := card.NewMemoryStore()
+	cards := []*card.Card{}
+	for n, name := range []string{"Lockjaw", "Jessica Jones"} {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 56))
+		rand.New(rand.NewSource(int64(n))).Read(img.Pix)
+		var b bytes.Buffer
+		if err := png.Encode(&b, img); err != nil {
+			t.Fatal(err)
+		}
+		f := &card.Face{Name: name, ImageURL: strPtr(card.ImageBaseURL + name + ".png")}
+		key, _ := card.ImageKey(f)
+		store.Put(key, b.Bytes())
+		cards = append(cards, &card.Card{Names: []string{name}, Faces: []*card.Face{f}})
+	}
+	cache := card.NewImageCache(store, card.NewMemoryStore(), 1)
+
+	var testCases = []struct {
+		name       string
+		limit      int
+		extensions []string
+		errors     int
+	}{
+		{name: "Grid fits as a PNG", limit: 20000, extensions: []string{"png"}},
+		{name: "Grid fits as a JPEG", limit: 5000, extensions: []string{"jpg"}},
+		{name: "Grid is split into pages", limit: 2000, extensions: []string{"jpg", "jpg"}},
+		{name: "Card too large to upload", limit: 1000, errors: 2},
+	}
+
+	for _, tt := range testCases {
+		images, cardsWithErrors := buildImages(cards, cache, layoutOptions{}, tt.limit)
+		if len(cardsWithErrors) != tt.errors {
+			t.Errorf("%s: expected %d cards with errors, got %d", tt.name, tt.errors, len(cardsWithErrors))
+		}
+		if len(images) != len(tt.extensions) {
+			t.Errorf("%s: expected %d images, got %d", tt.name, len(tt.extensions), len(images))
+			continue
+		}
+		for n, img := range images {
+			if img.Extension != tt.extensions[n] {
+				t.Errorf("%s: expected image %d to be a %s, got %s", tt.name, n+1, tt.extensions[n], img.Extension)
+			}
+			if len(img.Data) > tt.limit {
+				t.Errorf("%s: image %d is %d bytes, over the limit of %d", tt.name, n+1, len(img.Data), tt.limit)
+			}
+		}
+	}
+}